@@ -2,7 +2,7 @@ package api
 
 import (
 	"bytes"
-	"crypto/tls"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -10,6 +10,7 @@ import (
 	"net/url"
 	"os"
 	"strings"
+	"sync/atomic"
 	"time"
 )
 
@@ -18,8 +19,30 @@ type Client struct {
 	BaseURL     string
 	APIKey      string
 	Debug       bool
+	DebugUnsafe bool
 	InsecureTLS bool
 	HTTPClient  *http.Client
+	Retry       RetryPolicy
+
+	// Credentials, if set, supplies the API key for each request in place
+	// of APIKey, e.g. from an external credential helper.
+	Credentials TokenSource
+
+	// UserAgent is sent as the User-Agent header of every request.
+	UserAgent string
+	// Notices, if set, receives a one-line notice the first time the server
+	// reports that an endpoint is deprecated or sends a warning. Later
+	// notices are dropped so a command prints at most one.
+	Notices io.Writer
+
+	noticed atomic.Bool
+}
+
+// TokenSource supplies the API key used to authenticate requests.
+// It is called before every request, so implementations may cache the key
+// and refresh it when it expires during a long-running command.
+type TokenSource interface {
+	Token(ctx context.Context) (string, error)
 }
 
 // NewClient creates a new API client
@@ -30,36 +53,60 @@ func NewClient(baseURL, apiKey string) *Client {
 		HTTPClient: &http.Client{
 			Timeout: 30 * time.Second,
 		},
+		Retry:     DefaultRetryPolicy(),
+		UserAgent: UserAgent("dev"),
 	}
 }
 
-// NewClientDebug creates a new API client with debug logging enabled
+// NewClientDebug creates a new API client with debug logging enabled. Debug
+// output has credentials and secret values redacted unless DebugUnsafe is set
+// on the returned client.
 func NewClientDebug(baseURL, apiKey string, debug, insecureTLS bool) *Client {
 	c := NewClient(baseURL, apiKey)
 	c.Debug = debug
 	if insecureTLS {
+		// NewTransport cannot fail when only verification is disabled.
 		c.InsecureTLS = true
-		c.HTTPClient.Transport = &http.Transport{
-			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-		}
+		c.HTTPClient.Transport, _ = NewTransport(TransportOptions{InsecureSkipVerify: true})
 	}
 	return c
 }
 
+// ConfigureTransport replaces the client's HTTP transport with one built
+// from opts. InsecureSkipVerify is also enabled if the client was created
+// with InsecureTLS set.
+func (c *Client) ConfigureTransport(opts TransportOptions) error {
+	opts.InsecureSkipVerify = opts.InsecureSkipVerify || c.InsecureTLS
+	t, err := NewTransport(opts)
+	if err != nil {
+		return err
+	}
+	c.HTTPClient.Transport = t
+	return nil
+}
+
 // CreateRequest represents the request payload for creating a VM
 type CreateRequest struct {
-	PublicKey string `json:"public_key"`
-	Name      string `json:"name"`
+	PublicKey string            `json:"public_key"`
+	Name      string            `json:"name"`
+	Labels    map[string]string `json:"labels,omitempty"`
+}
+
+// UpdateVMRequest represents the request payload for updating a VM. Labels
+// are merged into the VM's labels: a nil value removes the label.
+type UpdateVMRequest struct {
+	Labels map[string]*string `json:"labels,omitempty"`
 }
 
 // VM represents a VM resource returned by the API
 type VM struct {
-	ID           string `json:"id"`
-	Name         string `json:"name"`
-	Status       string `json:"status"`
-	StatusDetail string `json:"status_detail,omitempty"`
-	CreatedAt    string `json:"created_at"`
-	UpdatedAt    string `json:"updated_at"`
+	ID           string            `json:"id"`
+	Name         string            `json:"name"`
+	Status       string            `json:"status"`
+	StatusDetail string            `json:"status_detail,omitempty"`
+	Labels       map[string]string `json:"labels,omitempty"`
+	CreatedAt    string            `json:"created_at"`
+	UpdatedAt    string            `json:"updated_at"`
 }
 
 // ListVMsResponse represents the response from listing all VMs.
@@ -180,22 +227,57 @@ type ListSecretsResponse struct {
 
 // DeviceCodeResponse represents the response from POST /auth/device/code
 type DeviceCodeResponse struct {
-	Code            string    `json:"code"`
-	VerificationURI string    `json:"verification_uri"`
-	ExpiresAt       time.Time `json:"expires_at"`
+	Code            string `json:"code"`
+	VerificationURI string `json:"verification_uri"`
+	// VerificationURIComplete is VerificationURI with the code filled in,
+	// if the server supports it, so the user need not type the code.
+	VerificationURIComplete string    `json:"verification_uri_complete,omitempty"`
+	ExpiresAt               time.Time `json:"expires_at"`
+	// Interval is the minimum number of seconds between polls, or zero if
+	// the server does not say.
+	Interval int `json:"interval,omitempty"`
 }
 
-// PollResponse represents the response from GET /auth/device/poll
+// PollResponse represents the response from GET /auth/device/poll. Status
+// is "pending", "slow_down", "authorized" or "expired".
 type PollResponse struct {
 	Status string `json:"status"`
 	Token  string `json:"token,omitempty"`
+	// Interval is the new minimum number of seconds between polls when
+	// Status is "slow_down", or zero if the server does not say.
+	Interval int `json:"interval,omitempty"`
+}
+
+// Account is the user an API token belongs to.
+type Account struct {
+	ID    string `json:"id"`
+	Name  string `json:"name,omitempty"`
+	Email string `json:"email,omitempty"`
+}
+
+// Organization is the organization an API token acts on behalf of.
+type Organization struct {
+	ID   string `json:"id"`
+	Name string `json:"name,omitempty"`
+}
+
+// TokenInfo describes the API token a request is made with, as returned by
+// GET /auth/token.
+type TokenInfo struct {
+	Account      Account      `json:"account"`
+	Organization Organization `json:"organization"`
+	Scopes       []string     `json:"scopes"`
+	CreatedAt    time.Time    `json:"created_at"`
+	// ExpiresAt is nil for tokens that do not expire.
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
 }
 
 // ErrorResponse represents an error response from the API
 type ErrorResponse struct {
 	Error struct {
-		Code    string `json:"code"`
-		Message string `json:"message"`
+		Code      string `json:"code"`
+		Message   string `json:"message"`
+		RequestID string `json:"request_id,omitempty"`
 	} `json:"error"`
 }
 
@@ -217,17 +299,30 @@ func unwrapData[T any](body []byte) (T, error) {
 
 // Create creates a new VM
 func (c *Client) Create(key []byte, name string) (*VM, error) {
-	req := CreateRequest{
+	return c.CreateContext(context.Background(), key, name)
+}
+
+// CreateContext is like Create but uses ctx for the underlying request.
+func (c *Client) CreateContext(ctx context.Context, key []byte, name string) (*VM, error) {
+	return c.CreateVMContext(ctx, CreateRequest{
 		PublicKey: string(key),
 		Name:      name,
-	}
+	})
+}
+
+// CreateVM creates a new VM from a full request, e.g. one with labels.
+func (c *Client) CreateVM(req CreateRequest) (*VM, error) {
+	return c.CreateVMContext(context.Background(), req)
+}
 
+// CreateVMContext is like CreateVM but uses ctx for the underlying request.
+func (c *Client) CreateVMContext(ctx context.Context, req CreateRequest) (*VM, error) {
 	reqBody, err := json.Marshal(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	body, err := c.makeRequest("POST", "/vms", bytes.NewReader(reqBody))
+	body, err := c.makeRequest(ctx, "POST", "/vms", bytes.NewReader(reqBody), withIdempotencyKey())
 	if err != nil {
 		return nil, fmt.Errorf("failed to create VM: %w", err)
 	}
@@ -242,9 +337,14 @@ func (c *Client) Create(key []byte, name string) (*VM, error) {
 
 // GetVM retrieves a VM by ID
 func (c *Client) GetVM(id string) (*VM, error) {
+	return c.GetVMContext(context.Background(), id)
+}
+
+// GetVMContext is like GetVM but uses ctx for the underlying request.
+func (c *Client) GetVMContext(ctx context.Context, id string) (*VM, error) {
 	path := fmt.Sprintf("/vms/%s", id)
 
-	body, err := c.makeRequest("GET", path, nil)
+	body, err := c.makeRequest(ctx, "GET", path, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get VM: %w", err)
 	}
@@ -257,9 +357,46 @@ func (c *Client) GetVM(id string) (*VM, error) {
 	return &vm, nil
 }
 
-// ListVMs lists all VMs
+// UpdateVM updates a VM's labels.
+func (c *Client) UpdateVM(id string, req UpdateVMRequest) (*VM, error) {
+	return c.UpdateVMContext(context.Background(), id, req)
+}
+
+// UpdateVMContext is like UpdateVM but uses ctx for the underlying request.
+func (c *Client) UpdateVMContext(ctx context.Context, id string, req UpdateVMRequest) (*VM, error) {
+	reqBody, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	path := fmt.Sprintf("/vms/%s", id)
+	body, err := c.makeRequest(ctx, "PATCH", path, bytes.NewReader(reqBody))
+	if err != nil {
+		return nil, fmt.Errorf("failed to update VM: %w", err)
+	}
+
+	vm, err := unwrapData[VM](body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return &vm, nil
+}
+
+// ListVMs lists the first page of VMs. Use AllVMs to walk every page.
 func (c *Client) ListVMs() (*ListVMsResponse, error) {
-	body, err := c.makeRequest("GET", "/vms", nil)
+	return c.ListVMsContext(context.Background())
+}
+
+// ListVMsContext is like ListVMs but uses ctx for the underlying request.
+func (c *Client) ListVMsContext(ctx context.Context) (*ListVMsResponse, error) {
+	return c.ListVMsPageContext(ctx, ListOptions{})
+}
+
+// ListVMsPageContext fetches a single page of VMs starting at opts.Cursor.
+// Use AllVMs to walk every page.
+func (c *Client) ListVMsPageContext(ctx context.Context, opts ListOptions) (*ListVMsResponse, error) {
+	body, err := c.makeRequest(ctx, "GET", opts.path("/vms"), nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list VMs: %w", err)
 	}
@@ -274,11 +411,16 @@ func (c *Client) ListVMs() (*ListVMsResponse, error) {
 
 // ListVMsByName lists VMs filtered by name.
 func (c *Client) ListVMsByName(name string) (*ListVMsResponse, error) {
+	return c.ListVMsByNameContext(context.Background(), name)
+}
+
+// ListVMsByNameContext is like ListVMsByName but uses ctx for the underlying request.
+func (c *Client) ListVMsByNameContext(ctx context.Context, name string) (*ListVMsResponse, error) {
 	q := url.Values{}
 	q.Set("name", name)
 	path := "/vms?" + q.Encode()
 
-	body, err := c.makeRequest("GET", path, nil)
+	body, err := c.makeRequest(ctx, "GET", path, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list VMs by name: %w", err)
 	}
@@ -291,34 +433,69 @@ func (c *Client) ListVMsByName(name string) (*ListVMsResponse, error) {
 	return &listResp, nil
 }
 
-// ResolveVM resolves a VM identifier to a VM ID. If idOrName already looks
-// like a VM ID (starts with "vm_") it is returned as-is. Otherwise the value
-// is treated as a name: the list VMs endpoint is queried with that name and
-// the first non-destroyed VM in the result is returned.
+// ResolveVM resolves a VM identifier to a VM ID. If idOrName is the ID of
+// an existing VM it is returned as-is. Otherwise the value is matched
+// against the account's non-destroyed VMs: first by exact name, then by
+// unique prefix of a name or of an ID, with or without its "vm_" prefix.
+//
+// If more than one VM matches, an *AmbiguousError listing the candidates is
+// returned. If none match, a *NotFoundError suggesting similar names is
+// returned.
 func (c *Client) ResolveVM(idOrName string) (string, error) {
+	return c.ResolveVMContext(context.Background(), idOrName)
+}
+
+// ResolveVMContext is like ResolveVM but uses ctx for any lookup requests.
+func (c *Client) ResolveVMContext(ctx context.Context, idOrName string) (string, error) {
 	if strings.HasPrefix(idOrName, "vm_") {
-		return idOrName, nil
+		vm, err := c.GetVMContext(ctx, idOrName)
+		if err == nil {
+			return vm.ID, nil
+		}
+		if !IsNotFound(err) {
+			return "", fmt.Errorf("resolving VM ID %q: %w", idOrName, err)
+		}
+		// Not a whole ID, but it may be the start of one.
 	}
 
-	resp, err := c.ListVMsByName(idOrName)
+	// Ask the server to filter by name first; this is the common case and
+	// avoids listing every VM on the account.
+	resp, err := c.ListVMsByNameContext(ctx, idOrName)
 	if err != nil {
 		return "", fmt.Errorf("resolving VM name %q: %w", idOrName, err)
 	}
-
+	var exact []Candidate
 	for _, vm := range resp.Data {
-		if vm.Status != "destroyed" {
-			return vm.ID, nil
+		if vm.Name == idOrName && vm.Status != "destroyed" {
+			exact = append(exact, vmCandidate(vm))
 		}
 	}
+	if len(exact) > 0 {
+		return pickCandidate("VM", idOrName, exact)
+	}
 
-	return "", fmt.Errorf("no active VM found with name %q", idOrName)
+	var all []Candidate
+	for vm, err := range c.AllVMs(ctx) {
+		if err != nil {
+			return "", fmt.Errorf("resolving VM name %q: %w", idOrName, err)
+		}
+		if vm.Status != "destroyed" {
+			all = append(all, vmCandidate(vm))
+		}
+	}
+	return resolvePrefix("VM", "vm_", idOrName, all)
 }
 
 // SSH retrieves SSH connection information for a VM
 func (c *Client) SSH(id string) (*SSHResponse, error) {
+	return c.SSHContext(context.Background(), id)
+}
+
+// SSHContext is like SSH but uses ctx for the underlying request.
+func (c *Client) SSHContext(ctx context.Context, id string) (*SSHResponse, error) {
 	path := fmt.Sprintf("/vms/%s/ssh", id)
 
-	body, err := c.makeRequest("GET", path, nil)
+	body, err := c.makeRequest(ctx, "GET", path, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get SSH info: %w", err)
 	}
@@ -333,9 +510,14 @@ func (c *Client) SSH(id string) (*SSHResponse, error) {
 
 // Destroy destroys a VM
 func (c *Client) Destroy(id string) error {
+	return c.DestroyContext(context.Background(), id)
+}
+
+// DestroyContext is like Destroy but uses ctx for the underlying request.
+func (c *Client) DestroyContext(ctx context.Context, id string) error {
 	path := fmt.Sprintf("/vms/%s", id)
 
-	_, err := c.makeRequest("DELETE", path, nil)
+	_, err := c.makeRequest(ctx, "DELETE", path, nil)
 	if err != nil {
 		return fmt.Errorf("failed to destroy VM: %w", err)
 	}
@@ -345,9 +527,14 @@ func (c *Client) Destroy(id string) error {
 
 // Start starts a VM
 func (c *Client) Start(id string) (*VM, error) {
+	return c.StartContext(context.Background(), id)
+}
+
+// StartContext is like Start but uses ctx for the underlying request.
+func (c *Client) StartContext(ctx context.Context, id string) (*VM, error) {
 	path := fmt.Sprintf("/vms/%s/start", id)
 
-	body, err := c.makeRequest("POST", path, nil)
+	body, err := c.makeRequest(ctx, "POST", path, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to start VM: %w", err)
 	}
@@ -362,9 +549,14 @@ func (c *Client) Start(id string) (*VM, error) {
 
 // Stop stops a VM
 func (c *Client) Stop(id string) (*VM, error) {
+	return c.StopContext(context.Background(), id)
+}
+
+// StopContext is like Stop but uses ctx for the underlying request.
+func (c *Client) StopContext(ctx context.Context, id string) (*VM, error) {
 	path := fmt.Sprintf("/vms/%s/stop", id)
 
-	body, err := c.makeRequest("POST", path, nil)
+	body, err := c.makeRequest(ctx, "POST", path, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to stop VM: %w", err)
 	}
@@ -379,7 +571,12 @@ func (c *Client) Stop(id string) (*VM, error) {
 
 // EgressGetPolicy gets the current egress policy for the account
 func (c *Client) EgressGetPolicy() (*EgressModeResponse, error) {
-	body, err := c.makeRequest("GET", "/egress/policy", nil)
+	return c.EgressGetPolicyContext(context.Background())
+}
+
+// EgressGetPolicyContext is like EgressGetPolicy but uses ctx for the underlying request.
+func (c *Client) EgressGetPolicyContext(ctx context.Context) (*EgressModeResponse, error) {
+	body, err := c.makeRequest(ctx, "GET", "/egress/policy", nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get egress policy: %w", err)
 	}
@@ -394,6 +591,11 @@ func (c *Client) EgressGetPolicy() (*EgressModeResponse, error) {
 
 // EgressSetPolicy sets the egress policy for the account
 func (c *Client) EgressSetPolicy(mode string) error {
+	return c.EgressSetPolicyContext(context.Background(), mode)
+}
+
+// EgressSetPolicyContext is like EgressSetPolicy but uses ctx for the underlying request.
+func (c *Client) EgressSetPolicyContext(ctx context.Context, mode string) error {
 	req := EgressModeRequest{Mode: mode}
 
 	reqBody, err := json.Marshal(req)
@@ -401,7 +603,7 @@ func (c *Client) EgressSetPolicy(mode string) error {
 		return fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	_, err = c.makeRequest("PUT", "/egress/policy", bytes.NewReader(reqBody))
+	_, err = c.makeRequest(ctx, "PUT", "/egress/policy", bytes.NewReader(reqBody))
 	if err != nil {
 		return fmt.Errorf("failed to set egress policy: %w", err)
 	}
@@ -409,9 +611,21 @@ func (c *Client) EgressSetPolicy(mode string) error {
 	return nil
 }
 
-// EgressListRules lists all egress rules for the account
+// EgressListRules lists the first page of egress rules for the account. Use
+// AllEgressRules to walk every page.
 func (c *Client) EgressListRules() (*ListEgressRulesResponse, error) {
-	body, err := c.makeRequest("GET", "/egress/rules", nil)
+	return c.EgressListRulesContext(context.Background())
+}
+
+// EgressListRulesContext is like EgressListRules but uses ctx for the underlying request.
+func (c *Client) EgressListRulesContext(ctx context.Context) (*ListEgressRulesResponse, error) {
+	return c.EgressListRulesPageContext(ctx, ListOptions{})
+}
+
+// EgressListRulesPageContext fetches a single page of egress rules starting at
+// opts.Cursor. Use AllEgressRules to walk every page.
+func (c *Client) EgressListRulesPageContext(ctx context.Context, opts ListOptions) (*ListEgressRulesResponse, error) {
+	body, err := c.makeRequest(ctx, "GET", opts.path("/egress/rules"), nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list egress rules: %w", err)
 	}
@@ -426,12 +640,17 @@ func (c *Client) EgressListRules() (*ListEgressRulesResponse, error) {
 
 // EgressCreateRule creates a new egress rule
 func (c *Client) EgressCreateRule(req EgressRuleRequest) (*EgressRule, error) {
+	return c.EgressCreateRuleContext(context.Background(), req)
+}
+
+// EgressCreateRuleContext is like EgressCreateRule but uses ctx for the underlying request.
+func (c *Client) EgressCreateRuleContext(ctx context.Context, req EgressRuleRequest) (*EgressRule, error) {
 	reqBody, err := json.Marshal(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	body, err := c.makeRequest("POST", "/egress/rules", bytes.NewReader(reqBody))
+	body, err := c.makeRequest(ctx, "POST", "/egress/rules", bytes.NewReader(reqBody), withIdempotencyKey())
 	if err != nil {
 		return nil, fmt.Errorf("failed to create egress rule: %w", err)
 	}
@@ -446,9 +665,14 @@ func (c *Client) EgressCreateRule(req EgressRuleRequest) (*EgressRule, error) {
 
 // EgressDeleteRule deletes an egress rule by ID
 func (c *Client) EgressDeleteRule(id string) error {
+	return c.EgressDeleteRuleContext(context.Background(), id)
+}
+
+// EgressDeleteRuleContext is like EgressDeleteRule but uses ctx for the underlying request.
+func (c *Client) EgressDeleteRuleContext(ctx context.Context, id string) error {
 	path := fmt.Sprintf("/egress/rules/%s", id)
 
-	_, err := c.makeRequest("DELETE", path, nil)
+	_, err := c.makeRequest(ctx, "DELETE", path, nil)
 	if err != nil {
 		return fmt.Errorf("failed to delete egress rule: %w", err)
 	}
@@ -458,9 +682,14 @@ func (c *Client) EgressDeleteRule(id string) error {
 
 // VMEgressGetPolicy gets the egress policy for a specific VM
 func (c *Client) VMEgressGetPolicy(vmID string) (*EgressModeResponse, error) {
+	return c.VMEgressGetPolicyContext(context.Background(), vmID)
+}
+
+// VMEgressGetPolicyContext is like VMEgressGetPolicy but uses ctx for the underlying request.
+func (c *Client) VMEgressGetPolicyContext(ctx context.Context, vmID string) (*EgressModeResponse, error) {
 	path := fmt.Sprintf("/vms/%s/egress/policy", vmID)
 
-	body, err := c.makeRequest("GET", path, nil)
+	body, err := c.makeRequest(ctx, "GET", path, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get VM egress policy: %w", err)
 	}
@@ -475,6 +704,11 @@ func (c *Client) VMEgressGetPolicy(vmID string) (*EgressModeResponse, error) {
 
 // VMEgressSetPolicy sets the egress policy for a specific VM
 func (c *Client) VMEgressSetPolicy(vmID, mode string) error {
+	return c.VMEgressSetPolicyContext(context.Background(), vmID, mode)
+}
+
+// VMEgressSetPolicyContext is like VMEgressSetPolicy but uses ctx for the underlying request.
+func (c *Client) VMEgressSetPolicyContext(ctx context.Context, vmID, mode string) error {
 	req := EgressModeRequest{Mode: mode}
 
 	reqBody, err := json.Marshal(req)
@@ -483,7 +717,7 @@ func (c *Client) VMEgressSetPolicy(vmID, mode string) error {
 	}
 
 	path := fmt.Sprintf("/vms/%s/egress/policy", vmID)
-	_, err = c.makeRequest("PUT", path, bytes.NewReader(reqBody))
+	_, err = c.makeRequest(ctx, "PUT", path, bytes.NewReader(reqBody))
 	if err != nil {
 		return fmt.Errorf("failed to set VM egress policy: %w", err)
 	}
@@ -493,6 +727,11 @@ func (c *Client) VMEgressSetPolicy(vmID, mode string) error {
 
 // AuditEgress fetches egress audit events with the given query parameters.
 func (c *Client) AuditEgress(params AuditEgressParams) (*ListAuditEgressResponse, error) {
+	return c.AuditEgressContext(context.Background(), params)
+}
+
+// AuditEgressContext is like AuditEgress but uses ctx for the underlying request.
+func (c *Client) AuditEgressContext(ctx context.Context, params AuditEgressParams) (*ListAuditEgressResponse, error) {
 	q := url.Values{}
 	if params.VMID != "" {
 		q.Set("vm_id", params.VMID)
@@ -518,7 +757,7 @@ func (c *Client) AuditEgress(params AuditEgressParams) (*ListAuditEgressResponse
 		path += "?" + encoded
 	}
 
-	body, err := c.makeRequest("GET", path, nil)
+	body, err := c.makeRequest(ctx, "GET", path, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch egress audit log: %w", err)
 	}
@@ -533,7 +772,12 @@ func (c *Client) AuditEgress(params AuditEgressParams) (*ListAuditEgressResponse
 
 // DeviceCode requests a new device code to begin the device authorization flow.
 func (c *Client) DeviceCode() (*DeviceCodeResponse, error) {
-	body, err := c.makeRequest("POST", "/auth/device/code", nil)
+	return c.DeviceCodeContext(context.Background())
+}
+
+// DeviceCodeContext is like DeviceCode but uses ctx for the underlying request.
+func (c *Client) DeviceCodeContext(ctx context.Context) (*DeviceCodeResponse, error) {
+	body, err := c.makeRequest(ctx, "POST", "/auth/device/code", nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to request device code: %w", err)
 	}
@@ -548,9 +792,14 @@ func (c *Client) DeviceCode() (*DeviceCodeResponse, error) {
 
 // PollDevice polls the device authorization endpoint for the given code.
 func (c *Client) PollDevice(code string) (*PollResponse, error) {
+	return c.PollDeviceContext(context.Background(), code)
+}
+
+// PollDeviceContext is like PollDevice but uses ctx for the underlying request.
+func (c *Client) PollDeviceContext(ctx context.Context, code string) (*PollResponse, error) {
 	path := fmt.Sprintf("/auth/device/poll?code=%s", code)
 
-	body, err := c.makeRequest("GET", path, nil)
+	body, err := c.makeRequest(ctx, "GET", path, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to poll device auth: %w", err)
 	}
@@ -563,14 +812,54 @@ func (c *Client) PollDevice(code string) (*PollResponse, error) {
 	return &resp, nil
 }
 
+// TokenInfo returns the account, organization, scopes and expiry of the API
+// token the client authenticates with.
+func (c *Client) TokenInfo() (*TokenInfo, error) {
+	return c.TokenInfoContext(context.Background())
+}
+
+// TokenInfoContext is like TokenInfo but uses ctx for the underlying request.
+func (c *Client) TokenInfoContext(ctx context.Context) (*TokenInfo, error) {
+	body, err := c.makeRequest(ctx, "GET", "/auth/token", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get token info: %w", err)
+	}
+
+	info, err := unwrapData[TokenInfo](body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode token info: %w", err)
+	}
+
+	return &info, nil
+}
+
+// RevokeToken revokes the API token the client authenticates with. Further
+// requests made with it fail with 401 Unauthorized.
+func (c *Client) RevokeToken() error {
+	return c.RevokeTokenContext(context.Background())
+}
+
+// RevokeTokenContext is like RevokeToken but uses ctx for the underlying request.
+func (c *Client) RevokeTokenContext(ctx context.Context) error {
+	if _, err := c.makeRequest(ctx, "DELETE", "/auth/token", nil); err != nil {
+		return fmt.Errorf("failed to revoke token: %w", err)
+	}
+	return nil
+}
+
 // SecretsCreate creates a new secret.
 func (c *Client) SecretsCreate(req CreateSecretRequest) (*Secret, error) {
+	return c.SecretsCreateContext(context.Background(), req)
+}
+
+// SecretsCreateContext is like SecretsCreate but uses ctx for the underlying request.
+func (c *Client) SecretsCreateContext(ctx context.Context, req CreateSecretRequest) (*Secret, error) {
 	reqBody, err := json.Marshal(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	body, err := c.makeRequest("POST", "/secrets", bytes.NewReader(reqBody))
+	body, err := c.makeRequest(ctx, "POST", "/secrets", bytes.NewReader(reqBody), withIdempotencyKey())
 	if err != nil {
 		return nil, fmt.Errorf("failed to create secret: %w", err)
 	}
@@ -583,9 +872,21 @@ func (c *Client) SecretsCreate(req CreateSecretRequest) (*Secret, error) {
 	return &secret, nil
 }
 
-// SecretsList lists all secrets.
+// SecretsList lists the first page of secrets. Use AllSecrets to walk every
+// page.
 func (c *Client) SecretsList() (*ListSecretsResponse, error) {
-	body, err := c.makeRequest("GET", "/secrets", nil)
+	return c.SecretsListContext(context.Background())
+}
+
+// SecretsListContext is like SecretsList but uses ctx for the underlying request.
+func (c *Client) SecretsListContext(ctx context.Context) (*ListSecretsResponse, error) {
+	return c.SecretsListPageContext(ctx, ListOptions{})
+}
+
+// SecretsListPageContext fetches a single page of secrets starting at
+// opts.Cursor. Use AllSecrets to walk every page.
+func (c *Client) SecretsListPageContext(ctx context.Context, opts ListOptions) (*ListSecretsResponse, error) {
+	body, err := c.makeRequest(ctx, "GET", opts.path("/secrets"), nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list secrets: %w", err)
 	}
@@ -600,11 +901,16 @@ func (c *Client) SecretsList() (*ListSecretsResponse, error) {
 
 // SecretsListByName lists secrets filtered by name.
 func (c *Client) SecretsListByName(name string) (*ListSecretsResponse, error) {
+	return c.SecretsListByNameContext(context.Background(), name)
+}
+
+// SecretsListByNameContext is like SecretsListByName but uses ctx for the underlying request.
+func (c *Client) SecretsListByNameContext(ctx context.Context, name string) (*ListSecretsResponse, error) {
 	q := url.Values{}
 	q.Set("name", name)
 	path := "/secrets?" + q.Encode()
 
-	body, err := c.makeRequest("GET", path, nil)
+	body, err := c.makeRequest(ctx, "GET", path, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list secrets by name: %w", err)
 	}
@@ -619,9 +925,14 @@ func (c *Client) SecretsListByName(name string) (*ListSecretsResponse, error) {
 
 // SecretsGet retrieves a single secret by ID.
 func (c *Client) SecretsGet(id string) (*Secret, error) {
+	return c.SecretsGetContext(context.Background(), id)
+}
+
+// SecretsGetContext is like SecretsGet but uses ctx for the underlying request.
+func (c *Client) SecretsGetContext(ctx context.Context, id string) (*Secret, error) {
 	path := fmt.Sprintf("/secrets/%s", id)
 
-	body, err := c.makeRequest("GET", path, nil)
+	body, err := c.makeRequest(ctx, "GET", path, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get secret: %w", err)
 	}
@@ -636,13 +947,18 @@ func (c *Client) SecretsGet(id string) (*Secret, error) {
 
 // SecretsUpdate updates a secret by ID.
 func (c *Client) SecretsUpdate(id string, req UpdateSecretRequest) (*Secret, error) {
+	return c.SecretsUpdateContext(context.Background(), id, req)
+}
+
+// SecretsUpdateContext is like SecretsUpdate but uses ctx for the underlying request.
+func (c *Client) SecretsUpdateContext(ctx context.Context, id string, req UpdateSecretRequest) (*Secret, error) {
 	reqBody, err := json.Marshal(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
 	path := fmt.Sprintf("/secrets/%s", id)
-	body, err := c.makeRequest("PATCH", path, bytes.NewReader(reqBody))
+	body, err := c.makeRequest(ctx, "PATCH", path, bytes.NewReader(reqBody))
 	if err != nil {
 		return nil, fmt.Errorf("failed to update secret: %w", err)
 	}
@@ -657,9 +973,14 @@ func (c *Client) SecretsUpdate(id string, req UpdateSecretRequest) (*Secret, err
 
 // SecretsDelete deletes a secret by ID.
 func (c *Client) SecretsDelete(id string) error {
+	return c.SecretsDeleteContext(context.Background(), id)
+}
+
+// SecretsDeleteContext is like SecretsDelete but uses ctx for the underlying request.
+func (c *Client) SecretsDeleteContext(ctx context.Context, id string) error {
 	path := fmt.Sprintf("/secrets/%s", id)
 
-	_, err := c.makeRequest("DELETE", path, nil)
+	_, err := c.makeRequest(ctx, "DELETE", path, nil)
 	if err != nil {
 		return fmt.Errorf("failed to delete secret: %w", err)
 	}
@@ -667,66 +988,148 @@ func (c *Client) SecretsDelete(id string) error {
 	return nil
 }
 
-// ResolveSecret resolves a secret identifier to a secret ID. If idOrName
-// starts with "sec_" it is returned as-is. Otherwise the value is treated as
-// a name: the list secrets endpoint is queried with that name and the first
-// match is returned.
+// ResolveSecret resolves a secret identifier to a secret ID. If idOrName is
+// the ID of an existing secret it is returned as-is. Otherwise the value is matched
+// against the account's secrets in the same way as ResolveVM: by exact name,
+// then by unique prefix of a name or ID, returning an *AmbiguousError or
+// *NotFoundError when that fails.
 func (c *Client) ResolveSecret(idOrName string) (string, error) {
+	return c.ResolveSecretContext(context.Background(), idOrName)
+}
+
+// ResolveSecretContext is like ResolveSecret but uses ctx for any lookup requests.
+func (c *Client) ResolveSecretContext(ctx context.Context, idOrName string) (string, error) {
 	if strings.HasPrefix(idOrName, "sec_") {
-		return idOrName, nil
+		secret, err := c.SecretsGetContext(ctx, idOrName)
+		if err == nil {
+			return secret.ID, nil
+		}
+		if !IsNotFound(err) {
+			return "", fmt.Errorf("resolving secret ID %q: %w", idOrName, err)
+		}
 	}
 
-	resp, err := c.SecretsListByName(idOrName)
+	resp, err := c.SecretsListByNameContext(ctx, idOrName)
 	if err != nil {
 		return "", fmt.Errorf("resolving secret name %q: %w", idOrName, err)
 	}
-
-	if len(resp.Data) == 0 {
-		return "", fmt.Errorf("no secret found with name %q", idOrName)
+	var exact []Candidate
+	for _, s := range resp.Data {
+		if s.Name == idOrName {
+			exact = append(exact, secretCandidate(s))
+		}
+	}
+	if len(exact) > 0 {
+		return pickCandidate("secret", idOrName, exact)
 	}
 
-	return resp.Data[0].ID, nil
+	var all []Candidate
+	for s, err := range c.AllSecrets(ctx) {
+		if err != nil {
+			return "", fmt.Errorf("resolving secret name %q: %w", idOrName, err)
+		}
+		all = append(all, secretCandidate(s))
+	}
+	return resolvePrefix("secret", "sec_", idOrName, all)
 }
 
-// makeRequest makes an HTTP request with common headers and error handling
-func (c *Client) makeRequest(method, path string, body io.Reader) ([]byte, error) {
-	reqURL := c.BaseURL + path
-
-	// Buffer the body so we can both log it and send it.
+// makeRequest makes an HTTP request with common headers and error handling.
+// The request is bound to ctx, so cancelling ctx aborts an in-flight call.
+// Failed attempts are retried according to c.Retry.
+func (c *Client) makeRequest(ctx context.Context, method, path string, body io.Reader, opts ...requestOption) ([]byte, error) {
+	// Buffer the body so we can log it and replay it on retries.
 	var reqBytes []byte
-	if body != nil && c.Debug {
+	if body != nil {
 		var err error
 		reqBytes, err = io.ReadAll(body)
 		if err != nil {
 			return nil, fmt.Errorf("failed to read request body: %w", err)
 		}
-		body = bytes.NewReader(reqBytes)
 	}
 
-	if c.Debug {
-		fmt.Fprintf(os.Stderr, ">>> %s %s\n", method, path)
-		if len(reqBytes) > 0 {
-			fmt.Fprintf(os.Stderr, "%s\n", reqBytes)
+	header := http.Header{}
+	for _, opt := range opts {
+		opt(header)
+	}
+
+	// Ask the token source once per request rather than per attempt, so a
+	// failing credential helper is not retried like a network error.
+	if c.Credentials != nil {
+		apiKey, err := c.Credentials.Token(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("getting API key: %w", err)
+		}
+		header.Set("Authorization", "Bearer "+apiKey)
+	}
+
+	for attempt := 0; ; attempt++ {
+		respBody, err := c.doRequest(ctx, method, path, reqBytes, header)
+		if err == nil {
+			return respBody, nil
+		}
+		if attempt >= c.Retry.MaxRetries || !shouldRetry(ctx, method, header, err) {
+			return nil, err
 		}
+
+		wait := c.Retry.backoff(attempt, err)
+		if c.Debug {
+			fmt.Fprintf(os.Stderr, "!!! %s %s failed (%v); retry %d/%d in %s\n",
+				method, path, err, attempt+1, c.Retry.MaxRetries, wait.Round(time.Millisecond))
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, err
+		case <-timer.C:
+		}
+	}
+}
+
+// doRequest performs a single attempt of a request built by makeRequest.
+func (c *Client) doRequest(ctx context.Context, method, path string, reqBytes []byte, header http.Header) ([]byte, error) {
+	reqURL := c.BaseURL + path
+
+	var body io.Reader
+	if reqBytes != nil {
+		body = bytes.NewReader(reqBytes)
 	}
 
-	req, err := http.NewRequest(method, reqURL, body)
+	req, err := http.NewRequestWithContext(ctx, method, reqURL, body)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
+	for k, v := range header {
+		req.Header[k] = v
+	}
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Accept", "application/json")
-	if c.APIKey != "" {
+	req.Header.Set(APIVersionHeader, APIVersion)
+	if c.UserAgent != "" {
+		req.Header.Set("User-Agent", c.UserAgent)
+	}
+	if c.APIKey != "" && req.Header.Get("Authorization") == "" {
 		req.Header.Set("Authorization", "Bearer "+c.APIKey)
 	}
 
+	if c.Debug {
+		c.logRequest(method, path, req.Header, reqBytes)
+	}
+
 	resp, err := c.HTTPClient.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to make request: %w", err)
 	}
 	defer resp.Body.Close()
 
+	if c.Notices != nil {
+		if n, ok := deprecationNotice(method, req.URL.Path, resp.Header); ok && c.noticed.CompareAndSwap(false, true) {
+			fmt.Fprintf(c.Notices, "Warning: %s\n", n)
+		}
+	}
+
 	// Check for no-content response
 	if resp.StatusCode == http.StatusNoContent {
 		if c.Debug {
@@ -742,19 +1145,12 @@ func (c *Client) makeRequest(method, path string, body io.Reader) ([]byte, error
 	}
 
 	if c.Debug {
-		fmt.Fprintf(os.Stderr, "<<< %d\n%s\n", resp.StatusCode, respBody)
+		c.logResponse(resp.StatusCode, respBody)
 	}
 
 	// Check for HTTP errors
 	if resp.StatusCode >= 400 {
-		// Try to parse as JSON error response
-		var errResp ErrorResponse
-		if err := json.Unmarshal(respBody, &errResp); err == nil && errResp.Error.Message != "" {
-			return nil, fmt.Errorf("API error: %s", errResp.Error.Message)
-		}
-
-		// Fallback to raw body if JSON parsing fails
-		return nil, fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(respBody))
+		return nil, newAPIError(resp, respBody)
 	}
 
 	return respBody, nil
@@ -0,0 +1,120 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+)
+
+// redacted replaces sensitive values in debug output.
+const redacted = "[REDACTED]"
+
+// sensitiveFields lists JSON object keys whose values are masked in debug
+// output: secret values sent by `irons secrets add/update`, the proxy values
+// returned for them, and API tokens issued by the device authorization flow.
+var sensitiveFields = []string{
+	"secret",
+	"proxy_value",
+	"token",
+	"api_key",
+	"access_token",
+	"refresh_token",
+	"password",
+	"private_key",
+}
+
+// sensitiveHeaders lists request headers whose values are masked in debug
+// output.
+var sensitiveHeaders = []string{
+	"Authorization",
+	"Cookie",
+}
+
+// logRequest writes a request line, headers and body to stderr.
+func (c *Client) logRequest(method, path string, header http.Header, body []byte) {
+	fmt.Fprintf(os.Stderr, ">>> %s %s\n", method, path)
+
+	keys := make([]string, 0, len(header))
+	for k := range header {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	for _, k := range keys {
+		v := strings.Join(header[k], ", ")
+		if !c.DebugUnsafe && slices.Contains(sensitiveHeaders, http.CanonicalHeaderKey(k)) {
+			v = redactHeader(v)
+		}
+		fmt.Fprintf(os.Stderr, "%s: %s\n", k, v)
+	}
+
+	if len(body) > 0 {
+		fmt.Fprintf(os.Stderr, "%s\n", c.debugBody(body))
+	}
+}
+
+// logResponse writes a response status and body to stderr.
+func (c *Client) logResponse(status int, body []byte) {
+	fmt.Fprintf(os.Stderr, "<<< %d\n%s\n", status, c.debugBody(body))
+}
+
+// debugBody returns body with sensitive JSON fields masked, unless the client
+// is in unsafe debug mode.
+func (c *Client) debugBody(body []byte) []byte {
+	if c.DebugUnsafe {
+		return body
+	}
+	return redactJSON(body)
+}
+
+// redactJSON returns a copy of a JSON document with the values of sensitive
+// fields replaced, at any depth. Bodies that are not valid JSON are returned
+// unchanged.
+func redactJSON(body []byte) []byte {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return body
+	}
+
+	out, err := json.Marshal(redactValue(v))
+	if err != nil {
+		return body
+	}
+	return out
+}
+
+// redactValue walks a decoded JSON value, masking sensitive object fields.
+func redactValue(v any) any {
+	switch t := v.(type) {
+	case map[string]any:
+		for k, val := range t {
+			if slices.Contains(sensitiveFields, strings.ToLower(k)) {
+				if s, ok := val.(string); !ok || s != "" {
+					t[k] = redacted
+				}
+				continue
+			}
+			t[k] = redactValue(val)
+		}
+	case []any:
+		for i, val := range t {
+			t[i] = redactValue(val)
+		}
+	}
+	return v
+}
+
+// redactHeader masks a header value, keeping the auth scheme (e.g. "Bearer")
+// so the output still shows which kind of credential was sent.
+func redactHeader(v string) string {
+	if scheme, _, ok := strings.Cut(v, " "); ok {
+		return scheme + " " + redacted
+	}
+	return redacted
+}
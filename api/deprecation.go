@@ -0,0 +1,89 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DeprecationNotice describes the Deprecation, Sunset and Warning headers the
+// server sent with a response.
+type DeprecationNotice struct {
+	Method string
+	Path   string
+	// Deprecated is set when the Deprecation header marks the endpoint as
+	// deprecated. DeprecatedAt is when, if the header gives a date.
+	Deprecated   bool
+	DeprecatedAt time.Time
+	// Sunset is when the endpoint will stop working, if announced.
+	Sunset time.Time
+	// Warning is the text of the first Warning header.
+	Warning string
+}
+
+// String returns the notice as a single line, e.g. "GET /vms is deprecated
+// and will be removed on 2026-12-01: use /v2/vms".
+func (n DeprecationNotice) String() string {
+	if !n.Deprecated && n.Sunset.IsZero() {
+		return n.Warning
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %s is deprecated", n.Method, n.Path)
+	if !n.Sunset.IsZero() {
+		fmt.Fprintf(&b, " and will be removed on %s", n.Sunset.UTC().Format(time.DateOnly))
+	}
+	if n.Warning != "" {
+		fmt.Fprintf(&b, ": %s", n.Warning)
+	}
+	b.WriteString("; upgrade irons or check the release notes")
+	return b.String()
+}
+
+// deprecationNotice builds a notice from the response headers, reporting
+// false if the response carried none of them.
+func deprecationNotice(method, path string, h http.Header) (DeprecationNotice, bool) {
+	n := DeprecationNotice{Method: method, Path: path}
+
+	if v := strings.TrimSpace(h.Get("Deprecation")); v != "" && v != "false" {
+		n.Deprecated = true
+		n.DeprecatedAt = parseHeaderDate(v)
+	}
+	if v := h.Get("Sunset"); v != "" {
+		n.Sunset = parseHeaderDate(v)
+	}
+	if v := h.Get("Warning"); v != "" {
+		n.Warning = warningText(v)
+	}
+
+	return n, n.Deprecated || !n.Sunset.IsZero() || n.Warning != ""
+}
+
+// parseHeaderDate parses an HTTP date or an RFC 9651 date ("@1767225600"),
+// returning the zero time for anything else, such as "true".
+func parseHeaderDate(v string) time.Time {
+	if unix, ok := strings.CutPrefix(v, "@"); ok {
+		if sec, err := strconv.ParseInt(unix, 10, 64); err == nil {
+			return time.Unix(sec, 0)
+		}
+		return time.Time{}
+	}
+	t, _ := http.ParseTime(v)
+	return t
+}
+
+// warningText extracts the quoted text from a Warning header value such as
+// `299 - "Deprecated endpoint"`, falling back to the raw value.
+func warningText(v string) string {
+	start := strings.IndexByte(v, '"')
+	if start < 0 {
+		return strings.TrimSpace(v)
+	}
+	end := strings.IndexByte(v[start+1:], '"')
+	if end < 0 {
+		return strings.TrimSpace(v)
+	}
+	return v[start+1 : start+1+end]
+}
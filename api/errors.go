@@ -0,0 +1,88 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// APIError is returned by Client methods when the API responds with an HTTP
+// status of 400 or above. Use errors.As to inspect it, or one of the Is*
+// helpers for the common cases.
+type APIError struct {
+	// StatusCode is the HTTP status code of the response.
+	StatusCode int
+	// Code is the machine-readable error code from the response body, if any.
+	Code string
+	// Message is the human-readable error message from the response body, if any.
+	Message string
+	// RequestID identifies the request for support, taken from the response
+	// body or the X-Request-Id header.
+	RequestID string
+	// RetryAfter is the delay requested by the server's Retry-After header,
+	// or zero if none was sent.
+	RetryAfter time.Duration
+	// Body is the raw response body, kept for responses that are not in the
+	// standard error format.
+	Body []byte
+}
+
+// Error implements the error interface.
+func (e *APIError) Error() string {
+	if e.Message != "" {
+		return fmt.Sprintf("API error: %s", e.Message)
+	}
+	return fmt.Sprintf("API request failed with status %d: %s", e.StatusCode, string(e.Body))
+}
+
+// newAPIError builds an APIError from a failed response and its body.
+func newAPIError(resp *http.Response, body []byte) *APIError {
+	apiErr := &APIError{
+		StatusCode: resp.StatusCode,
+		RequestID:  resp.Header.Get("X-Request-Id"),
+		RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+		Body:       body,
+	}
+
+	var errResp ErrorResponse
+	if err := json.Unmarshal(body, &errResp); err == nil {
+		apiErr.Code = errResp.Error.Code
+		apiErr.Message = errResp.Error.Message
+		if errResp.Error.RequestID != "" {
+			apiErr.RequestID = errResp.Error.RequestID
+		}
+	}
+
+	return apiErr
+}
+
+// hasStatus reports whether err wraps an APIError with the given status code.
+func hasStatus(err error, status int) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == status
+}
+
+// IsNotFound reports whether err is an API 404 Not Found error, or a
+// *NotFoundError from resolving a name.
+func IsNotFound(err error) bool {
+	return hasStatus(err, http.StatusNotFound) || isResolveNotFound(err)
+}
+
+// IsConflict reports whether err is an API 409 Conflict error, e.g. when a VM
+// is not in the right state for the requested operation.
+func IsConflict(err error) bool {
+	return hasStatus(err, http.StatusConflict)
+}
+
+// IsUnauthorized reports whether err is an API 401 Unauthorized error, i.e.
+// the API key is missing, invalid or revoked.
+func IsUnauthorized(err error) bool {
+	return hasStatus(err, http.StatusUnauthorized)
+}
+
+// IsForbidden reports whether err is an API 403 Forbidden error.
+func IsForbidden(err error) bool {
+	return hasStatus(err, http.StatusForbidden)
+}
@@ -0,0 +1,143 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"net/url"
+)
+
+// ListOptions contains the pagination parameters accepted by the list
+// endpoints. A zero value requests the first page with the server's default
+// page size.
+type ListOptions struct {
+	Cursor string
+	Limit  int
+}
+
+// path appends the encoded pagination parameters to base.
+func (o ListOptions) path(base string) string {
+	q := url.Values{}
+	if o.Cursor != "" {
+		q.Set("cursor", o.Cursor)
+	}
+	if o.Limit > 0 {
+		q.Set("limit", fmt.Sprintf("%d", o.Limit))
+	}
+	if encoded := q.Encode(); encoded != "" {
+		return base + "?" + encoded
+	}
+	return base
+}
+
+// page is a single page of results normalised across the list responses,
+// which disagree on whether the cursor is a string or a pointer.
+type page[T any] struct {
+	items   []T
+	hasMore bool
+	cursor  string
+}
+
+// paginate walks every page returned by fetch, starting at cursor start, and
+// yields each item in order. Iteration stops after the first error, when the
+// server reports no further pages, or when the consumer breaks out of the
+// loop; no page is requested beyond the one being consumed.
+func paginate[T any](ctx context.Context, start string, fetch func(ctx context.Context, cursor string) (page[T], error)) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		cursor := start
+		for {
+			p, err := fetch(ctx, cursor)
+			if err != nil {
+				var zero T
+				yield(zero, err)
+				return
+			}
+			for _, item := range p.items {
+				if !yield(item, nil) {
+					return
+				}
+			}
+			// Guard against a server that keeps returning the same cursor.
+			if !p.hasMore || p.cursor == "" || p.cursor == cursor {
+				return
+			}
+			cursor = p.cursor
+		}
+	}
+}
+
+// derefCursor returns the cursor value, or "" if it is nil.
+func derefCursor(cursor *string) string {
+	if cursor == nil {
+		return ""
+	}
+	return *cursor
+}
+
+// AllVMs returns an iterator over every VM on the account, fetching further
+// pages on demand.
+func (c *Client) AllVMs(ctx context.Context) iter.Seq2[VM, error] {
+	return paginate(ctx, "", func(ctx context.Context, cursor string) (page[VM], error) {
+		resp, err := c.ListVMsPageContext(ctx, ListOptions{Cursor: cursor})
+		if err != nil {
+			return page[VM]{}, err
+		}
+		return page[VM]{resp.Data, resp.HasMore, derefCursor(resp.Cursor)}, nil
+	})
+}
+
+// AllEgressRules returns an iterator over every egress rule on the account,
+// fetching further pages on demand.
+func (c *Client) AllEgressRules(ctx context.Context) iter.Seq2[EgressRule, error] {
+	return paginate(ctx, "", func(ctx context.Context, cursor string) (page[EgressRule], error) {
+		resp, err := c.EgressListRulesPageContext(ctx, ListOptions{Cursor: cursor})
+		if err != nil {
+			return page[EgressRule]{}, err
+		}
+		return page[EgressRule]{resp.Data, resp.HasMore, derefCursor(resp.Cursor)}, nil
+	})
+}
+
+// AllSecrets returns an iterator over every secret on the account, fetching
+// further pages on demand.
+func (c *Client) AllSecrets(ctx context.Context) iter.Seq2[Secret, error] {
+	return paginate(ctx, "", func(ctx context.Context, cursor string) (page[Secret], error) {
+		resp, err := c.SecretsListPageContext(ctx, ListOptions{Cursor: cursor})
+		if err != nil {
+			return page[Secret]{}, err
+		}
+		return page[Secret]{resp.Data, resp.HasMore, derefCursor(resp.Cursor)}, nil
+	})
+}
+
+// AllAuditEgress returns an iterator over every egress audit event matching
+// params, fetching further pages on demand. params.Cursor, if set, is used as
+// the starting point; params.Limit is passed through as the page size.
+func (c *Client) AllAuditEgress(ctx context.Context, params AuditEgressParams) iter.Seq2[EgressAuditEvent, error] {
+	return paginate(ctx, params.Cursor, func(ctx context.Context, cursor string) (page[EgressAuditEvent], error) {
+		p := params
+		p.Cursor = cursor
+		resp, err := c.AuditEgressContext(ctx, p)
+		if err != nil {
+			return page[EgressAuditEvent]{}, err
+		}
+		return page[EgressAuditEvent]{resp.Data, resp.HasMore, resp.Cursor}, nil
+	})
+}
+
+// Collect drains seq into a slice, stopping after limit items when limit is
+// positive. The first error encountered is returned along with the items
+// collected so far.
+func Collect[T any](seq iter.Seq2[T, error], limit int) ([]T, error) {
+	var out []T
+	for item, err := range seq {
+		if err != nil {
+			return out, err
+		}
+		out = append(out, item)
+		if limit > 0 && len(out) >= limit {
+			break
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,169 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"text/tabwriter"
+)
+
+// maxSuggestions is the number of "did you mean" names offered when a name
+// cannot be resolved.
+const maxSuggestions = 3
+
+// Candidate is a resource considered while resolving a name.
+type Candidate struct {
+	ID        string
+	Name      string
+	Status    string
+	CreatedAt string
+}
+
+// AmbiguousError is returned by ResolveVM and ResolveSecret when a name or
+// prefix matches more than one resource.
+type AmbiguousError struct {
+	// Kind is the resource kind, "VM" or "secret".
+	Kind string
+	// Query is the name or prefix that was being resolved.
+	Query string
+	// Candidates lists every matching resource.
+	Candidates []Candidate
+}
+
+// Error implements the error interface. The message lists each candidate on
+// its own line so the user can pick one by ID.
+func (e *AmbiguousError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %q is ambiguous; it matches %d %ss:\n", e.Kind, e.Query, len(e.Candidates), e.Kind)
+
+	tw := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	for _, c := range e.Candidates {
+		fmt.Fprintf(tw, "  %s\t%s\t", c.ID, c.Name)
+		if c.Status != "" {
+			fmt.Fprintf(tw, "%s\t", c.Status)
+		}
+		fmt.Fprintf(tw, "created %s\n", c.CreatedAt)
+	}
+	tw.Flush()
+
+	fmt.Fprintf(&b, "Specify the %s by ID instead.", e.Kind)
+	return b.String()
+}
+
+// NotFoundError is returned by ResolveVM and ResolveSecret when nothing
+// matches the given name.
+type NotFoundError struct {
+	// Kind is the resource kind, "VM" or "secret".
+	Kind string
+	// Query is the name that was being resolved.
+	Query string
+	// Suggestions lists existing names similar to Query, closest first.
+	Suggestions []string
+}
+
+// Error implements the error interface.
+func (e *NotFoundError) Error() string {
+	noun := e.Kind
+	if noun == "VM" {
+		// Destroyed VMs are never resolved, so say so.
+		noun = "active VM"
+	}
+	msg := fmt.Sprintf("no %s found with name %q", noun, e.Query)
+	if len(e.Suggestions) > 0 {
+		quoted := make([]string, len(e.Suggestions))
+		for i, s := range e.Suggestions {
+			quoted[i] = fmt.Sprintf("%q", s)
+		}
+		msg += "; did you mean " + strings.Join(quoted, " or ") + "?"
+	}
+	return msg
+}
+
+// isResolveNotFound reports whether err wraps a NotFoundError.
+func isResolveNotFound(err error) bool {
+	var nf *NotFoundError
+	return errors.As(err, &nf)
+}
+
+func vmCandidate(vm VM) Candidate {
+	return Candidate{ID: vm.ID, Name: vm.Name, Status: vm.Status, CreatedAt: vm.CreatedAt}
+}
+
+func secretCandidate(s Secret) Candidate {
+	return Candidate{ID: s.ID, Name: s.Name, CreatedAt: s.CreatedAt}
+}
+
+// pickCandidate returns the ID of the only candidate, or an AmbiguousError
+// if there are several.
+func pickCandidate(kind, query string, candidates []Candidate) (string, error) {
+	if len(candidates) == 1 {
+		return candidates[0].ID, nil
+	}
+	return "", &AmbiguousError{Kind: kind, Query: query, Candidates: candidates}
+}
+
+// resolvePrefix resolves query against all by unique prefix of a name or of
+// an ID, with or without idPrefix. When nothing matches, the closest names are
+// suggested.
+func resolvePrefix(kind, idPrefix, query string, all []Candidate) (string, error) {
+	var matches []Candidate
+	for _, c := range all {
+		if strings.HasPrefix(c.Name, query) || strings.HasPrefix(c.ID, query) || strings.HasPrefix(strings.TrimPrefix(c.ID, idPrefix), query) {
+			matches = append(matches, c)
+		}
+	}
+	if len(matches) > 0 {
+		return pickCandidate(kind, query, matches)
+	}
+	return "", &NotFoundError{Kind: kind, Query: query, Suggestions: suggest(query, all)}
+}
+
+// suggest returns up to maxSuggestions distinct candidate names that are
+// within a small edit distance of query, closest first.
+func suggest(query string, all []Candidate) []string {
+	type scored struct {
+		name string
+		dist int
+	}
+	threshold := max(2, len(query)/3)
+
+	var near []scored
+	for _, c := range all {
+		if c.Name == "" || slices.ContainsFunc(near, func(s scored) bool { return s.name == c.Name }) {
+			continue
+		}
+		if d := levenshtein(strings.ToLower(query), strings.ToLower(c.Name)); d <= threshold {
+			near = append(near, scored{c.Name, d})
+		}
+	}
+	slices.SortStableFunc(near, func(a, b scored) int { return a.dist - b.dist })
+
+	var out []string
+	for _, s := range near[:min(len(near), maxSuggestions)] {
+		out = append(out, s.name)
+	}
+	return out
+}
+
+// levenshtein returns the edit distance between a and b.
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
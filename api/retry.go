@@ -0,0 +1,121 @@
+package api
+
+import (
+	"context"
+	crand "crypto/rand"
+	"errors"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy controls how Client retries failed requests. Network errors,
+// 5xx responses and 429 Too Many Requests are retried with jittered
+// exponential backoff. A Retry-After header on the response takes precedence
+// over the computed backoff.
+//
+// Requests that are not idempotent (POST and PATCH) are only retried after a
+// network error or 5xx response if they carry an Idempotency-Key header, so a
+// retry can never create a duplicate resource. A 429 is always safe to retry
+// because the server rejected the request without processing it.
+type RetryPolicy struct {
+	// MaxRetries is the number of retries after the initial attempt. Zero
+	// disables retries.
+	MaxRetries int
+	// MinBackoff is the backoff before the first retry.
+	MinBackoff time.Duration
+	// MaxBackoff caps the computed backoff and any server-sent Retry-After.
+	MaxBackoff time.Duration
+}
+
+// DefaultRetryPolicy returns the retry policy used by NewClient.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxRetries: 3,
+		MinBackoff: 500 * time.Millisecond,
+		MaxBackoff: 30 * time.Second,
+	}
+}
+
+// backoff returns how long to wait before retry number attempt+1 after err.
+func (p RetryPolicy) backoff(attempt int, err error) time.Duration {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.RetryAfter > 0 {
+		return min(apiErr.RetryAfter, p.MaxBackoff)
+	}
+
+	d := p.MinBackoff << attempt
+	if d <= 0 || d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+	if d <= 0 {
+		return 0
+	}
+	// Equal jitter: wait at least half the backoff so retries stay spaced
+	// out, and randomise the rest so clients don't retry in lockstep.
+	half := d / 2
+	return half + rand.N(d-half+1)
+}
+
+// shouldRetry reports whether a request that failed with err may be retried.
+func shouldRetry(ctx context.Context, method string, header http.Header, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		if apiErr.StatusCode == http.StatusTooManyRequests {
+			return true
+		}
+		if apiErr.StatusCode < 500 {
+			return false
+		}
+	}
+
+	// Network error or 5xx: the request may have been processed.
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete:
+		return true
+	default:
+		return header.Get("Idempotency-Key") != ""
+	}
+}
+
+// parseRetryAfter parses a Retry-After header value, given either as a number
+// of seconds or as an HTTP date. It returns zero if the value is absent or
+// invalid.
+func parseRetryAfter(v string) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+// requestOption customises the headers of a request made by makeRequest.
+// Options are applied once, so every retry of a request sends the same
+// headers.
+type requestOption func(http.Header)
+
+// withIdempotencyKey attaches a fresh Idempotency-Key to the request so that
+// the server can deduplicate retries of a create call.
+func withIdempotencyKey() requestOption {
+	key := newIdempotencyKey()
+	return func(h http.Header) {
+		h.Set("Idempotency-Key", key)
+	}
+}
+
+// newIdempotencyKey returns a random key with at least 128 bits of entropy.
+func newIdempotencyKey() string {
+	return crand.Text()
+}
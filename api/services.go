@@ -0,0 +1,83 @@
+package api
+
+import (
+	"context"
+	"iter"
+)
+
+// VMService is the subset of the API that manages VMs. It is satisfied by
+// *Client and can be implemented by fakes in tests.
+type VMService interface {
+	CreateContext(ctx context.Context, key []byte, name string) (*VM, error)
+	CreateVMContext(ctx context.Context, req CreateRequest) (*VM, error)
+	GetVMContext(ctx context.Context, id string) (*VM, error)
+	UpdateVMContext(ctx context.Context, id string, req UpdateVMRequest) (*VM, error)
+	ListVMsPageContext(ctx context.Context, opts ListOptions) (*ListVMsResponse, error)
+	ListVMsByNameContext(ctx context.Context, name string) (*ListVMsResponse, error)
+	AllVMs(ctx context.Context) iter.Seq2[VM, error]
+	ResolveVMContext(ctx context.Context, idOrName string) (string, error)
+	SSHContext(ctx context.Context, id string) (*SSHResponse, error)
+	StartContext(ctx context.Context, id string) (*VM, error)
+	StopContext(ctx context.Context, id string) (*VM, error)
+	DestroyContext(ctx context.Context, id string) error
+}
+
+// EgressService is the subset of the API that manages account-wide and
+// per-VM egress policy and rules.
+type EgressService interface {
+	EgressGetPolicyContext(ctx context.Context) (*EgressModeResponse, error)
+	EgressSetPolicyContext(ctx context.Context, mode string) error
+	EgressListRulesPageContext(ctx context.Context, opts ListOptions) (*ListEgressRulesResponse, error)
+	AllEgressRules(ctx context.Context) iter.Seq2[EgressRule, error]
+	EgressCreateRuleContext(ctx context.Context, req EgressRuleRequest) (*EgressRule, error)
+	EgressDeleteRuleContext(ctx context.Context, id string) error
+	VMEgressGetPolicyContext(ctx context.Context, vmID string) (*EgressModeResponse, error)
+	VMEgressSetPolicyContext(ctx context.Context, vmID, mode string) error
+}
+
+// SecretsService is the subset of the API that manages secrets.
+type SecretsService interface {
+	SecretsCreateContext(ctx context.Context, req CreateSecretRequest) (*Secret, error)
+	SecretsListPageContext(ctx context.Context, opts ListOptions) (*ListSecretsResponse, error)
+	SecretsListByNameContext(ctx context.Context, name string) (*ListSecretsResponse, error)
+	AllSecrets(ctx context.Context) iter.Seq2[Secret, error]
+	SecretsGetContext(ctx context.Context, id string) (*Secret, error)
+	SecretsUpdateContext(ctx context.Context, id string, req UpdateSecretRequest) (*Secret, error)
+	SecretsDeleteContext(ctx context.Context, id string) error
+	ResolveSecretContext(ctx context.Context, idOrName string) (string, error)
+}
+
+// AuditService is the subset of the API that reads audit logs.
+type AuditService interface {
+	AuditEgressContext(ctx context.Context, params AuditEgressParams) (*ListAuditEgressResponse, error)
+	AllAuditEgress(ctx context.Context, params AuditEgressParams) iter.Seq2[EgressAuditEvent, error]
+}
+
+// AuthService is the subset of the API that implements the device
+// authorization flow used by `irons login` and inspects or revokes the
+// current token.
+type AuthService interface {
+	DeviceCodeContext(ctx context.Context) (*DeviceCodeResponse, error)
+	PollDeviceContext(ctx context.Context, code string) (*PollResponse, error)
+	TokenInfoContext(ctx context.Context) (*TokenInfo, error)
+	RevokeTokenContext(ctx context.Context) error
+}
+
+// VersionService is the subset of the API that reports which CLI versions
+// the server supports.
+type VersionService interface {
+	CLIVersionContext(ctx context.Context) (*CLIVersionResponse, error)
+}
+
+// Services combines every service interface. It is what the irons commands
+// depend on, so a fake implementing Services can stand in for *Client.
+type Services interface {
+	VMService
+	EgressService
+	SecretsService
+	AuditService
+	AuthService
+	VersionService
+}
+
+var _ Services = (*Client)(nil)
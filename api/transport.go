@@ -0,0 +1,113 @@
+package api
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// TransportOptions configures the TLS and proxy behaviour of the HTTP
+// transport built by NewTransport. The zero value gives the same behaviour
+// as http.DefaultTransport.
+type TransportOptions struct {
+	// CACertFile is a PEM bundle of additional certificate authorities to
+	// trust alongside the system roots, e.g. a corporate CA.
+	CACertFile string
+	// ClientCertFile and ClientKeyFile are a PEM certificate and private key
+	// presented to servers that require mutual TLS. Both or neither must be
+	// set.
+	ClientCertFile string
+	ClientKeyFile  string
+	// Proxy is the URL of the proxy all requests are sent through. When it
+	// is empty the HTTPS_PROXY, HTTP_PROXY and NO_PROXY environment variables
+	// are honoured instead. A URL without a scheme is assumed to be http.
+	Proxy string
+	// InsecureSkipVerify disables server certificate verification.
+	InsecureSkipVerify bool
+}
+
+// NewTransport returns an HTTP transport configured by opts. It starts from
+// a clone of http.DefaultTransport, so connection pooling, timeouts and
+// environment proxy settings are kept unless opts overrides them.
+func NewTransport(opts TransportOptions) (*http.Transport, error) {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.Proxy = http.ProxyFromEnvironment
+
+	if opts.Proxy != "" {
+		proxyURL, err := parseProxy(opts.Proxy)
+		if err != nil {
+			return nil, err
+		}
+		t.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: opts.InsecureSkipVerify,
+	}
+
+	if opts.CACertFile != "" {
+		pool, err := loadCACerts(opts.CACertFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if opts.ClientCertFile != "" || opts.ClientKeyFile != "" {
+		if opts.ClientCertFile == "" || opts.ClientKeyFile == "" {
+			return nil, errors.New("a client certificate and key must be given together")
+		}
+		cert, err := tls.LoadX509KeyPair(opts.ClientCertFile, opts.ClientKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("loading client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	t.TLSClientConfig = tlsConfig
+	return t, nil
+}
+
+// loadCACerts returns the system certificate pool with the PEM certificates
+// in path added to it.
+func loadCACerts(path string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading CA certificate: %w", err)
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no PEM certificates found in %s", path)
+	}
+	return pool, nil
+}
+
+// parseProxy parses a proxy URL, defaulting the scheme to http.
+func parseProxy(raw string) (*url.URL, error) {
+	if !strings.Contains(raw, "://") {
+		raw = "http://" + raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy URL: %w", err)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("invalid proxy URL %q: unsupported scheme %q", raw, u.Scheme)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("invalid proxy URL %q: missing host", raw)
+	}
+	return u, nil
+}
@@ -0,0 +1,110 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// APIVersion is the version of the API this client is written against. It
+// is sent with every request in the APIVersionHeader header so the server
+// keeps answering in the shape the client expects.
+const APIVersion = "2026-01-01"
+
+// APIVersionHeader is the request header that carries APIVersion.
+const APIVersionHeader = "Irons-API-Version"
+
+// UserAgent returns the User-Agent sent by the irons CLI at the given
+// version, e.g. "irons/1.4.0 (linux/amd64)".
+func UserAgent(version string) string {
+	return fmt.Sprintf("irons/%s (%s/%s)", version, runtime.GOOS, runtime.GOARCH)
+}
+
+// CLIVersionResponse represents the response from GET /cli/version.
+type CLIVersionResponse struct {
+	MinimumVersion string `json:"minimum_version"`
+	LatestVersion  string `json:"latest_version,omitempty"`
+}
+
+// CLIVersion fetches the CLI versions advertised by the server.
+func (c *Client) CLIVersion() (*CLIVersionResponse, error) {
+	return c.CLIVersionContext(context.Background())
+}
+
+// CLIVersionContext is like CLIVersion but uses ctx for the underlying request.
+func (c *Client) CLIVersionContext(ctx context.Context) (*CLIVersionResponse, error) {
+	body, err := c.makeRequest(ctx, "GET", "/cli/version", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get CLI version: %w", err)
+	}
+
+	resp, err := unwrapData[CLIVersionResponse](body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode CLI version response: %w", err)
+	}
+
+	return &resp, nil
+}
+
+// CompareVersions compares two semantic versions such as "1.4.0" or
+// "v1.5.0-rc.1" and returns -1, 0 or +1 as a is older than, equal to or newer
+// than b. A pre-release sorts before the release it precedes; pre-release
+// identifiers themselves are compared as plain strings. Build metadata is
+// ignored.
+func CompareVersions(a, b string) (int, error) {
+	va, err := parseVersion(a)
+	if err != nil {
+		return 0, err
+	}
+	vb, err := parseVersion(b)
+	if err != nil {
+		return 0, err
+	}
+
+	for i := range va.parts {
+		if va.parts[i] != vb.parts[i] {
+			if va.parts[i] < vb.parts[i] {
+				return -1, nil
+			}
+			return 1, nil
+		}
+	}
+
+	switch {
+	case va.pre == vb.pre:
+		return 0, nil
+	case va.pre == "":
+		return 1, nil
+	case vb.pre == "":
+		return -1, nil
+	}
+	return strings.Compare(va.pre, vb.pre), nil
+}
+
+// version is a parsed semantic version.
+type version struct {
+	parts [3]int
+	pre   string
+}
+
+func parseVersion(s string) (version, error) {
+	var v version
+	rest := strings.TrimPrefix(strings.TrimSpace(s), "v")
+	rest, _, _ = strings.Cut(rest, "+")
+	rest, v.pre, _ = strings.Cut(rest, "-")
+
+	fields := strings.Split(rest, ".")
+	if len(fields) > len(v.parts) {
+		return version{}, fmt.Errorf("invalid version %q", s)
+	}
+	for i, f := range fields {
+		n, err := strconv.Atoi(f)
+		if err != nil || n < 0 {
+			return version{}, fmt.Errorf("invalid version %q", s)
+		}
+		v.parts[i] = n
+	}
+	return v, nil
+}
@@ -0,0 +1,102 @@
+package apitest
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ironsh/irons/api"
+)
+
+// AddAuditEvents appends egress audit events, filling in missing IDs and
+// timestamps. Events are served in the order they were added.
+func (s *Server) AddAuditEvents(events ...api.EgressAuditEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, ev := range events {
+		if ev.ID == "" {
+			ev.ID = s.nextID("evt")
+		}
+		if ev.Timestamp.IsZero() {
+			ev.Timestamp = s.opts.Now().UTC()
+		}
+		s.events = append(s.events, ev)
+	}
+}
+
+// eventVerdict returns the event's verdict, deriving it from Allowed when the
+// event has none.
+func eventVerdict(ev api.EgressAuditEvent) string {
+	if ev.Verdict != "" {
+		return strings.ToLower(ev.Verdict)
+	}
+	if ev.Allowed {
+		return "allowed"
+	}
+	return "blocked"
+}
+
+// handleAuditEgress serves GET /audit/egress. Unlike the other list
+// endpoints the cursor is returned even on the last page, so a client
+// following the log can resume from it once new events arrive.
+func (s *Server) handleAuditEgress(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	var since, until time.Time
+	for _, p := range []struct {
+		name string
+		dst  *time.Time
+	}{{"since", &since}, {"until", &until}} {
+		if v := q.Get(p.name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, "invalid_request", p.name+" must be an RFC3339 timestamp")
+				return
+			}
+			*p.dst = t
+		}
+	}
+
+	start := 0
+	if c := q.Get("cursor"); c != "" {
+		start, _ = strconv.Atoi(c)
+	}
+	size := s.opts.PageSize
+	if l, err := strconv.Atoi(q.Get("limit")); err == nil && l > 0 {
+		size = l
+	}
+
+	s.mu.Lock()
+	events := s.events
+	s.mu.Unlock()
+
+	// Walk the log from the cursor, which is an offset into the full log
+	// rather than into the filtered results so it stays valid as events are
+	// appended.
+	start = min(max(start, 0), len(events))
+	var page []api.EgressAuditEvent
+	next := start
+	for ; next < len(events) && len(page) < size; next++ {
+		ev := events[next]
+		if v := q.Get("vm_id"); v != "" && ev.VMID != v {
+			continue
+		}
+		if v := q.Get("verdict"); v != "" && eventVerdict(ev) != strings.ToLower(v) {
+			continue
+		}
+		if !since.IsZero() && ev.Timestamp.Before(since) {
+			continue
+		}
+		if !until.IsZero() && ev.Timestamp.After(until) {
+			continue
+		}
+		page = append(page, ev)
+	}
+
+	writeJSON(w, http.StatusOK, api.ListAuditEgressResponse{
+		Data:    nonNil(page),
+		HasMore: next < len(events),
+		Cursor:  strconv.Itoa(next),
+	})
+}
@@ -0,0 +1,177 @@
+package apitest
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/ironsh/irons/api"
+)
+
+// deviceCodeTTL is how long a device code stays valid.
+const deviceCodeTTL = 10 * time.Minute
+
+// deviceTokenTTL is how long a token issued by the device flow stays valid.
+// Tokens from Options.APIKeys do not expire.
+const deviceTokenTTL = 90 * 24 * time.Hour
+
+// tokenScopes are the scopes granted to every token.
+var tokenScopes = []string{"vms", "egress", "secrets", "audit"}
+
+// tokenState is an API token accepted by the server.
+type tokenState struct {
+	createdAt time.Time
+	expiresAt time.Time // zero if the token does not expire
+}
+
+// deviceState tracks a device authorization request.
+type deviceState struct {
+	code      string
+	status    string
+	token     string
+	expiresAt time.Time
+	// slowDown is the interval to send with a slow_down response to the
+	// next poll, if positive.
+	slowDown int
+}
+
+// AuthorizeDevice approves a pending device code as if the user had
+// completed the browser flow. It returns the API token that the next poll
+// will hand out, which the server also starts accepting.
+func (s *Server) AuthorizeDevice(code string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d, ok := s.devices[code]
+	if !ok {
+		return "", fmt.Errorf("unknown device code %q", code)
+	}
+	if d.status != "pending" {
+		return "", fmt.Errorf("device code %q is %s", code, d.status)
+	}
+	d.status = "authorized"
+	d.token = "irons_" + s.nextID("tok")
+	now := s.opts.Now()
+	s.tokens[d.token] = &tokenState{createdAt: now, expiresAt: now.Add(deviceTokenTTL)}
+	return d.token, nil
+}
+
+// RevokeToken stops the server accepting token, as if it had been revoked
+// from the dashboard. It reports whether the token was accepted before.
+func (s *Server) RevokeToken(token string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.tokens[token]
+	delete(s.tokens, token)
+	return ok
+}
+
+// ExpireDevice marks a device code as expired.
+func (s *Server) ExpireDevice(code string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d, ok := s.devices[code]
+	if ok {
+		d.status = "expired"
+	}
+	return ok
+}
+
+// SlowDownDevice makes the next poll for a pending device code answer
+// slow_down, asking the client to poll every interval seconds instead.
+func (s *Server) SlowDownDevice(code string, interval int) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d, ok := s.devices[code]
+	if ok {
+		d.slowDown = interval
+	}
+	return ok
+}
+
+// DeviceCodes returns every device code issued so far.
+func (s *Server) DeviceCodes() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	codes := make([]string, 0, len(s.devices))
+	for code := range s.devices {
+		codes = append(codes, code)
+	}
+	return codes
+}
+
+func (s *Server) handleDeviceCode(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.seq++
+	d := &deviceState{
+		code:      fmt.Sprintf("IRON-%04d", s.seq),
+		status:    "pending",
+		expiresAt: s.opts.Now().Add(deviceCodeTTL),
+	}
+	s.devices[d.code] = d
+	s.mu.Unlock()
+
+	writeData(w, http.StatusCreated, api.DeviceCodeResponse{
+		Code:                    d.code,
+		VerificationURI:         s.URL + "/device",
+		VerificationURIComplete: s.URL + "/device?code=" + d.code,
+		ExpiresAt:               d.expiresAt,
+		Interval:                s.opts.DeviceInterval,
+	})
+}
+
+func (s *Server) handleDevicePoll(w http.ResponseWriter, r *http.Request) {
+	code := r.URL.Query().Get("code")
+
+	s.mu.Lock()
+	d, ok := s.devices[code]
+	var resp api.PollResponse
+	if ok {
+		if d.status == "pending" && s.opts.Now().After(d.expiresAt) {
+			d.status = "expired"
+		}
+		resp = api.PollResponse{Status: d.status, Token: d.token}
+		if d.status == "pending" && d.slowDown > 0 {
+			resp = api.PollResponse{Status: "slow_down", Interval: d.slowDown}
+			d.slowDown = 0
+		}
+	}
+	s.mu.Unlock()
+
+	if !ok {
+		writeError(w, http.StatusNotFound, "not_found", "unknown device code")
+		return
+	}
+	writeData(w, http.StatusOK, resp)
+}
+
+func (s *Server) handleTokenInfo(w http.ResponseWriter, r *http.Request) {
+	token, _ := bearerToken(r)
+
+	s.mu.Lock()
+	t := s.tokens[token]
+	if t == nil {
+		s.mu.Unlock()
+		writeError(w, http.StatusUnauthorized, "unauthorized", "invalid or missing API key")
+		return
+	}
+	info := api.TokenInfo{
+		Account:      s.opts.Account,
+		Organization: s.opts.Organization,
+		Scopes:       slices.Clone(tokenScopes),
+		CreatedAt:    t.createdAt.UTC(),
+	}
+	if !t.expiresAt.IsZero() {
+		expiresAt := t.expiresAt.UTC()
+		info.ExpiresAt = &expiresAt
+	}
+	s.mu.Unlock()
+
+	writeData(w, http.StatusOK, info)
+}
+
+func (s *Server) handleRevokeToken(w http.ResponseWriter, r *http.Request) {
+	token, _ := bearerToken(r)
+	s.RevokeToken(token)
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,146 @@
+package apitest
+
+import (
+	"net/http"
+	"slices"
+
+	"github.com/ironsh/irons/api"
+)
+
+// validEgressMode reports whether mode is an egress mode the API accepts.
+func validEgressMode(mode string) bool {
+	return mode == "enforce" || mode == "warn"
+}
+
+// EgressMode returns the account-wide egress mode.
+func (s *Server) EgressMode() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.egressMode
+}
+
+// EgressRules returns a copy of the account's egress rules.
+func (s *Server) EgressRules() []api.EgressRule {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.rules)
+}
+
+func (s *Server) handleGetEgressPolicy(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	mode := s.egressMode
+	s.mu.Unlock()
+	writeData(w, http.StatusOK, api.EgressModeResponse{Mode: mode})
+}
+
+func (s *Server) handleSetEgressPolicy(w http.ResponseWriter, r *http.Request) {
+	var req api.EgressModeRequest
+	if !decodeBody(w, r, &req) {
+		return
+	}
+	if !validEgressMode(req.Mode) {
+		writeError(w, http.StatusBadRequest, "invalid_request", "mode must be enforce or warn")
+		return
+	}
+
+	s.mu.Lock()
+	s.egressMode = req.Mode
+	s.mu.Unlock()
+
+	writeData(w, http.StatusOK, api.EgressModeResponse{Mode: req.Mode})
+}
+
+func (s *Server) handleGetVMEgressPolicy(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	v := s.findVM(r.PathValue("id"))
+	mode := s.egressMode
+	if v != nil && v.egressMode != "" {
+		mode = v.egressMode
+	}
+	s.mu.Unlock()
+
+	if v == nil {
+		writeError(w, http.StatusNotFound, "not_found", "VM not found")
+		return
+	}
+	writeData(w, http.StatusOK, api.EgressModeResponse{Mode: mode})
+}
+
+func (s *Server) handleSetVMEgressPolicy(w http.ResponseWriter, r *http.Request) {
+	var req api.EgressModeRequest
+	if !decodeBody(w, r, &req) {
+		return
+	}
+	if !validEgressMode(req.Mode) {
+		writeError(w, http.StatusBadRequest, "invalid_request", "mode must be enforce or warn")
+		return
+	}
+
+	s.mu.Lock()
+	v := s.findVM(r.PathValue("id"))
+	if v != nil {
+		v.egressMode = req.Mode
+	}
+	s.mu.Unlock()
+
+	if v == nil {
+		writeError(w, http.StatusNotFound, "not_found", "VM not found")
+		return
+	}
+	writeData(w, http.StatusOK, api.EgressModeResponse{Mode: req.Mode})
+}
+
+func (s *Server) handleListRules(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	rules := slices.Clone(s.rules)
+	s.mu.Unlock()
+
+	page, next := paginate(s, r, rules)
+	writeJSON(w, http.StatusOK, api.ListEgressRulesResponse{
+		Data:    nonNil(page),
+		HasMore: next != "",
+		Cursor:  cursorPtr(next),
+	})
+}
+
+func (s *Server) handleCreateRule(w http.ResponseWriter, r *http.Request) {
+	var req api.EgressRuleRequest
+	if !decodeBody(w, r, &req) {
+		return
+	}
+	if (req.Host == "") == (req.CIDR == "") {
+		writeError(w, http.StatusBadRequest, "invalid_request", "exactly one of host or cidr is required")
+		return
+	}
+
+	s.mu.Lock()
+	rule := api.EgressRule{
+		ID:        s.nextID("rule"),
+		Name:      req.Name,
+		Host:      req.Host,
+		CIDR:      req.CIDR,
+		Comment:   req.Comment,
+		CreatedAt: s.now(),
+	}
+	s.rules = append(s.rules, rule)
+	s.mu.Unlock()
+
+	writeData(w, http.StatusCreated, rule)
+}
+
+func (s *Server) handleDeleteRule(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	s.mu.Lock()
+	i := slices.IndexFunc(s.rules, func(rule api.EgressRule) bool { return rule.ID == id })
+	if i >= 0 {
+		s.rules = slices.Delete(s.rules, i, i+1)
+	}
+	s.mu.Unlock()
+
+	if i < 0 {
+		writeError(w, http.StatusNotFound, "not_found", "egress rule not found")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,167 @@
+package apitest
+
+import (
+	"net/http"
+	"slices"
+
+	"github.com/ironsh/irons/api"
+)
+
+// secretState is a secret plus its value, which the API never returns.
+type secretState struct {
+	secret api.Secret
+	value  string
+}
+
+// findSecret returns the secret with the given ID, or nil. It must be called
+// with s.mu held.
+func (s *Server) findSecret(id string) *secretState {
+	for _, sec := range s.secrets {
+		if sec.secret.ID == id {
+			return sec
+		}
+	}
+	return nil
+}
+
+// SecretValue returns the stored value of a secret by ID, which is not
+// otherwise observable through the API.
+func (s *Server) SecretValue(id string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if sec := s.findSecret(id); sec != nil {
+		return sec.value, true
+	}
+	return "", false
+}
+
+func (s *Server) handleCreateSecret(w http.ResponseWriter, r *http.Request) {
+	var req api.CreateSecretRequest
+	if !decodeBody(w, r, &req) {
+		return
+	}
+	if req.Name == "" || req.Secret == "" || req.EnvVar == "" {
+		writeError(w, http.StatusBadRequest, "invalid_request", "name, secret and env_var are required")
+		return
+	}
+
+	s.mu.Lock()
+	for _, sec := range s.secrets {
+		if sec.secret.Name == req.Name {
+			s.mu.Unlock()
+			writeError(w, http.StatusConflict, "already_exists", "a secret named "+req.Name+" already exists")
+			return
+		}
+	}
+	now := s.now()
+	sec := &secretState{
+		secret: api.Secret{
+			ID:         s.nextID("sec"),
+			Name:       req.Name,
+			EnvVar:     req.EnvVar,
+			Hosts:      nonNil(req.Hosts),
+			ProxyValue: "IRONSH_PROXY_" + req.Name,
+			CreatedAt:  now,
+			UpdatedAt:  now,
+		},
+		value: req.Secret,
+	}
+	if req.Comment != "" {
+		comment := req.Comment
+		sec.secret.Comment = &comment
+	}
+	s.secrets = append(s.secrets, sec)
+	out := sec.secret
+	s.mu.Unlock()
+
+	writeData(w, http.StatusCreated, out)
+}
+
+func (s *Server) handleListSecrets(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("name")
+
+	s.mu.Lock()
+	var secrets []api.Secret
+	for _, sec := range s.secrets {
+		if name != "" && sec.secret.Name != name {
+			continue
+		}
+		secrets = append(secrets, sec.secret)
+	}
+	s.mu.Unlock()
+
+	page, next := paginate(s, r, secrets)
+	writeJSON(w, http.StatusOK, api.ListSecretsResponse{
+		Data:    nonNil(page),
+		HasMore: next != "",
+		Cursor:  cursorPtr(next),
+	})
+}
+
+func (s *Server) handleGetSecret(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	sec := s.findSecret(r.PathValue("id"))
+	var out api.Secret
+	if sec != nil {
+		out = sec.secret
+	}
+	s.mu.Unlock()
+
+	if sec == nil {
+		writeError(w, http.StatusNotFound, "not_found", "secret not found")
+		return
+	}
+	writeData(w, http.StatusOK, out)
+}
+
+func (s *Server) handleUpdateSecret(w http.ResponseWriter, r *http.Request) {
+	var req api.UpdateSecretRequest
+	if !decodeBody(w, r, &req) {
+		return
+	}
+
+	s.mu.Lock()
+	sec := s.findSecret(r.PathValue("id"))
+	var out api.Secret
+	if sec != nil {
+		if req.Secret != "" {
+			sec.value = req.Secret
+		}
+		if req.EnvVar != "" {
+			sec.secret.EnvVar = req.EnvVar
+		}
+		if req.Hosts != nil {
+			sec.secret.Hosts = req.Hosts
+		}
+		if req.Comment != "" {
+			comment := req.Comment
+			sec.secret.Comment = &comment
+		}
+		sec.secret.UpdatedAt = s.now()
+		out = sec.secret
+	}
+	s.mu.Unlock()
+
+	if sec == nil {
+		writeError(w, http.StatusNotFound, "not_found", "secret not found")
+		return
+	}
+	writeData(w, http.StatusOK, out)
+}
+
+func (s *Server) handleDeleteSecret(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	s.mu.Lock()
+	i := slices.IndexFunc(s.secrets, func(sec *secretState) bool { return sec.secret.ID == id })
+	if i >= 0 {
+		s.secrets = slices.Delete(s.secrets, i, i+1)
+	}
+	s.mu.Unlock()
+
+	if i < 0 {
+		writeError(w, http.StatusNotFound, "not_found", "secret not found")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,520 @@
+// Package apitest provides an in-memory fake of the IronCD API for tests.
+//
+// A Server implements every endpoint used by api.Client on top of an
+// httptest.Server: VMs with a realistic status machine, account and per-VM
+// egress policy, egress rules, secrets, paginated egress audit events, the
+// device authorization flow and token inspection and revocation. Faults and
+// latency can be injected per route to exercise error handling and retries.
+//
+//	srv := apitest.NewServer(t, apitest.Options{})
+//	client := srv.Client()
+//	vm, _ := client.Create(key, "agent-1")
+package apitest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ironsh/irons/api"
+)
+
+// DefaultAPIKey is the API key accepted by a Server when Options.APIKeys is
+// empty.
+const DefaultAPIKey = "test-key"
+
+// Options configures a Server. The zero value is ready to use.
+type Options struct {
+	// APIKeys lists the bearer tokens the server accepts. Defaults to
+	// DefaultAPIKey.
+	APIKeys []string
+	// TransitionDelay is how long a VM spends in a transitional status
+	// (creating, starting, stopping) before reaching its target status. With
+	// the default of zero the target is reached by the next read.
+	TransitionDelay time.Duration
+	// PageSize is the maximum number of items returned per page by the list
+	// endpoints when the request does not set a limit. Defaults to 100.
+	PageSize int
+	// Latency is added to every request before it is handled.
+	Latency time.Duration
+	// SSHHost and SSHPort are returned by the SSH endpoint. They default to
+	// 127.0.0.1 and 22.
+	SSHHost string
+	SSHPort int
+	// Now returns the current time. Defaults to time.Now.
+	Now func() time.Time
+	// MinimumCLIVersion and LatestCLIVersion are advertised by the CLI
+	// version endpoint. MinimumCLIVersion defaults to 0.0.0.
+	MinimumCLIVersion string
+	LatestCLIVersion  string
+	// DeviceInterval is the poll interval in seconds advertised with device
+	// codes. Zero leaves it out.
+	DeviceInterval int
+	// Account and Organization are reported for every token by the token
+	// info endpoint. They default to a test user and organization.
+	Account      api.Account
+	Organization api.Organization
+}
+
+// Request is a request received by a Server.
+type Request struct {
+	Method string
+	Path   string
+	Query  string
+	Header http.Header
+	Body   []byte
+}
+
+// Fault describes an injected failure for a route.
+type Fault struct {
+	// Status is the HTTP status to respond with. A zero status only applies
+	// Latency and then handles the request normally.
+	Status int
+	// Code and Message populate the standard error body.
+	Code    string
+	Message string
+	// RetryAfter, if non-zero, is sent as a Retry-After header in seconds.
+	RetryAfter time.Duration
+	// Latency delays the request before the fault is applied.
+	Latency time.Duration
+	// AfterHandler handles the request normally and then discards the real
+	// response in favour of the fault, simulating a response lost after the
+	// server committed the change.
+	AfterHandler bool
+	// Times is the number of requests the fault applies to. Zero means every
+	// matching request until the fault is cleared.
+	Times int
+}
+
+// fault is a registered Fault with its match pattern and remaining count.
+type fault struct {
+	method  string
+	pattern string
+	Fault
+	remaining int
+}
+
+// storedResponse is the response to a create call, replayed when the same
+// Idempotency-Key is seen again. It is reserved before the call is handled,
+// and done is closed once status and body are set, or once the key has been
+// released because the call failed.
+type storedResponse struct {
+	done   chan struct{}
+	status int
+	body   []byte
+}
+
+// Server is an in-memory implementation of the IronCD API.
+type Server struct {
+	*httptest.Server
+
+	opts Options
+	mux  *http.ServeMux
+
+	mu          sync.Mutex
+	seq         int
+	requests    []Request
+	faults      []*fault
+	idempotency map[string]*storedResponse
+	vms         []*vmState
+	egressMode  string
+	rules       []api.EgressRule
+	secrets     []*secretState
+	events      []api.EgressAuditEvent
+	devices     map[string]*deviceState
+	tokens      map[string]*tokenState
+}
+
+// NewServer starts a Server and registers its shutdown with t.Cleanup.
+func NewServer(t testing.TB, opts Options) *Server {
+	t.Helper()
+	s := NewUnstartedServer(opts)
+	s.Start()
+	t.Cleanup(s.Close)
+	return s
+}
+
+// NewUnstartedServer returns a Server that has not started listening. Call
+// Start before use and Close when done.
+func NewUnstartedServer(opts Options) *Server {
+	if len(opts.APIKeys) == 0 {
+		opts.APIKeys = []string{DefaultAPIKey}
+	}
+	if opts.PageSize <= 0 {
+		opts.PageSize = 100
+	}
+	if opts.SSHHost == "" {
+		opts.SSHHost = "127.0.0.1"
+	}
+	if opts.SSHPort == 0 {
+		opts.SSHPort = 22
+	}
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+	if opts.MinimumCLIVersion == "" {
+		opts.MinimumCLIVersion = "0.0.0"
+	}
+	if opts.Account.ID == "" {
+		opts.Account = api.Account{ID: "acct_test", Name: "Test User", Email: "test@example.com"}
+	}
+	if opts.Organization.ID == "" {
+		opts.Organization = api.Organization{ID: "org_test", Name: "Test Org"}
+	}
+
+	s := &Server{
+		opts:        opts,
+		mux:         http.NewServeMux(),
+		idempotency: map[string]*storedResponse{},
+		egressMode:  "enforce",
+		devices:     map[string]*deviceState{},
+		tokens:      map[string]*tokenState{},
+	}
+	for _, k := range opts.APIKeys {
+		s.tokens[k] = &tokenState{createdAt: opts.Now()}
+	}
+	s.routes()
+	s.Server = httptest.NewUnstartedServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// Client returns an api.Client pointed at the server and authenticated with
+// the first configured API key. Retries use a short backoff so injected
+// faults don't slow tests down.
+func (s *Server) Client() *api.Client {
+	c := api.NewClient(s.URL, s.opts.APIKeys[0])
+	c.Retry.MinBackoff = time.Millisecond
+	c.Retry.MaxBackoff = 10 * time.Millisecond
+	return c
+}
+
+// InjectFault registers a fault for requests whose method and path match.
+// method may be empty to match any method, and pattern uses path.Match
+// syntax, e.g. "/vms/*". Faults are checked in registration order.
+func (s *Server) InjectFault(method, pattern string, f Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = append(s.faults, &fault{method: method, pattern: pattern, Fault: f, remaining: f.Times})
+}
+
+// ClearFaults removes every injected fault.
+func (s *Server) ClearFaults() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = nil
+}
+
+// Requests returns a copy of every request received so far.
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.requests)
+}
+
+// CountRequests returns the number of requests received for method and an
+// exact path.
+func (s *Server) CountRequests(method, path string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for _, r := range s.requests {
+		if r.Method == method && r.Path == path {
+			n++
+		}
+	}
+	return n
+}
+
+// serveHTTP records the request, applies latency and faults, and dispatches
+// it.
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	r.Body.Close()
+
+	s.mu.Lock()
+	s.requests = append(s.requests, Request{
+		Method: r.Method,
+		Path:   r.URL.Path,
+		Query:  r.URL.RawQuery,
+		Header: r.Header.Clone(),
+		Body:   body,
+	})
+	f := s.matchFault(r.Method, r.URL.Path)
+	s.mu.Unlock()
+
+	delay := s.opts.Latency
+	if f != nil {
+		delay += f.Latency
+	}
+	if delay > 0 {
+		select {
+		case <-time.After(delay):
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	if f != nil && f.Status != 0 {
+		if f.AfterHandler {
+			s.dispatch(httptest.NewRecorder(), r, body)
+		}
+		if f.RetryAfter > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(int(f.RetryAfter.Seconds())))
+		}
+		writeError(w, f.Status, f.Code, f.Message)
+		return
+	}
+
+	s.dispatch(w, r, body)
+}
+
+// dispatch enforces authentication, replays idempotent creates and hands the
+// request to the mux.
+func (s *Server) dispatch(w http.ResponseWriter, r *http.Request, body []byte) {
+	if !public(r.URL.Path) && !s.authorized(r) {
+		writeError(w, http.StatusUnauthorized, "unauthorized", "invalid or missing API key")
+		return
+	}
+
+	// Replay the stored response for a repeated Idempotency-Key.
+	key := r.Header.Get("Idempotency-Key")
+	if key != "" && r.Method == http.MethodPost {
+		idemKey := r.URL.Path + " " + key
+		stored, ok := s.reserveIdempotencyKey(r.Context(), idemKey)
+		if !ok {
+			return
+		}
+		if stored.status != 0 {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(stored.status)
+			w.Write(stored.body)
+			return
+		}
+
+		rec := httptest.NewRecorder()
+		s.mux.ServeHTTP(rec, withBody(r, body))
+		s.mu.Lock()
+		if rec.Code < 300 {
+			stored.status, stored.body = rec.Code, rec.Body.Bytes()
+		} else {
+			delete(s.idempotency, idemKey)
+		}
+		s.mu.Unlock()
+		close(stored.done)
+
+		for k, v := range rec.Header() {
+			w.Header()[k] = v
+		}
+		w.WriteHeader(rec.Code)
+		w.Write(rec.Body.Bytes())
+		return
+	}
+
+	s.mux.ServeHTTP(w, withBody(r, body))
+}
+
+// reserveIdempotencyKey returns the stored response for idemKey. If the key
+// is new it is reserved, and the caller must handle the request, fill in the
+// response and close done. A request that arrives while another with the
+// same key is being handled waits for its response, and takes over the key
+// if that request fails. ok is false if ctx ends first.
+func (s *Server) reserveIdempotencyKey(ctx context.Context, idemKey string) (stored *storedResponse, ok bool) {
+	for {
+		s.mu.Lock()
+		stored, found := s.idempotency[idemKey]
+		if !found {
+			stored = &storedResponse{done: make(chan struct{})}
+			s.idempotency[idemKey] = stored
+		}
+		s.mu.Unlock()
+		if !found {
+			return stored, true
+		}
+
+		select {
+		case <-stored.done:
+		case <-ctx.Done():
+			return nil, false
+		}
+		if stored.status != 0 {
+			return stored, true
+		}
+	}
+}
+
+// public reports whether the endpoint at p can be called without an API key.
+func public(p string) bool {
+	return strings.HasPrefix(p, "/auth/device/") || p == "/cli/version"
+}
+
+// matchFault returns the first fault matching the request, consuming one use
+// of it. It must be called with s.mu held.
+func (s *Server) matchFault(method, p string) *Fault {
+	for i, f := range s.faults {
+		if f.method != "" && f.method != method {
+			continue
+		}
+		if ok, _ := path.Match(f.pattern, p); !ok {
+			continue
+		}
+		if f.Times > 0 {
+			f.remaining--
+			if f.remaining <= 0 {
+				s.faults = slices.Delete(s.faults, i, i+1)
+			}
+		}
+		out := f.Fault
+		return &out
+	}
+	return nil
+}
+
+// authorized reports whether the request carries an accepted bearer token
+// that has not expired.
+func (s *Server) authorized(r *http.Request) bool {
+	token, ok := bearerToken(r)
+	if !ok {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t := s.tokens[token]
+	return t != nil && (t.expiresAt.IsZero() || s.opts.Now().Before(t.expiresAt))
+}
+
+// bearerToken returns the token from the request's Authorization header.
+func bearerToken(r *http.Request) (string, bool) {
+	return strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+}
+
+// routes registers every API endpoint on the mux.
+func (s *Server) routes() {
+	s.mux.HandleFunc("POST /vms", s.handleCreateVM)
+	s.mux.HandleFunc("GET /vms", s.handleListVMs)
+	s.mux.HandleFunc("GET /vms/{id}", s.handleGetVM)
+	s.mux.HandleFunc("PATCH /vms/{id}", s.handleUpdateVM)
+	s.mux.HandleFunc("DELETE /vms/{id}", s.handleDestroyVM)
+	s.mux.HandleFunc("POST /vms/{id}/start", s.handleStartVM)
+	s.mux.HandleFunc("POST /vms/{id}/stop", s.handleStopVM)
+	s.mux.HandleFunc("GET /vms/{id}/ssh", s.handleSSH)
+	s.mux.HandleFunc("GET /vms/{id}/egress/policy", s.handleGetVMEgressPolicy)
+	s.mux.HandleFunc("PUT /vms/{id}/egress/policy", s.handleSetVMEgressPolicy)
+
+	s.mux.HandleFunc("GET /egress/policy", s.handleGetEgressPolicy)
+	s.mux.HandleFunc("PUT /egress/policy", s.handleSetEgressPolicy)
+	s.mux.HandleFunc("GET /egress/rules", s.handleListRules)
+	s.mux.HandleFunc("POST /egress/rules", s.handleCreateRule)
+	s.mux.HandleFunc("DELETE /egress/rules/{id}", s.handleDeleteRule)
+
+	s.mux.HandleFunc("POST /secrets", s.handleCreateSecret)
+	s.mux.HandleFunc("GET /secrets", s.handleListSecrets)
+	s.mux.HandleFunc("GET /secrets/{id}", s.handleGetSecret)
+	s.mux.HandleFunc("PATCH /secrets/{id}", s.handleUpdateSecret)
+	s.mux.HandleFunc("DELETE /secrets/{id}", s.handleDeleteSecret)
+
+	s.mux.HandleFunc("GET /audit/egress", s.handleAuditEgress)
+
+	s.mux.HandleFunc("POST /auth/device/code", s.handleDeviceCode)
+	s.mux.HandleFunc("GET /auth/device/poll", s.handleDevicePoll)
+	s.mux.HandleFunc("GET /auth/token", s.handleTokenInfo)
+	s.mux.HandleFunc("DELETE /auth/token", s.handleRevokeToken)
+
+	s.mux.HandleFunc("GET /cli/version", s.handleCLIVersion)
+}
+
+// nextID returns a new resource ID with the given prefix. It must be called
+// with s.mu held.
+func (s *Server) nextID(prefix string) string {
+	s.seq++
+	return fmt.Sprintf("%s_%06d", prefix, s.seq)
+}
+
+// now returns the server's current time formatted for API responses.
+func (s *Server) now() string {
+	return s.opts.Now().UTC().Format(time.RFC3339)
+}
+
+// withBody returns a shallow copy of r whose body reads from b again.
+func withBody(r *http.Request, b []byte) *http.Request {
+	r2 := r.Clone(r.Context())
+	r2.Body = io.NopCloser(strings.NewReader(string(b)))
+	return r2
+}
+
+// decodeBody unmarshals the request body into v, writing a 400 response and
+// returning false if it is not valid JSON.
+func decodeBody(w http.ResponseWriter, r *http.Request, v any) bool {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request", "invalid JSON body: "+err.Error())
+		return false
+	}
+	return true
+}
+
+// writeJSON writes v as a JSON response with the given status.
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeData writes v wrapped in the {"data": v} envelope.
+func writeData(w http.ResponseWriter, status int, v any) {
+	writeJSON(w, status, map[string]any{"data": v})
+}
+
+// writeError writes an API error in the standard error format.
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	if code == "" {
+		code = strings.ToLower(strings.ReplaceAll(http.StatusText(status), " ", "_"))
+	}
+	if message == "" {
+		message = http.StatusText(status)
+	}
+	body := api.ErrorResponse{}
+	body.Error.Code = code
+	body.Error.Message = message
+	writeJSON(w, status, body)
+}
+
+// paginate returns the page of items selected by the request's cursor and
+// limit query parameters, along with the cursor for the next page ("" if
+// this is the last page). Cursors are item offsets.
+func paginate[T any](s *Server, r *http.Request, items []T) ([]T, string) {
+	start := 0
+	if c := r.URL.Query().Get("cursor"); c != "" {
+		start, _ = strconv.Atoi(c)
+	}
+	start = min(max(start, 0), len(items))
+
+	size := s.opts.PageSize
+	if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 {
+		size = l
+	}
+
+	end := min(start+size, len(items))
+	next := ""
+	if end < len(items) {
+		next = strconv.Itoa(end)
+	}
+	return items[start:end], next
+}
+
+// cursorPtr returns nil for an empty cursor, matching the list responses
+// that use *string cursors.
+func cursorPtr(c string) *string {
+	if c == "" {
+		return nil
+	}
+	return &c
+}
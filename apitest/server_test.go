@@ -0,0 +1,230 @@
+package apitest_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ironsh/irons/api"
+	"github.com/ironsh/irons/apitest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVMLifecycle(t *testing.T) {
+	srv := apitest.NewServer(t, apitest.Options{})
+	client := srv.Client()
+
+	vm, err := client.Create([]byte("ssh-ed25519 AAAA"), "agent-1")
+	require.NoError(t, err)
+	require.Equal(t, "creating", vm.Status)
+
+	got, err := client.GetVM(vm.ID)
+	require.NoError(t, err)
+	require.Equal(t, "running", got.Status)
+	require.Equal(t, "ready", got.StatusDetail)
+
+	err = client.Destroy(vm.ID)
+	require.True(t, api.IsConflict(err), err)
+
+	_, err = client.Stop(vm.ID)
+	require.NoError(t, err)
+	got, err = client.GetVM(vm.ID)
+	require.NoError(t, err)
+	require.Equal(t, "stopped", got.Status)
+
+	require.NoError(t, client.Destroy(vm.ID))
+	got, err = client.GetVM(vm.ID)
+	require.NoError(t, err)
+	require.Equal(t, "destroyed", got.Status)
+
+	_, err = client.ResolveVM("agent-1")
+	require.Error(t, err, "destroyed VMs should not resolve by name")
+}
+
+func TestTransitionDelay(t *testing.T) {
+	now := time.Date(2026, 3, 4, 12, 0, 0, 0, time.UTC)
+	srv := apitest.NewServer(t, apitest.Options{
+		TransitionDelay: time.Minute,
+		Now:             func() time.Time { return now },
+	})
+	client := srv.Client()
+
+	vm, err := client.Create([]byte("ssh-ed25519 AAAA"), "agent-1")
+	require.NoError(t, err)
+
+	got, err := client.GetVM(vm.ID)
+	require.NoError(t, err)
+	require.Equal(t, "creating", got.Status)
+
+	now = now.Add(time.Minute)
+	got, err = client.GetVM(vm.ID)
+	require.NoError(t, err)
+	require.Equal(t, "running", got.Status)
+}
+
+func TestIdempotentCreateSurvivesLostResponse(t *testing.T) {
+	srv := apitest.NewServer(t, apitest.Options{})
+	srv.InjectFault("POST", "/vms", apitest.Fault{Status: http.StatusBadGateway, AfterHandler: true, Times: 1})
+	client := srv.Client()
+
+	vm, err := client.Create([]byte("ssh-ed25519 AAAA"), "agent-1")
+	require.NoError(t, err)
+	require.Equal(t, 2, srv.CountRequests("POST", "/vms"))
+
+	vms, err := api.Collect(client.AllVMs(context.Background()), 0)
+	require.NoError(t, err)
+	require.Len(t, vms, 1, "retry must not create a second VM")
+	require.Equal(t, vm.ID, vms[0].ID)
+}
+
+func TestIdempotentCreateConcurrentRequests(t *testing.T) {
+	srv := apitest.NewServer(t, apitest.Options{})
+	body := `{"name": "agent-1", "public_key": "ssh-ed25519 AAAA"}`
+
+	var wg sync.WaitGroup
+	ids := make([]string, 8)
+	for i := range ids {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req, err := http.NewRequest("POST", srv.URL+"/vms", strings.NewReader(body))
+			require.NoError(t, err)
+			req.Header.Set("Authorization", "Bearer "+apitest.DefaultAPIKey)
+			req.Header.Set("Idempotency-Key", "same-key")
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			var created struct{ Data api.VM }
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&created))
+			ids[i] = created.Data.ID
+		}()
+	}
+	wg.Wait()
+
+	vms, err := api.Collect(srv.Client().AllVMs(context.Background()), 0)
+	require.NoError(t, err)
+	require.Len(t, vms, 1, "requests sharing a key must create one VM")
+	for _, id := range ids {
+		require.Equal(t, vms[0].ID, id)
+	}
+}
+
+func TestPagination(t *testing.T) {
+	srv := apitest.NewServer(t, apitest.Options{PageSize: 2})
+	for range 5 {
+		srv.AddVM(api.VM{Name: "agent"})
+	}
+	client := srv.Client()
+
+	first, err := client.ListVMs()
+	require.NoError(t, err)
+	require.Len(t, first.Data, 2)
+	require.True(t, first.HasMore)
+
+	all, err := api.Collect(client.AllVMs(context.Background()), 0)
+	require.NoError(t, err)
+	require.Len(t, all, 5)
+}
+
+func TestUnauthorized(t *testing.T) {
+	srv := apitest.NewServer(t, apitest.Options{})
+	client := api.NewClient(srv.URL, "wrong-key")
+
+	_, err := client.ListVMs()
+	require.True(t, api.IsUnauthorized(err), err)
+}
+
+func TestEgressAndSecrets(t *testing.T) {
+	srv := apitest.NewServer(t, apitest.Options{})
+	client := srv.Client()
+
+	require.NoError(t, client.EgressSetPolicy("warn"))
+	policy, err := client.EgressGetPolicy()
+	require.NoError(t, err)
+	require.Equal(t, "warn", policy.Mode)
+
+	rule, err := client.EgressCreateRule(api.EgressRuleRequest{Host: "crates.io"})
+	require.NoError(t, err)
+	require.NoError(t, client.EgressDeleteRule(rule.ID))
+	require.Empty(t, srv.EgressRules())
+
+	vm := srv.AddVM(api.VM{Name: "agent-1"})
+	vmPolicy, err := client.VMEgressGetPolicy(vm.ID)
+	require.NoError(t, err)
+	require.Equal(t, "warn", vmPolicy.Mode, "VMs inherit the account policy")
+
+	sec, err := client.SecretsCreate(api.CreateSecretRequest{Name: "gh", Secret: "ghp_x", EnvVar: "GITHUB_TOKEN"})
+	require.NoError(t, err)
+	id, err := client.ResolveSecret("gh")
+	require.NoError(t, err)
+	require.Equal(t, sec.ID, id)
+	value, _ := srv.SecretValue(sec.ID)
+	require.Equal(t, "ghp_x", value)
+}
+
+func TestAuditEgressFollowCursor(t *testing.T) {
+	srv := apitest.NewServer(t, apitest.Options{PageSize: 2})
+	srv.AddAuditEvents(
+		api.EgressAuditEvent{VMID: "vm_1", Host: "a.example", Allowed: true},
+		api.EgressAuditEvent{VMID: "vm_1", Host: "b.example"},
+		api.EgressAuditEvent{VMID: "vm_2", Host: "c.example", Allowed: true},
+	)
+	client := srv.Client()
+
+	events, err := api.Collect(client.AllAuditEgress(context.Background(), api.AuditEgressParams{Verdict: "allowed"}), 0)
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+
+	resp, err := client.AuditEgress(api.AuditEgressParams{Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, resp.Data, 3)
+
+	srv.AddAuditEvents(api.EgressAuditEvent{Host: "d.example"})
+	resp, err = client.AuditEgress(api.AuditEgressParams{Cursor: resp.Cursor})
+	require.NoError(t, err)
+	require.Len(t, resp.Data, 1)
+	require.Equal(t, "d.example", resp.Data[0].Host)
+}
+
+func TestDeviceAuthorization(t *testing.T) {
+	srv := apitest.NewServer(t, apitest.Options{})
+	anon := api.NewClient(srv.URL, "")
+
+	code, err := anon.DeviceCode()
+	require.NoError(t, err)
+
+	poll, err := anon.PollDevice(code.Code)
+	require.NoError(t, err)
+	require.Equal(t, "pending", poll.Status)
+
+	token, err := srv.AuthorizeDevice(code.Code)
+	require.NoError(t, err)
+
+	poll, err = anon.PollDevice(code.Code)
+	require.NoError(t, err)
+	require.Equal(t, "authorized", poll.Status)
+	require.Equal(t, token, poll.Token)
+
+	_, err = api.NewClient(srv.URL, token).ListVMs()
+	require.NoError(t, err)
+}
+
+func TestTokenInfoAndRevoke(t *testing.T) {
+	srv := apitest.NewServer(t, apitest.Options{})
+	client := srv.Client()
+
+	info, err := client.TokenInfo()
+	require.NoError(t, err)
+	require.Equal(t, "acct_test", info.Account.ID)
+	require.Equal(t, "org_test", info.Organization.ID)
+	require.NotEmpty(t, info.Scopes)
+	require.Nil(t, info.ExpiresAt)
+
+	require.NoError(t, client.RevokeToken())
+	_, err = client.TokenInfo()
+	require.True(t, api.IsUnauthorized(err), err)
+}
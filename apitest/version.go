@@ -0,0 +1,14 @@
+package apitest
+
+import (
+	"net/http"
+
+	"github.com/ironsh/irons/api"
+)
+
+func (s *Server) handleCLIVersion(w http.ResponseWriter, r *http.Request) {
+	writeData(w, http.StatusOK, api.CLIVersionResponse{
+		MinimumVersion: s.opts.MinimumCLIVersion,
+		LatestVersion:  s.opts.LatestCLIVersion,
+	})
+}
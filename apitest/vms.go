@@ -0,0 +1,297 @@
+package apitest
+
+import (
+	"maps"
+	"net/http"
+	"time"
+
+	"github.com/ironsh/irons/api"
+)
+
+// vmState is a VM plus the status it is transitioning to.
+type vmState struct {
+	vm           api.VM
+	publicKey    string
+	egressMode   string
+	targetStatus string
+	targetDetail string
+	readyAt      time.Time
+}
+
+// settle applies a pending transition once its delay has elapsed.
+func (v *vmState) settle(now time.Time) {
+	if v.targetStatus == "" || now.Before(v.readyAt) {
+		return
+	}
+	v.vm.Status = v.targetStatus
+	v.vm.StatusDetail = v.targetDetail
+	v.vm.UpdatedAt = now.UTC().Format(time.RFC3339)
+	v.targetStatus, v.targetDetail = "", ""
+}
+
+// transition moves the VM into a transitional status that settles into the
+// target status after Options.TransitionDelay. It must be called with s.mu
+// held.
+func (s *Server) transition(v *vmState, status, detail, targetStatus, targetDetail string) {
+	now := s.opts.Now()
+	v.vm.Status = status
+	v.vm.StatusDetail = detail
+	v.vm.UpdatedAt = now.UTC().Format(time.RFC3339)
+	v.targetStatus = targetStatus
+	v.targetDetail = targetDetail
+	v.readyAt = now.Add(s.opts.TransitionDelay)
+}
+
+// findVM returns the VM with the given ID after settling any pending
+// transition, or nil. It must be called with s.mu held.
+func (s *Server) findVM(id string) *vmState {
+	for _, v := range s.vms {
+		if v.vm.ID == id {
+			v.settle(s.opts.Now())
+			return v
+		}
+	}
+	return nil
+}
+
+// AddVM inserts a VM directly into the server's state, bypassing the create
+// flow. Missing IDs and timestamps are filled in. It returns the stored VM.
+func (s *Server) AddVM(vm api.VM) api.VM {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if vm.ID == "" {
+		vm.ID = s.nextID("vm")
+	}
+	if vm.Status == "" {
+		vm.Status, vm.StatusDetail = "running", "ready"
+	}
+	if vm.CreatedAt == "" {
+		vm.CreatedAt = s.now()
+	}
+	if vm.UpdatedAt == "" {
+		vm.UpdatedAt = vm.CreatedAt
+	}
+	s.vms = append(s.vms, &vmState{vm: vm})
+	return vm
+}
+
+// VM returns the current state of a VM by ID.
+func (s *Server) VM(id string) (api.VM, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if v := s.findVM(id); v != nil {
+		return v.vm, true
+	}
+	return api.VM{}, false
+}
+
+// SetVMStatus forces a VM into the given status and detail, cancelling any
+// pending transition. Use it to simulate failures such as "failed".
+func (s *Server) SetVMStatus(id, status, detail string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v := s.findVM(id)
+	if v == nil {
+		return false
+	}
+	v.vm.Status, v.vm.StatusDetail = status, detail
+	v.targetStatus, v.targetDetail = "", ""
+	return true
+}
+
+func (s *Server) handleCreateVM(w http.ResponseWriter, r *http.Request) {
+	var req api.CreateRequest
+	if !decodeBody(w, r, &req) {
+		return
+	}
+	if req.Name == "" {
+		writeError(w, http.StatusBadRequest, "invalid_request", "name is required")
+		return
+	}
+	if req.PublicKey == "" {
+		writeError(w, http.StatusBadRequest, "invalid_request", "public_key is required")
+		return
+	}
+
+	s.mu.Lock()
+	now := s.now()
+	v := &vmState{
+		vm: api.VM{
+			ID:        s.nextID("vm"),
+			Name:      req.Name,
+			Labels:    maps.Clone(req.Labels),
+			CreatedAt: now,
+		},
+		publicKey: req.PublicKey,
+	}
+	s.transition(v, "creating", "provisioning", "running", "ready")
+	s.vms = append(s.vms, v)
+	vm := v.vm
+	s.mu.Unlock()
+
+	writeData(w, http.StatusCreated, vm)
+}
+
+func (s *Server) handleUpdateVM(w http.ResponseWriter, r *http.Request) {
+	var req api.UpdateVMRequest
+	if !decodeBody(w, r, &req) {
+		return
+	}
+
+	s.mu.Lock()
+	v := s.findVM(r.PathValue("id"))
+	if v == nil || v.vm.Status == "destroyed" {
+		s.mu.Unlock()
+		writeError(w, http.StatusNotFound, "not_found", "VM not found")
+		return
+	}
+	// Copy the labels rather than editing them in place, since copies of
+	// the VM handed out earlier share the map.
+	labels := maps.Clone(v.vm.Labels)
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	for k, val := range req.Labels {
+		if val == nil {
+			delete(labels, k)
+		} else {
+			labels[k] = *val
+		}
+	}
+	if len(labels) == 0 {
+		labels = nil
+	}
+	v.vm.Labels = labels
+	v.vm.UpdatedAt = s.now()
+	vm := v.vm
+	s.mu.Unlock()
+
+	writeData(w, http.StatusOK, vm)
+}
+
+func (s *Server) handleListVMs(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("name")
+
+	s.mu.Lock()
+	var vms []api.VM
+	for _, v := range s.vms {
+		v.settle(s.opts.Now())
+		if name != "" && v.vm.Name != name {
+			continue
+		}
+		vms = append(vms, v.vm)
+	}
+	s.mu.Unlock()
+
+	page, next := paginate(s, r, vms)
+	writeJSON(w, http.StatusOK, api.ListVMsResponse{
+		Data:    nonNil(page),
+		HasMore: next != "",
+		Cursor:  cursorPtr(next),
+	})
+}
+
+func (s *Server) handleGetVM(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	v := s.findVM(r.PathValue("id"))
+	var vm api.VM
+	if v != nil {
+		vm = v.vm
+	}
+	s.mu.Unlock()
+
+	if v == nil {
+		writeError(w, http.StatusNotFound, "not_found", "VM not found")
+		return
+	}
+	writeData(w, http.StatusOK, vm)
+}
+
+func (s *Server) handleStartVM(w http.ResponseWriter, r *http.Request) {
+	s.lifecycle(w, r, "stopped", "starting", "booting", "running", "ready")
+}
+
+func (s *Server) handleStopVM(w http.ResponseWriter, r *http.Request) {
+	s.lifecycle(w, r, "running", "stopping", "shutting_down", "stopped", "stopped")
+}
+
+// lifecycle implements start and stop: the VM must be in status from, and is
+// moved through a transitional status to the target.
+func (s *Server) lifecycle(w http.ResponseWriter, r *http.Request, from, status, detail, targetStatus, targetDetail string) {
+	s.mu.Lock()
+	v := s.findVM(r.PathValue("id"))
+	if v == nil {
+		s.mu.Unlock()
+		writeError(w, http.StatusNotFound, "not_found", "VM not found")
+		return
+	}
+	if v.vm.Status != from {
+		current := v.vm.Status
+		s.mu.Unlock()
+		writeError(w, http.StatusConflict, "invalid_state", "VM is "+current+", expected "+from)
+		return
+	}
+	s.transition(v, status, detail, targetStatus, targetDetail)
+	vm := v.vm
+	s.mu.Unlock()
+
+	writeData(w, http.StatusOK, vm)
+}
+
+func (s *Server) handleDestroyVM(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	v := s.findVM(r.PathValue("id"))
+	if v == nil {
+		s.mu.Unlock()
+		writeError(w, http.StatusNotFound, "not_found", "VM not found")
+		return
+	}
+	switch v.vm.Status {
+	case "stopped", "failed":
+	case "destroyed":
+		s.mu.Unlock()
+		writeError(w, http.StatusNotFound, "not_found", "VM not found")
+		return
+	default:
+		s.mu.Unlock()
+		writeError(w, http.StatusConflict, "vm_not_stopped", "VM is not stopped")
+		return
+	}
+	v.vm.Status, v.vm.StatusDetail = "destroyed", ""
+	v.vm.UpdatedAt = s.now()
+	s.mu.Unlock()
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleSSH(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	v := s.findVM(r.PathValue("id"))
+	var status string
+	if v != nil {
+		status = v.vm.Status
+	}
+	s.mu.Unlock()
+
+	if v == nil || status == "destroyed" {
+		writeError(w, http.StatusNotFound, "not_found", "VM not found")
+		return
+	}
+	if status != "running" {
+		writeError(w, http.StatusConflict, "invalid_state", "VM is "+status+", expected running")
+		return
+	}
+	writeData(w, http.StatusOK, api.SSHResponse{
+		Host:     s.opts.SSHHost,
+		Port:     s.opts.SSHPort,
+		Username: "iron",
+	})
+}
+
+// nonNil returns an empty slice instead of nil so lists encode as [].
+func nonNil[T any](items []T) []T {
+	if items == nil {
+		return []T{}
+	}
+	return items
+}
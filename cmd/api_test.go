@@ -0,0 +1,300 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/ironsh/irons/api"
+	"github.com/ironsh/irons/apitest"
+	"github.com/stretchr/testify/require"
+)
+
+// --- API client tests (pure unit tests against the mock server) ---
+
+func TestAPI_ContextCancelsInFlightRequest(t *testing.T) {
+	release := make(chan struct{})
+	t.Cleanup(func() { close(release) })
+
+	ms := newMockServer(t, []route{
+		{"GET", "/vms/vm_abc123", func(w http.ResponseWriter, r *http.Request, body []byte) {
+			select {
+			case <-release:
+			case <-r.Context().Done():
+			}
+		}},
+	})
+	client := api.NewClient(ms.Server.URL, "test-key")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := client.GetVMContext(ctx, "vm_abc123")
+	require.Error(t, err)
+	require.True(t, errors.Is(err, context.DeadlineExceeded), err)
+	require.Less(t, time.Since(start), 5*time.Second)
+}
+
+// pagedVMsRoute serves VMs in pages of pageSize, using the index of the next
+// item as the cursor.
+func pagedVMsRoute(vms []api.VM, pageSize int) route {
+	return route{"GET", "/vms", func(w http.ResponseWriter, r *http.Request, body []byte) {
+		start := 0
+		if c := r.URL.Query().Get("cursor"); c != "" {
+			start, _ = strconv.Atoi(c)
+		}
+		end := min(start+pageSize, len(vms))
+		resp := api.ListVMsResponse{Data: vms[start:end], HasMore: end < len(vms)}
+		if resp.HasMore {
+			next := strconv.Itoa(end)
+			resp.Cursor = &next
+		}
+		jsonResponse(w, http.StatusOK, resp)
+	}}
+}
+
+func sampleVMs(n int) []api.VM {
+	vms := make([]api.VM, n)
+	for i := range vms {
+		vms[i] = api.VM{
+			ID:        fmt.Sprintf("vm_%03d", i),
+			Name:      fmt.Sprintf("agent-%d", i),
+			Status:    "running",
+			CreatedAt: "2026-03-04T12:00:00Z",
+		}
+	}
+	return vms
+}
+
+func TestAPI_AllVMsWalksEveryPage(t *testing.T) {
+	ms := newMockServer(t, []route{pagedVMsRoute(sampleVMs(7), 3)})
+	client := api.NewClient(ms.Server.URL, "test-key")
+
+	vms, err := api.Collect(client.AllVMs(context.Background()), 0)
+	require.NoError(t, err)
+	require.Len(t, vms, 7)
+	require.Equal(t, "vm_006", vms[6].ID)
+	require.Len(t, ms.Requests(), 3)
+}
+
+func TestAPI_AllVMsStopsAtLimit(t *testing.T) {
+	ms := newMockServer(t, []route{pagedVMsRoute(sampleVMs(7), 3)})
+	client := api.NewClient(ms.Server.URL, "test-key")
+
+	vms, err := api.Collect(client.AllVMs(context.Background()), 4)
+	require.NoError(t, err)
+	require.Len(t, vms, 4)
+	require.Len(t, ms.Requests(), 2, "should not fetch pages beyond the limit")
+}
+
+func TestList_AllPages(t *testing.T) {
+	ms := newMockServer(t, []route{pagedVMsRoute(sampleVMs(5), 2)})
+
+	res := runCLI(t, ms, "list")
+	require.Equal(t, 0, res.ExitCode, res.Stderr)
+	require.Contains(t, res.Stdout, "vm_000")
+	require.Contains(t, res.Stdout, "vm_004")
+
+	res = runCLI(t, ms, "list", "--limit", "1")
+	require.Equal(t, 0, res.ExitCode, res.Stderr)
+	require.Contains(t, res.Stdout, "vm_000")
+	require.NotContains(t, res.Stdout, "vm_001")
+}
+
+func TestAPI_TypedErrors(t *testing.T) {
+	ms := newMockServer(t, []route{
+		{"DELETE", "/vms/vm_abc123", func(w http.ResponseWriter, r *http.Request, body []byte) {
+			w.Header().Set("X-Request-Id", "req_123")
+			jsonResponse(w, http.StatusConflict, map[string]interface{}{
+				"error": map[string]string{"code": "vm_not_stopped", "message": "VM is not stopped"},
+			})
+		}},
+	})
+	client := api.NewClient(ms.Server.URL, "test-key")
+
+	err := client.Destroy("vm_abc123")
+	require.Error(t, err)
+	require.True(t, api.IsConflict(err))
+	require.False(t, api.IsNotFound(err))
+
+	var apiErr *api.APIError
+	require.True(t, errors.As(err, &apiErr))
+	require.Equal(t, http.StatusConflict, apiErr.StatusCode)
+	require.Equal(t, "vm_not_stopped", apiErr.Code)
+	require.Equal(t, "VM is not stopped", apiErr.Message)
+	require.Equal(t, "req_123", apiErr.RequestID)
+	require.Contains(t, err.Error(), "VM is not stopped")
+}
+
+func TestDestroy_ConflictSuggestsForce(t *testing.T) {
+	ms := newMockServer(t, []route{
+		{"GET", "/vms/vm_abc123", func(w http.ResponseWriter, r *http.Request, body []byte) {
+			jsonResponse(w, http.StatusOK, wrapData(api.VM{ID: "vm_abc123", Name: "agent-1", Status: "running"}))
+		}},
+		{"DELETE", "/vms/vm_abc123", func(w http.ResponseWriter, r *http.Request, body []byte) {
+			jsonResponse(w, http.StatusConflict, map[string]interface{}{
+				"error": map[string]string{"code": "conflict", "message": "conflict"},
+			})
+		}},
+	})
+
+	res := runCLI(t, ms, "destroy", "vm_abc123")
+	require.NotEqual(t, 0, res.ExitCode)
+	require.Contains(t, res.Stderr, "Use --force to stop it first")
+}
+
+func TestUnauthorized_PrintsLoginGuidance(t *testing.T) {
+	ms := newMockServer(t, []route{
+		{"GET", "/vms", func(w http.ResponseWriter, r *http.Request, body []byte) {
+			jsonResponse(w, http.StatusUnauthorized, map[string]interface{}{
+				"error": map[string]string{"code": "unauthorized", "message": "invalid API key"},
+			})
+		}},
+	})
+
+	res := runCLI(t, ms, "list")
+	require.NotEqual(t, 0, res.ExitCode)
+	require.Contains(t, res.Stderr, "authentication failed")
+	require.Contains(t, res.Stderr, "irons login")
+	require.NotContains(t, res.Stderr, "Usage:")
+}
+
+func TestAPI_RetriesCreateWithSameIdempotencyKey(t *testing.T) {
+	var keys []string
+	ms := newMockServer(t, []route{
+		{"POST", "/vms", func(w http.ResponseWriter, r *http.Request, body []byte) {
+			keys = append(keys, r.Header.Get("Idempotency-Key"))
+			if len(keys) < 3 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			jsonResponse(w, http.StatusCreated, wrapData(api.VM{ID: "vm_abc123", Name: "my-vm", Status: "creating"}))
+		}},
+	})
+	client := api.NewClient(ms.Server.URL, "test-key")
+	client.Retry.MinBackoff = time.Millisecond
+
+	vm, err := client.Create([]byte("ssh-ed25519 AAAA"), "my-vm")
+	require.NoError(t, err)
+	require.Equal(t, "vm_abc123", vm.ID)
+	require.Len(t, keys, 3)
+	require.NotEmpty(t, keys[0])
+	require.Equal(t, keys[0], keys[1])
+	require.Equal(t, keys[0], keys[2])
+}
+
+func TestAPI_HonoursRetryAfter(t *testing.T) {
+	calls := 0
+	ms := newMockServer(t, []route{
+		{"GET", "/vms/vm_abc123", func(w http.ResponseWriter, r *http.Request, body []byte) {
+			calls++
+			if calls == 1 {
+				w.Header().Set("Retry-After", "1")
+				w.WriteHeader(http.StatusTooManyRequests)
+				return
+			}
+			jsonResponse(w, http.StatusOK, wrapData(api.VM{ID: "vm_abc123"}))
+		}},
+	})
+	client := api.NewClient(ms.Server.URL, "test-key")
+	client.Retry.MinBackoff = time.Millisecond
+
+	start := time.Now()
+	_, err := client.GetVM("vm_abc123")
+	require.NoError(t, err)
+	require.Equal(t, 2, calls)
+	require.GreaterOrEqual(t, time.Since(start), time.Second)
+}
+
+func TestAPI_DoesNotRetryNonIdempotentPost(t *testing.T) {
+	ms := newMockServer(t, []route{
+		{"POST", "/vms/vm_abc123/start", func(w http.ResponseWriter, r *http.Request, body []byte) {
+			w.WriteHeader(http.StatusInternalServerError)
+		}},
+	})
+	client := api.NewClient(ms.Server.URL, "test-key")
+	client.Retry.MinBackoff = time.Millisecond
+
+	_, err := client.Start("vm_abc123")
+	require.Error(t, err)
+	require.Len(t, ms.Requests(), 1)
+}
+
+func TestAPI_RetriesDisabled(t *testing.T) {
+	ms := newMockServer(t, []route{
+		{"GET", "/vms/vm_abc123", func(w http.ResponseWriter, r *http.Request, body []byte) {
+			w.WriteHeader(http.StatusBadGateway)
+		}},
+	})
+	client := api.NewClient(ms.Server.URL, "test-key")
+	client.Retry.MaxRetries = 0
+
+	_, err := client.GetVM("vm_abc123")
+	require.Error(t, err)
+	require.Len(t, ms.Requests(), 1)
+}
+
+func TestAPI_ResolveVM(t *testing.T) {
+	srv := apitest.NewServer(t, apitest.Options{})
+	a := srv.AddVM(api.VM{ID: "vm_abc123", Name: "agent-1"})
+	srv.AddVM(api.VM{ID: "vm_abd456", Name: "agent-1", Status: "stopped"})
+	srv.AddVM(api.VM{ID: "vm_xyz789", Name: "builder"})
+	srv.AddVM(api.VM{ID: "vm_old000", Name: "builder", Status: "destroyed"})
+	client := srv.Client()
+
+	_, err := client.ResolveVM("agent-1")
+	var ambiguous *api.AmbiguousError
+	require.ErrorAs(t, err, &ambiguous)
+	require.Len(t, ambiguous.Candidates, 2)
+	require.Contains(t, err.Error(), "vm_abc123")
+	require.Contains(t, err.Error(), "vm_abd456")
+
+	id, err := client.ResolveVM("builder")
+	require.NoError(t, err, "destroyed VMs should not make a name ambiguous")
+	require.Equal(t, "vm_xyz789", id)
+
+	id, err = client.ResolveVM("abc")
+	require.NoError(t, err)
+	require.Equal(t, a.ID, id)
+
+	id, err = client.ResolveVM("bui")
+	require.NoError(t, err)
+	require.Equal(t, "vm_xyz789", id)
+
+	_, err = client.ResolveVM("ab")
+	require.ErrorAs(t, err, &ambiguous)
+
+	_, err = client.ResolveVM("biulder")
+	require.True(t, api.IsNotFound(err))
+	require.ErrorContains(t, err, `did you mean "builder"?`)
+
+	id, err = client.ResolveVM("vm_abc123")
+	require.NoError(t, err)
+	require.Equal(t, a.ID, id)
+
+	id, err = client.ResolveVM("vm_xy")
+	require.NoError(t, err, "an ID prefix should be matched like a name prefix")
+	require.Equal(t, "vm_xyz789", id)
+
+	_, err = client.ResolveVM("vm_nope")
+	require.True(t, api.IsNotFound(err), "a mistyped ID should not be sent on as it is")
+}
+
+func TestSecretsAPI_ResolveAmbiguousName(t *testing.T) {
+	s := sampleSecret()
+	s2 := sampleSecret()
+	s2.ID = "sec_other"
+	ms := newMockServer(t, []route{secretsListRoute(s, s2)})
+	client := api.NewClient(ms.Server.URL, "test-key")
+
+	_, err := client.ResolveSecret("github-main")
+	var ambiguous *api.AmbiguousError
+	require.ErrorAs(t, err, &ambiguous)
+	require.Equal(t, "secret", ambiguous.Kind)
+	require.Len(t, ambiguous.Candidates, 2)
+}
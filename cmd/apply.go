@@ -0,0 +1,354 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/fatih/color"
+	"github.com/ironsh/irons/api"
+	"github.com/ironsh/irons/spec"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+const specHelp = `The spec is a YAML file listing the VMs, egress policy and rules, and
+secrets the account should have:
+
+  egress:
+    mode: enforce
+    rules:
+      - host: github.com
+      - cidr: 10.0.0.0/8
+        comment: internal network
+  vms:
+    - name: agent-1
+      ssh_key: ~/.ssh/id_ed25519.pub   # default: the ssh_key setting
+      egress_mode: warn
+  secrets:
+    - name: github-token
+      env_var: GITHUB_TOKEN
+      hosts: [api.github.com]
+      value_from_env: GITHUB_TOKEN     # or value_file: path
+
+VMs are matched by name, egress rules by host or CIDR, and secrets by
+name. Sections that are left out are not managed. A secret's value is only
+used when it is created, and a VM's SSH key only when it is created.
+
+With --prune, resources of a managed kind that the spec does not list are
+deleted: VMs are stopped and destroyed, and rules and secrets removed. An
+egress section without a rules key does not prune rules.`
+
+// planCmd represents the plan command
+var planCmd = &cobra.Command{
+	Use:   "plan",
+	Short: "Show the changes apply would make",
+	Long: `Compare a spec with the account and show the changes 'irons apply' would
+make, without making them.
+
+` + specHelp + `
+
+Examples:
+  irons plan
+  irons plan -f sandboxes/irons.yaml --prune`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		_, changes, err := planSpec(cmd)
+		if err != nil {
+			return err
+		}
+		printPlan(changes)
+		return nil
+	},
+}
+
+// applyCmd represents the apply command
+var applyCmd = &cobra.Command{
+	Use:   "apply",
+	Short: "Create, update or delete resources to match a spec",
+	Long: `Compare a spec with the account and make the changes needed for the
+account to match it. The plan is printed first, as by 'irons plan', and
+on a terminal apply asks before making it. Applying the same spec again
+makes no changes.
+
+-y/--yes (or --auto-approve) skips the question. Without a terminal to
+ask on, apply refuses to delete or replace anything unless it is given.
+
+` + specHelp + `
+
+Examples:
+  irons apply
+  irons apply -f sandboxes/irons.yaml --prune --yes`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		yes, _ := cmd.Flags().GetBool("yes")
+
+		client, changes, err := planSpec(cmd)
+		if err != nil {
+			return err
+		}
+		printPlan(changes)
+		if len(changes) == 0 {
+			return nil
+		}
+
+		// As in Terraform, people are always asked, but scripts only when
+		// the plan loses something.
+		if !yes && (stdinIsTerminal() || destructive(changes)) {
+			if err := confirm("perform these actions", "--yes"); err != nil {
+				return err
+			}
+		}
+
+		ctx := cmd.Context()
+		fmt.Println()
+		for i, c := range changes {
+			if err := applyChange(ctx, client, c); err != nil {
+				return fmt.Errorf("%s %s %q: %w (%d of %d changes applied)", c.Action, c.Kind, c.Name, err, i, len(changes))
+			}
+		}
+
+		add, change, destroy := spec.Summary(changes)
+		fmt.Printf("\n✓ Apply complete! Resources: %d added, %d changed, %d destroyed.\n", add, change, destroy)
+		return nil
+	},
+}
+
+func init() {
+	for _, cmd := range []*cobra.Command{planCmd, applyCmd} {
+		rootCmd.AddCommand(cmd)
+		cmd.Flags().StringP("file", "f", "irons.yaml", "Spec file")
+		cmd.Flags().Bool("prune", false, "Delete resources the spec does not list")
+	}
+	applyCmd.Flags().BoolP("yes", "y", false, "Apply the plan without asking")
+	applyCmd.Flags().SetNormalizeFunc(func(f *pflag.FlagSet, name string) pflag.NormalizedName {
+		if name == "auto-approve" {
+			name = "yes"
+		}
+		return pflag.NormalizedName(name)
+	})
+}
+
+// destructive reports whether any of changes deletes or replaces a
+// resource.
+func destructive(changes []spec.Change) bool {
+	for _, c := range changes {
+		if c.Action == spec.Delete || c.Action == spec.Replace {
+			return true
+		}
+	}
+	return false
+}
+
+// planSpec loads the spec named by --file, reads the account's state and
+// returns the changes needed, along with the client used.
+func planSpec(cmd *cobra.Command) (api.Services, []spec.Change, error) {
+	path, _ := cmd.Flags().GetString("file")
+	prune, _ := cmd.Flags().GetBool("prune")
+
+	s, err := spec.Load(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	client := newClient()
+	state, err := fetchState(cmd.Context(), client, s)
+	if err != nil {
+		return nil, nil, err
+	}
+	return client, spec.Plan(s, state, prune), nil
+}
+
+// fetchState reads the parts of the account's state that s manages.
+func fetchState(ctx context.Context, client api.Services, s *spec.Spec) (*spec.State, error) {
+	state := &spec.State{VMEgressModes: map[string]string{}}
+	var err error
+
+	if s.Egress != nil {
+		if s.Egress.Mode != "" {
+			policy, err := client.EgressGetPolicyContext(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("getting egress mode: %w", err)
+			}
+			state.EgressMode = policy.Mode
+		}
+		if state.Rules, err = api.Collect(client.AllEgressRules(ctx), 0); err != nil {
+			return nil, fmt.Errorf("listing egress rules: %w", err)
+		}
+	}
+
+	if s.Secrets != nil {
+		if state.Secrets, err = api.Collect(client.AllSecrets(ctx), 0); err != nil {
+			return nil, fmt.Errorf("listing secrets: %w", err)
+		}
+	}
+
+	if s.VMs != nil {
+		vms, err := api.Collect(client.AllVMs(ctx), 0)
+		if err != nil {
+			return nil, fmt.Errorf("listing VMs: %w", err)
+		}
+		byName := map[string]api.VM{}
+		for _, vm := range vms {
+			if vm.Status != "destroyed" {
+				state.VMs = append(state.VMs, vm)
+				byName[vm.Name] = vm
+			}
+		}
+		for _, want := range s.VMs {
+			vm, ok := byName[want.Name]
+			if !ok || want.EgressMode == "" {
+				continue
+			}
+			policy, err := client.VMEgressGetPolicyContext(ctx, vm.ID)
+			if err != nil {
+				return nil, fmt.Errorf("getting egress mode of VM %q: %w", vm.Name, err)
+			}
+			state.VMEgressModes[vm.ID] = policy.Mode
+		}
+	}
+
+	return state, nil
+}
+
+// planSymbols are the Terraform-style markers for each action.
+var planSymbols = map[spec.Action]string{
+	spec.Create:  color.GreenString("+"),
+	spec.Update:  color.YellowString("~"),
+	spec.Replace: color.RedString("-") + "/" + color.GreenString("+"),
+	spec.Delete:  color.RedString("-"),
+}
+
+// printPlan prints the changes and a summary line.
+func printPlan(changes []spec.Change) {
+	if len(changes) == 0 {
+		fmt.Println("No changes. The account matches the spec.")
+		return
+	}
+
+	fmt.Println("irons will perform the following actions:")
+	fmt.Println()
+	for _, c := range changes {
+		fmt.Printf("  %s %s %q\n", planSymbols[c.Action], c.Kind, c.Name)
+		for _, f := range c.Fields {
+			fmt.Printf("      %s\n", f)
+		}
+	}
+
+	add, change, destroy := spec.Summary(changes)
+	fmt.Printf("\nPlan: %d to add, %d to change, %d to destroy.\n", add, change, destroy)
+}
+
+// applyChange makes one change and reports it.
+func applyChange(ctx context.Context, client api.Services, c spec.Change) error {
+	switch c.Kind {
+	case spec.KindEgressMode:
+		if err := client.EgressSetPolicyContext(ctx, c.Mode); err != nil {
+			return err
+		}
+
+	case spec.KindEgressRule:
+		// A rule is replaced by creating the new one first, so that egress
+		// it allows is never blocked in between.
+		if c.Action == spec.Create || c.Action == spec.Replace {
+			_, err := client.EgressCreateRuleContext(ctx, api.EgressRuleRequest{
+				Name:    c.Rule.Name,
+				Host:    c.Rule.Host,
+				CIDR:    c.Rule.CIDR,
+				Comment: c.Rule.Comment,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		if c.Action == spec.Delete || c.Action == spec.Replace {
+			if err := client.EgressDeleteRuleContext(ctx, c.ID); err != nil {
+				return err
+			}
+		}
+
+	case spec.KindSecret:
+		switch c.Action {
+		case spec.Create:
+			value, err := c.Secret.Value()
+			if err != nil {
+				return err
+			}
+			_, err = client.SecretsCreateContext(ctx, api.CreateSecretRequest{
+				Name:    c.Secret.Name,
+				Secret:  value,
+				EnvVar:  c.Secret.EnvVar,
+				Hosts:   c.Secret.Hosts,
+				Comment: c.Secret.Comment,
+			})
+			if err != nil {
+				return err
+			}
+		case spec.Update:
+			_, err := client.SecretsUpdateContext(ctx, c.ID, api.UpdateSecretRequest{
+				EnvVar:  c.Secret.EnvVar,
+				Hosts:   c.Secret.Hosts,
+				Comment: c.Secret.Comment,
+			})
+			if err != nil {
+				return err
+			}
+		case spec.Delete:
+			if err := client.SecretsDeleteContext(ctx, c.ID); err != nil {
+				return err
+			}
+		}
+
+	case spec.KindVM:
+		switch c.Action {
+		case spec.Create:
+			keyPath := c.VM.SSHKey
+			if keyPath == "" {
+				keyPath = viper.GetString("ssh-key")
+			}
+			key, err := os.ReadFile(keyPath)
+			if err != nil {
+				return fmt.Errorf("reading SSH key file %s: %w", keyPath, err)
+			}
+			vm, err := client.CreateContext(ctx, key, c.VM.Name)
+			if err != nil {
+				return err
+			}
+			if c.VM.EgressMode != "" {
+				if err := client.VMEgressSetPolicyContext(ctx, vm.ID, c.VM.EgressMode); err != nil {
+					return fmt.Errorf("setting egress mode: %w", err)
+				}
+			}
+		case spec.Update:
+			if err := client.VMEgressSetPolicyContext(ctx, c.ID, c.VM.EgressMode); err != nil {
+				return err
+			}
+		case spec.Delete:
+			if err := stopAndDestroy(ctx, client, c.ID); err != nil {
+				return err
+			}
+		}
+	}
+
+	fmt.Printf("%s %s %q: %sd\n", planSymbols[c.Action], c.Kind, c.Name, c.Action)
+	return nil
+}
+
+// stopAndDestroy stops a VM if it is not already stopped, waits for it to
+// stop, and destroys it.
+func stopAndDestroy(ctx context.Context, client api.VMService, id string) error {
+	vm, err := client.GetVMContext(ctx, id)
+	if err != nil {
+		return err
+	}
+	if vm.Status != "stopped" && vm.Status != "failed" {
+		if _, err := client.StopContext(ctx, id); err != nil {
+			return fmt.Errorf("stopping VM: %w", err)
+		}
+		if err := waitForVMCond(ctx, client, id, statusIn("stopped")); err != nil {
+			return err
+		}
+	}
+	return client.DestroyContext(ctx, id)
+}
@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ironsh/irons/api"
+	"github.com/ironsh/irons/apitest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApply_ConvergesAndPrunes(t *testing.T) {
+	srv := apitest.NewServer(t, apitest.Options{})
+	stale := srv.AddVM(api.VM{Name: "stale"})
+	_, err := srv.Client().EgressCreateRule(api.EgressRuleRequest{Host: "github.com", Comment: "old"})
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	keyData, err := os.ReadFile(writeTestKey(t))
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "id.pub"), keyData, 0o644))
+	spec := `egress:
+  mode: warn
+  rules:
+    - host: github.com
+    - cidr: 10.0.0.0/8
+vms:
+  - name: agent-1
+    ssh_key: id.pub
+    egress_mode: warn
+secrets:
+  - name: gh
+    env_var: GITHUB_TOKEN
+    hosts: [api.github.com]
+    value_from_env: GH_TOKEN
+`
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "irons.yaml"), []byte(spec), 0o644))
+	env := []string{"HOME=" + t.TempDir(), "IRONS_API_URL=" + srv.URL, "IRONS_API_KEY=test-key", "GH_TOKEN=ghp_secret"}
+
+	res := runCLIIn(t, dir, env, nil, "plan")
+	require.Equal(t, 0, res.ExitCode, res.Stderr)
+	require.Contains(t, res.Stdout, `egress_rule "github.com"`)
+	require.Contains(t, res.Stdout, `comment: "old" -> ""`)
+	require.Contains(t, res.Stdout, "Plan: 4 to add, 1 to change, 1 to destroy.")
+	require.Len(t, srv.EgressRules(), 1, "plan must not change anything")
+
+	// The rule is replaced, so without a terminal apply needs --yes.
+	res = runCLIIn(t, dir, env, nil, "apply")
+	require.Equal(t, 1, res.ExitCode)
+	require.Contains(t, res.Stderr, "pass --yes to go ahead")
+	require.Len(t, srv.EgressRules(), 1)
+
+	before := len(srv.Requests())
+	res = runCLIIn(t, dir, env, nil, "apply", "--auto-approve")
+	require.Equal(t, 0, res.ExitCode, res.Stderr)
+	require.Contains(t, res.Stdout, "Apply complete! Resources: 4 added, 1 changed, 1 destroyed.")
+	require.Equal(t, "warn", srv.EgressMode())
+	var ruleRequests []string
+	for _, r := range srv.Requests()[before:] {
+		if strings.HasPrefix(r.Path, "/egress/rules") && r.Method != "GET" {
+			ruleRequests = append(ruleRequests, r.Method)
+		}
+	}
+	require.Equal(t, []string{"POST", "DELETE", "POST"}, ruleRequests, "a replaced rule must be created before the old one is deleted")
+	require.Len(t, srv.EgressRules(), 2)
+
+	secrets, err := api.Collect(srv.Client().AllSecrets(t.Context()), 0)
+	require.NoError(t, err)
+	require.Len(t, secrets, 1)
+	value, _ := srv.SecretValue(secrets[0].ID)
+	require.Equal(t, "ghp_secret", value)
+
+	// Applying again changes nothing, and the unlisted VM is left alone.
+	res = runCLIIn(t, dir, env, nil, "apply")
+	require.Equal(t, 0, res.ExitCode, res.Stderr)
+	require.Contains(t, res.Stdout, "No changes.")
+	vm, _ := srv.VM(stale.ID)
+	require.Equal(t, "running", vm.Status)
+
+	res = runCLIIn(t, dir, env, nil, "apply", "--prune")
+	require.Equal(t, 1, res.ExitCode)
+	vm, _ = srv.VM(stale.ID)
+	require.Equal(t, "running", vm.Status)
+
+	res = runCLIIn(t, dir, env, nil, "apply", "--prune", "-y")
+	require.Equal(t, 0, res.ExitCode, res.Stderr)
+	require.Contains(t, res.Stdout, `vm "stale": deleted`)
+	vm, _ = srv.VM(stale.ID)
+	require.Equal(t, "destroyed", vm.Status)
+}
+
+func TestApply_InvalidSpec(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "irons.yaml"), []byte("vms:\n  - nme: agent-1\n"), 0o644))
+
+	res := runCLIIn(t, dir, []string{"HOME=" + t.TempDir(), "IRONS_API_KEY=test-key"}, nil, "apply")
+	require.Equal(t, 1, res.ExitCode)
+	require.Contains(t, res.Stderr, "field nme not found")
+}
@@ -1,12 +1,9 @@
 package cmd
 
 import (
-	"context"
 	"fmt"
 	"os"
-	"os/signal"
 	"strings"
-	"syscall"
 	"time"
 
 	"github.com/fatih/color"
@@ -29,7 +26,8 @@ var auditEgressCmd = &cobra.Command{
 	Long: `View egress audit logs.
 
 Prints a log of outbound network connection attempts, including whether each
-was allowed or denied. Use --follow to continuously tail new events.
+was allowed or denied. Without --follow every page of matching events is
+fetched, up to --limit events. Use --follow to continuously tail new events.
 
 Examples:
   irons audit egress
@@ -44,13 +42,19 @@ Examples:
 		until, _ := cmd.Flags().GetString("until")
 		limit, _ := cmd.Flags().GetInt("limit")
 
-		client := newClient()
+		output, err := newPrinter()
+		if err != nil {
+			return err
+		}
+		show := func(ev api.EgressAuditEvent) error {
+			return output.printEvent(ev, func() { printEgressEvent(ev, output.wide()) })
+		}
 
-		ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
-		defer cancel()
+		client := newClient()
+		ctx := cmd.Context()
 
 		if vmID != "" {
-			resolved, err := resolveVM(client, vmID)
+			resolved, err := resolveVM(ctx, client, vmID)
 			if err != nil {
 				return err
 			}
@@ -69,29 +73,50 @@ Examples:
 			Limit:   limit,
 		}
 
+		if !follow {
+			// Walk every page, stopping early once --limit events have been printed.
+			printed := 0
+			for ev, err := range client.AllAuditEgress(ctx, params) {
+				if err != nil {
+					return fmt.Errorf("fetching egress audit log: %w", err)
+				}
+				if err := show(ev); err != nil {
+					return err
+				}
+				printed++
+				if limit > 0 && printed >= limit {
+					break
+				}
+			}
+			return nil
+		}
+
 		// Initial fetch.
-		resp, err := client.AuditEgress(params)
+		resp, err := client.AuditEgressContext(ctx, params)
 		if err != nil {
 			return fmt.Errorf("fetching egress audit log: %w", err)
 		}
 		for _, ev := range resp.Data {
-			printEgressEvent(ev)
+			if err := show(ev); err != nil {
+				return err
+			}
 		}
 		params.Cursor = resp.Cursor
 
-		if !follow {
-			return nil
-		}
-
 		ticker := time.NewTicker(2 * time.Second)
 		defer ticker.Stop()
 
 		var prevLastEventID string
+		var showErr error
 
 		// fetch returns true if there are more events to fetch right away.
 		fetch := func() bool {
-			resp, err := client.AuditEgress(params)
+			resp, err := client.AuditEgressContext(ctx, params)
 			if err != nil {
+				if ctx.Err() != nil {
+					// Interrupted mid-request; the loop exits on ctx.Done.
+					return false
+				}
 				fmt.Fprintf(os.Stderr, "warning: %v\n", err)
 				return false
 			}
@@ -110,14 +135,20 @@ Examples:
 			prevLastEventID = lastEventID
 
 			for _, ev := range resp.Data {
-				printEgressEvent(ev)
+				if showErr = show(ev); showErr != nil {
+					return false
+				}
 			}
 
 			return true
 		}
 
 		for {
-			if immediate := fetch(); immediate {
+			immediate := fetch()
+			if showErr != nil {
+				return showErr
+			}
+			if immediate {
 				select {
 				case <-ctx.Done():
 					return nil
@@ -142,7 +173,9 @@ var (
 	verdictDeny  = color.New(color.FgRed, color.Bold).SprintfFunc()
 )
 
-func printEgressEvent(ev api.EgressAuditEvent) {
+// printEgressEvent prints an event on one line. wide adds the CIDR of the
+// rule that matched, if any, and the event's ID.
+func printEgressEvent(ev api.EgressAuditEvent, wide bool) {
 	verdict := strings.ToLower(ev.Verdict)
 	if verdict == "" {
 		if ev.Allowed {
@@ -174,9 +207,15 @@ func printEgressEvent(ev api.EgressAuditEvent) {
 		parts = append(parts, fmt.Sprintf("%-5s", ev.Protocol))
 	}
 	parts = append(parts, ev.Host)
+	if wide && ev.CIDR != "" {
+		parts = append(parts, ev.CIDR)
+	}
 	if ev.Mode != "" {
 		parts = append(parts, fmt.Sprintf("(mode: %s)", ev.Mode))
 	}
+	if wide {
+		parts = append(parts, ev.ID)
+	}
 
 	fmt.Println(strings.Join(parts, "  "))
 }
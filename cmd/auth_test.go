@@ -0,0 +1,287 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ironsh/irons/api"
+	"github.com/ironsh/irons/apitest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWhoami(t *testing.T) {
+	srv := apitest.NewServer(t, apitest.Options{})
+
+	res := runCLIAt(t, srv.URL, nil, "whoami")
+	require.Equal(t, 0, res.ExitCode, res.Stderr)
+	require.Contains(t, res.Stdout, "Logged in as Test User <test@example.com> (acct_test)")
+	require.Contains(t, res.Stdout, "Organization: Test Org (org_test)")
+	require.Contains(t, res.Stdout, "Key source: env IRONS_API_KEY")
+	require.Contains(t, res.Stdout, "Scopes: vms, egress, secrets, audit")
+	require.Contains(t, res.Stdout, "Expires: never")
+
+	res = runCLIAt(t, srv.URL, nil, "whoami", "-o", "json")
+	require.Equal(t, 0, res.ExitCode, res.Stderr)
+	var info api.TokenInfo
+	require.NoError(t, json.Unmarshal([]byte(res.Stdout), &info), res.Stdout)
+	require.Equal(t, "acct_test", info.Account.ID)
+	require.Equal(t, "org_test", info.Organization.ID)
+	require.Nil(t, info.ExpiresAt)
+}
+
+func TestWhoami_ExpiringToken(t *testing.T) {
+	now := time.Now().Add(-89 * 24 * time.Hour)
+	srv := apitest.NewServer(t, apitest.Options{Now: func() time.Time { return now }})
+	codeResp, err := srv.Client().DeviceCode()
+	require.NoError(t, err)
+	token, err := srv.AuthorizeDevice(codeResp.Code)
+	require.NoError(t, err)
+	now = time.Now()
+
+	home := writeConfig(t, "profiles:\n  default:\n    api_key: "+token+"\n")
+	res := runCLIWithEnv(t, []string{"HOME=" + home, "IRONS_API_URL=" + srv.URL}, nil, "whoami")
+	require.Equal(t, 0, res.ExitCode, res.Stderr)
+	require.Contains(t, res.Stdout, "Key source: file ")
+	require.Contains(t, res.Stdout, "This API key expires in")
+}
+
+func TestWhoami_ExpiredToken(t *testing.T) {
+	expired := time.Now().Add(-3 * time.Hour)
+	ms := newMockServer(t, []route{
+		{"GET", "/auth/token", func(w http.ResponseWriter, r *http.Request, body []byte) {
+			jsonResponse(w, http.StatusOK, wrapData(api.TokenInfo{
+				Account:   api.Account{ID: "acct_test"},
+				ExpiresAt: &expired,
+			}))
+		}},
+	})
+
+	res := runCLI(t, ms, "whoami")
+	require.Equal(t, 0, res.ExitCode, res.Stderr)
+	require.Contains(t, res.Stdout, "This API key has expired.")
+	require.NotContains(t, res.Stdout, "expires in")
+}
+
+func TestCheckToken_CustomClientFactory(t *testing.T) {
+	srv := apitest.NewServer(t, apitest.Options{})
+	// An api.Client wrapped so its key cannot be replaced.
+	SetClientFactory(func() api.Services { return struct{ *api.Client }{srv.Client()} })
+	t.Cleanup(func() { SetClientFactory(nil) })
+
+	_, err := checkToken(context.Background(), "new-key")
+	require.ErrorContains(t, err, "cannot authenticate with a different API key")
+	require.Empty(t, srv.Requests(), "the configured key is not checked instead")
+}
+
+func TestRequireAuth_NoKeyVersusRejected(t *testing.T) {
+	srv := apitest.NewServer(t, apitest.Options{})
+
+	res := runCLIWithEnv(t, []string{"HOME=" + t.TempDir(), "IRONS_API_URL=" + srv.URL}, nil, "whoami")
+	require.Equal(t, 1, res.ExitCode)
+	require.Contains(t, res.Stderr, `no API key is configured for profile "default"`)
+	require.Empty(t, srv.Requests())
+
+	home := writeConfig(t, "profiles:\n  default:\n    api_key: stale-key\n")
+	res = runCLIWithEnv(t, []string{"HOME=" + home, "IRONS_API_URL=" + srv.URL}, nil, "whoami")
+	require.Equal(t, 1, res.ExitCode)
+	require.Contains(t, res.Stderr, "authentication failed")
+	require.Contains(t, res.Stderr, "(profile default) was rejected")
+}
+
+func TestLogout_RevokesAndRemovesKey(t *testing.T) {
+	srv := apitest.NewServer(t, apitest.Options{APIKeys: []string{"saved-key"}})
+	home := writeConfig(t, "profiles:\n  default:\n    api_key: saved-key\n    output: json\n")
+	env := []string{"HOME=" + home, "IRONS_API_URL=" + srv.URL}
+
+	res := runCLIWithEnv(t, env, nil, "logout")
+	require.Equal(t, 0, res.ExitCode, res.Stderr)
+	require.Contains(t, res.Stdout, "Revoked the API key")
+	require.Contains(t, res.Stdout, `Logged out of profile "default"`)
+	require.Equal(t, 1, srv.CountRequests("DELETE", "/auth/token"))
+
+	cfg := readConfig(t, home)
+	require.NotContains(t, cfg, "saved-key")
+	require.Contains(t, cfg, "output: json", "other settings are kept")
+
+	res = runCLIAt(t, srv.URL, nil, "list")
+	require.Equal(t, 1, res.ExitCode)
+	require.Contains(t, res.Stderr, "authentication failed")
+
+	res = runCLIWithEnv(t, env, nil, "logout")
+	require.Equal(t, 0, res.ExitCode, res.Stderr)
+	require.Contains(t, res.Stdout, "Not logged in")
+}
+
+func TestLogout_RevokeFailureStillRemovesKey(t *testing.T) {
+	srv := apitest.NewServer(t, apitest.Options{})
+	srv.InjectFault("DELETE", "/auth/token", apitest.Fault{Status: 500})
+	home := writeConfig(t, "profiles:\n  default:\n    api_key: test-key\n")
+
+	res := runCLIWithEnv(t, []string{"HOME=" + home, "IRONS_API_URL=" + srv.URL}, nil, "logout", "--retries", "0")
+	require.Equal(t, 0, res.ExitCode, res.Stderr)
+	require.Contains(t, res.Stderr, "Warning:")
+	require.NotContains(t, readConfig(t, home), "test-key")
+}
+
+func TestLogout_Local(t *testing.T) {
+	srv := apitest.NewServer(t, apitest.Options{})
+	home := writeConfig(t, "profiles:\n  default:\n    api_key: test-key\n")
+
+	res := runCLIWithEnv(t, []string{"HOME=" + home, "IRONS_API_URL=" + srv.URL}, nil, "logout", "--local")
+	require.Equal(t, 0, res.ExitCode, res.Stderr)
+	require.Empty(t, srv.Requests())
+	require.NotContains(t, readConfig(t, home), "test-key")
+}
+
+func TestLogout_EnvKeyIsNotRemoved(t *testing.T) {
+	srv := apitest.NewServer(t, apitest.Options{})
+
+	res := runCLIAt(t, srv.URL, nil, "logout")
+	require.Equal(t, 0, res.ExitCode, res.Stderr)
+	require.Contains(t, res.Stdout, "Revoked the API key")
+	require.Contains(t, res.Stdout, "comes from env IRONS_API_KEY")
+}
+
+func TestLogout_ErasesFromCredentialHelper(t *testing.T) {
+	srv := apitest.NewServer(t, apitest.Options{})
+	h := newHelperSetup(t, srv, `{"api_key": "test-key"}`)
+
+	res := runCLIWithEnv(t, h.env, nil, "logout")
+	require.Equal(t, 0, res.ExitCode, res.Stderr)
+	require.Equal(t, []string{"get", "erase"}, h.actions(t))
+	require.Contains(t, res.Stdout, `Logged out of profile "default"`)
+}
+
+func TestLogin_WithTokenFromStdin(t *testing.T) {
+	srv := apitest.NewServer(t, apitest.Options{APIKeys: []string{"ci-token"}})
+	home := t.TempDir()
+	env := []string{"HOME=" + home, "IRONS_API_URL=" + srv.URL}
+
+	res := runCLIWithEnv(t, env, strings.NewReader("ci-token\n"), "login", "--with-token")
+	require.Equal(t, 0, res.ExitCode, res.Stderr)
+	require.Contains(t, res.Stdout, "Token is valid for Test User")
+	require.Contains(t, readConfig(t, home), "api_key: ci-token")
+	require.Empty(t, srv.DeviceCodes(), "the browser flow is not used")
+}
+
+func TestLogin_WithTokenFileNoSave(t *testing.T) {
+	srv := apitest.NewServer(t, apitest.Options{APIKeys: []string{"ci-token"}})
+	home := t.TempDir()
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(tokenFile, []byte("ci-token\n"), 0o600))
+
+	res := runCLIWithEnv(t, []string{"HOME=" + home, "IRONS_API_URL=" + srv.URL}, nil,
+		"login", "--token-file", tokenFile, "--no-save")
+	require.Equal(t, 0, res.ExitCode, res.Stderr)
+	require.Contains(t, res.Stdout, "Token is valid")
+	require.NoFileExists(t, filepath.Join(home, ".config", "irons", "config.yml"))
+}
+
+func TestLogin_WithTokenRejected(t *testing.T) {
+	srv := apitest.NewServer(t, apitest.Options{})
+	home := t.TempDir()
+
+	res := runCLIWithEnv(t, []string{"HOME=" + home, "IRONS_API_URL=" + srv.URL}, strings.NewReader("bogus\n"),
+		"login", "--with-token")
+	require.Equal(t, 1, res.ExitCode)
+	require.Contains(t, res.Stderr, "rejected the token")
+	require.NoFileExists(t, filepath.Join(home, ".config", "irons", "config.yml"))
+}
+
+func TestLogin_RefusesTokenArgument(t *testing.T) {
+	res := runCLIWithEnv(t, []string{"HOME=" + t.TempDir()}, nil, "login", "irons_secret_token")
+	require.Equal(t, 1, res.ExitCode)
+	require.Contains(t, res.Stderr, "--with-token")
+	require.NotContains(t, res.Stderr, "irons_secret_token")
+}
+
+// waitForDeviceCode returns the first device code issued by srv.
+func waitForDeviceCode(t *testing.T, srv *apitest.Server) string {
+	t.Helper()
+	var code string
+	require.Eventually(t, func() bool {
+		codes := srv.DeviceCodes()
+		if len(codes) > 0 {
+			code = codes[0]
+		}
+		return code != ""
+	}, 10*time.Second, 10*time.Millisecond)
+	return code
+}
+
+// fakeBrowser writes a browser script that records the URL it is opened
+// with, and returns the script and the file the URL is written to.
+func fakeBrowser(t *testing.T) (browser, opened string) {
+	t.Helper()
+	dir := t.TempDir()
+	browser = filepath.Join(dir, "browser.sh")
+	opened = filepath.Join(dir, "opened")
+	require.NoError(t, os.WriteFile(browser, []byte("#!/bin/sh\necho \"$1\" > "+opened+"\n"), 0o700))
+	return browser, opened
+}
+
+func TestLogin_OpensBrowserWithCode(t *testing.T) {
+	srv := apitest.NewServer(t, apitest.Options{})
+	browser, opened := fakeBrowser(t)
+	env := []string{"HOME=" + t.TempDir(), "IRONS_API_URL=" + srv.URL, "BROWSER=" + browser}
+
+	done := make(chan cliResult)
+	go func() { done <- runCLIWithEnv(t, env, nil, "login") }()
+
+	code := waitForDeviceCode(t, srv)
+	_, err := srv.AuthorizeDevice(code)
+	require.NoError(t, err)
+
+	res := <-done
+	require.Equal(t, 0, res.ExitCode, res.Stderr)
+	require.Contains(t, res.Stdout, "Opened the following URL")
+	require.Eventually(t, func() bool {
+		data, _ := os.ReadFile(opened)
+		return strings.TrimSpace(string(data)) == srv.URL+"/device?code="+code
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestLogin_NoBrowserHonoursSlowDown(t *testing.T) {
+	srv := apitest.NewServer(t, apitest.Options{})
+	browser, opened := fakeBrowser(t)
+	env := []string{"HOME=" + t.TempDir(), "IRONS_API_URL=" + srv.URL, "BROWSER=" + browser}
+
+	done := make(chan cliResult)
+	go func() { done <- runCLIWithEnv(t, env, nil, "login", "--no-browser") }()
+
+	code := waitForDeviceCode(t, srv)
+	require.True(t, srv.SlowDownDevice(code, 3))
+	require.Eventually(t, func() bool {
+		return srv.CountRequests("GET", "/auth/device/poll") > 0
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// The next poll comes three seconds after the slow_down, not one.
+	time.Sleep(2 * time.Second)
+	require.Equal(t, 1, srv.CountRequests("GET", "/auth/device/poll"))
+	_, err := srv.AuthorizeDevice(code)
+	require.NoError(t, err)
+
+	res := <-done
+	require.Equal(t, 0, res.ExitCode, res.Stderr)
+	require.Contains(t, res.Stdout, "Open the following URL in your browser")
+	require.Contains(t, res.Stdout, "polling every 3s")
+	require.NoFileExists(t, opened)
+}
+
+func TestLogin_StopsPollingWhenCodeExpires(t *testing.T) {
+	// The server's clock stands still, so it never reports the code as
+	// expired itself; the CLI must stop at the expiry it was given.
+	frozen := time.Now().Add(2*time.Second - 10*time.Minute)
+	srv := apitest.NewServer(t, apitest.Options{Now: func() time.Time { return frozen }})
+	env := []string{"HOME=" + t.TempDir(), "IRONS_API_URL=" + srv.URL}
+
+	res := runCLIWithEnv(t, env, nil, "login", "--no-browser")
+	require.Equal(t, 1, res.ExitCode)
+	require.Contains(t, res.Stderr, "device code expired")
+}
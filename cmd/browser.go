@@ -0,0 +1,38 @@
+package cmd
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"runtime"
+)
+
+// errNoBrowser is returned by openBrowser when there is no way to open one,
+// e.g. on a Linux machine without a display.
+var errNoBrowser = errors.New("no browser available")
+
+// openBrowser opens url in the user's default browser without waiting for
+// it. The command in $BROWSER is used instead of the platform's opener if
+// it is set.
+func openBrowser(url string) error {
+	var cmd *exec.Cmd
+	switch {
+	case os.Getenv("BROWSER") != "":
+		cmd = exec.Command(os.Getenv("BROWSER"), url)
+	case runtime.GOOS == "darwin":
+		cmd = exec.Command("open", url)
+	case runtime.GOOS == "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
+	default:
+		if os.Getenv("DISPLAY") == "" && os.Getenv("WAYLAND_DISPLAY") == "" {
+			return errNoBrowser
+		}
+		cmd = exec.Command("xdg-open", url)
+	}
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	go cmd.Wait()
+	return nil
+}
@@ -0,0 +1,277 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ironsh/irons/api"
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+// target is a VM that a lifecycle command acts on.
+type target struct {
+	ID string
+	// Name is how the VM is shown: its name, or the argument that named it.
+	Name string
+}
+
+// addTargetFlags adds the flags that choose the VMs a lifecycle command acts
+// on besides its arguments, and how many it acts on at once.
+func addTargetFlags(cmd *cobra.Command) {
+	addSelectorFlag(cmd)
+	cmd.Flags().Bool("all", false, "Act on every VM that is in a state to be acted on")
+	cmd.Flags().Int("parallel", 4, "Number of VMs to act on at once")
+}
+
+// targetVMs returns the VMs a command acts on: those named by its arguments,
+// or, with --all or --selector, every VM or those whose labels match, that
+// has not been destroyed and for which eligible returns true. Exactly one
+// of the three must be given, and if none match, out is told so. If exact
+// is set, arguments must be whole names or IDs, as resolveVMExact requires
+// for commands that cannot be undone. bulk
+// reports whether the VMs were given by more than one argument or by --all
+// or --selector, so that the command should report on them with runBulk.
+func targetVMs(cmd *cobra.Command, client api.VMService, args []string, eligible func(*api.VM) bool, exact bool, out io.Writer) (targets []target, bulk bool, err error) {
+	selector, _ := cmd.Flags().GetString("selector")
+	all, _ := cmd.Flags().GetBool("all")
+	ctx := cmd.Context()
+
+	given := 0
+	for _, b := range []bool{len(args) > 0, selector != "", all} {
+		if b {
+			given++
+		}
+	}
+	switch {
+	case given > 1:
+		return nil, false, errors.New("VMs, --all and --selector cannot be combined")
+	case given == 0:
+		return nil, false, errors.New("a VM, --all or --selector is required")
+	}
+
+	if len(args) > 0 {
+		seen := map[string]bool{}
+		resolve := resolveVM
+		if exact {
+			resolve = resolveVMExact
+		}
+		for _, arg := range args {
+			id, err := resolve(ctx, client, arg)
+			if err != nil {
+				return nil, false, err
+			}
+			if !seen[id] {
+				seen[id] = true
+				targets = append(targets, target{ID: id, Name: arg})
+			}
+		}
+		return targets, len(args) > 1, nil
+	}
+
+	vms, err := selectVMs(ctx, client, selector)
+	if err != nil {
+		return nil, false, err
+	}
+	for _, vm := range vms {
+		if eligible(&vm) {
+			targets = append(targets, target{ID: vm.ID, Name: vm.Name})
+		}
+	}
+	if len(targets) == 0 {
+		if all {
+			fmt.Fprintln(out, "No VMs to act on.")
+		} else {
+			fmt.Fprintf(out, "No VMs match selector %q.\n", selector)
+		}
+	}
+	return targets, true, nil
+}
+
+// bulkOp acts on one VM, writing any progress to out.
+type bulkOp func(ctx context.Context, id string, out io.Writer) error
+
+// bulkResult is the outcome of a bulkOp on one VM.
+type bulkResult struct {
+	started, done bool
+	err           error
+	elapsed       time.Duration
+}
+
+// runBulk runs op on every target, at most parallel at a time. Progress is
+// shown on out as each VM finishes, redrawn in place on terminals, followed
+// by a table of results. verb names what op does and past is its past
+// tense, e.g. "stop" and "stopped". It returns an error naming how many VMs
+// failed if any did.
+func runBulk(ctx context.Context, targets []target, parallel int, verb, past string, out io.Writer, op bulkOp) error {
+	if parallel < 1 {
+		return fmt.Errorf("--parallel must be at least 1")
+	}
+	if len(targets) == 0 {
+		return nil
+	}
+
+	results := make([]bulkResult, len(targets))
+	p := &bulkProgress{out: out, targets: targets, results: results, past: past}
+	if f, ok := out.(*os.File); ok && term.IsTerminal(int(f.Fd())) {
+		// Lines are redrawn by moving the cursor up over them, which cannot
+		// reach lines that have scrolled off, so on a terminal too short to
+		// show every VM a line is printed as each finishes instead.
+		width, height, err := term.GetSize(int(f.Fd()))
+		p.tty = err == nil && len(targets) < height
+		p.width = width
+	}
+	p.draw()
+
+	sem := make(chan struct{}, parallel)
+	var wg sync.WaitGroup
+	for i, t := range targets {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				p.update(i, func(r *bulkResult) { r.done, r.err = true, ctx.Err() })
+				return
+			}
+			defer func() { <-sem }()
+
+			p.update(i, func(r *bulkResult) { r.started = true })
+			start := time.Now()
+			err := op(ctx, t.ID, io.Discard)
+			p.update(i, func(r *bulkResult) { r.done, r.err, r.elapsed = true, err, time.Since(start) })
+		}()
+	}
+	wg.Wait()
+	p.clear()
+
+	table := tablewriter.NewTable(out)
+	table.Header([]string{"VM", "ID", "Result", "Time"})
+	var failed []string
+	for i, t := range targets {
+		r := results[i]
+		result := "✓ " + past
+		if r.err != nil {
+			result = "✗ " + r.err.Error()
+			failed = append(failed, fmt.Sprintf("  %s (%s): %v", t.Name, t.ID, r.err))
+		}
+		table.Append([]string{t.Name, t.ID, result, r.elapsed.Round(time.Second).String()})
+	}
+	table.Render()
+
+	if len(failed) > 0 {
+		fmt.Fprintf(out, "\n%d of %d VMs failed to %s:\n%s\n", len(failed), len(targets), verb, strings.Join(failed, "\n"))
+		return fmt.Errorf("%d of %d VMs failed to %s", len(failed), len(targets), verb)
+	}
+	fmt.Fprintf(out, "\n✓ %d VMs %s\n", len(targets), past)
+	return nil
+}
+
+// bulkProgress shows the progress of runBulk. On terminals tall enough,
+// every VM has a line that is redrawn as its state changes; otherwise a line
+// is printed as each VM finishes.
+type bulkProgress struct {
+	mu      sync.Mutex
+	out     io.Writer
+	targets []target
+	results []bulkResult
+	past    string
+	tty     bool
+	// width is the terminal's width. Redrawn lines are cut to fit, since a
+	// line that wraps would take more than one row.
+	width int
+	drawn int
+}
+
+// update applies f to the result of target i and shows the change.
+func (p *bulkProgress) update(i int, f func(*bulkResult)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	f(&p.results[i])
+	if p.tty {
+		p.redraw()
+	} else if r := p.results[i]; r.done {
+		fmt.Fprintln(p.out, p.line(i))
+	}
+}
+
+// draw shows the initial state on terminals.
+func (p *bulkProgress) draw() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.tty {
+		p.redraw()
+	}
+}
+
+// redraw replaces the lines drawn so far. It must be called with p.mu held.
+func (p *bulkProgress) redraw() {
+	if p.drawn > 0 {
+		fmt.Fprintf(p.out, "\x1b[%dA", p.drawn)
+	}
+	for i := range p.targets {
+		line := []rune(p.line(i))
+		if p.width > 1 && len(line) >= p.width {
+			line = append(line[:p.width-2], '…')
+		}
+		fmt.Fprintf(p.out, "\r\x1b[K%s\n", string(line))
+	}
+	p.drawn = len(p.targets)
+}
+
+// clear erases the lines drawn on terminals.
+func (p *bulkProgress) clear() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.drawn > 0 {
+		fmt.Fprintf(p.out, "\x1b[%dA\x1b[J", p.drawn)
+		p.drawn = 0
+	}
+}
+
+// line describes the state of target i.
+func (p *bulkProgress) line(i int) string {
+	t, r := p.targets[i], p.results[i]
+	switch {
+	case r.done && r.err != nil:
+		return fmt.Sprintf("✗ %s (%s): %v", t.Name, t.ID, r.err)
+	case r.done:
+		return fmt.Sprintf("✓ %s (%s): %s in %s", t.Name, t.ID, p.past, r.elapsed.Round(time.Second))
+	case r.started:
+		return fmt.Sprintf("… %s (%s): in progress", t.Name, t.ID)
+	default:
+		return fmt.Sprintf("  %s (%s): queued", t.Name, t.ID)
+	}
+}
+
+// printTargets prints the VMs a command acted on as they are now, if the
+// output is structured: a list if bulk, otherwise the one VM. VMs that no
+// longer exist are shown as destroyed.
+func printTargets(ctx context.Context, client api.VMService, output *printer, targets []target, bulk bool) error {
+	if !output.structured() {
+		return nil
+	}
+	vms := make([]api.VM, 0, len(targets))
+	for _, t := range targets {
+		vm, err := client.GetVMContext(ctx, t.ID)
+		if api.IsNotFound(err) {
+			vm, err = &api.VM{ID: t.ID, Status: "destroyed"}, nil
+		}
+		if err != nil {
+			return fmt.Errorf("getting VM: %w", err)
+		}
+		vms = append(vms, *vm)
+	}
+	if !bulk && len(vms) == 1 {
+		return output.print(vms[0], nil)
+	}
+	return output.print(vms, nil)
+}
@@ -0,0 +1,127 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ironsh/irons/api"
+	"github.com/ironsh/irons/apitest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunBulk_BoundsConcurrency(t *testing.T) {
+	var targets []target
+	for i := range 10 {
+		targets = append(targets, target{ID: fmt.Sprintf("vm_%d", i), Name: fmt.Sprintf("agent-%d", i)})
+	}
+
+	var running, peak atomic.Int32
+	err := runBulk(context.Background(), targets, 3, "stop", "stopped", io.Discard, func(ctx context.Context, id string, out io.Writer) error {
+		n := running.Add(1)
+		defer running.Add(-1)
+		for p := peak.Load(); n > p && !peak.CompareAndSwap(p, n); p = peak.Load() {
+		}
+		time.Sleep(20 * time.Millisecond)
+		if id == "vm_4" {
+			return errors.New("boom")
+		}
+		return nil
+	})
+	require.EqualError(t, err, "1 of 10 VMs failed to stop")
+	require.Equal(t, int32(3), peak.Load())
+}
+
+func TestBulk_StopsSeveralVMs(t *testing.T) {
+	srv := apitest.NewServer(t, apitest.Options{})
+	a := srv.AddVM(api.VM{Name: "a"})
+	b := srv.AddVM(api.VM{Name: "b"})
+	c := srv.AddVM(api.VM{Name: "c", Status: "stopped", StatusDetail: "stopped"})
+
+	res := runCLIAt(t, srv.URL, nil, "stop", "a", "b", "--parallel", "2")
+	require.Equal(t, 0, res.ExitCode, res.Stderr)
+	require.Contains(t, res.Stdout, "✓ a ("+a.ID+"): stopped")
+	require.Contains(t, res.Stdout, "✓ 2 VMs stopped")
+
+	// --all only picks VMs that can be started.
+	res = runCLIAt(t, srv.URL, nil, "start", "--all")
+	require.Equal(t, 0, res.ExitCode, res.Stderr)
+	require.Contains(t, res.Stdout, "✓ 3 VMs started")
+	for _, vm := range []api.VM{a, b, c} {
+		got, _ := srv.VM(vm.ID)
+		require.Equal(t, "running", got.Status)
+	}
+
+	// Without a terminal to ask on, destroying several VMs needs --yes.
+	res = runCLIAt(t, srv.URL, nil, "destroy", "--force", "--all")
+	require.Equal(t, 1, res.ExitCode)
+	require.Contains(t, res.Stdout, "These 3 VMs will be destroyed:\n  a ("+a.ID+")")
+	require.Contains(t, res.Stderr, "pass --yes to go ahead")
+	require.Equal(t, 0, srv.CountRequests("DELETE", "/vms/"+a.ID))
+
+	res = runCLIAt(t, srv.URL, nil, "destroy", "--force", "--all", "--yes")
+	require.Equal(t, 0, res.ExitCode, res.Stderr)
+	require.Contains(t, res.Stdout, "✓ 3 VMs destroyed")
+}
+
+func TestBulk_ReportsFailures(t *testing.T) {
+	srv := apitest.NewServer(t, apitest.Options{})
+	srv.AddVM(api.VM{Name: "a"})
+	b := srv.AddVM(api.VM{Name: "b", Status: "stopped", StatusDetail: "stopped"})
+
+	res := runCLIAt(t, srv.URL, nil, "stop", "a", "b")
+	require.Equal(t, 1, res.ExitCode)
+	require.Contains(t, res.Stdout, "1 of 2 VMs failed to stop:\n  b ("+b.ID+"): stopping VM:")
+	require.Contains(t, res.Stderr, "Error: 1 of 2 VMs failed to stop")
+
+	res = runCLIAt(t, srv.URL, nil, "stop", "--all", "a")
+	require.Equal(t, 1, res.ExitCode)
+	require.Contains(t, res.Stderr, "cannot be combined")
+
+	res = runCLIAt(t, srv.URL, nil, "stop", "--parallel", "0", "a", "b")
+	require.Equal(t, 1, res.ExitCode)
+	require.Contains(t, res.Stderr, "--parallel must be at least 1")
+}
+
+func TestDestroy_RequiresExactNameOrID(t *testing.T) {
+	srv := apitest.NewServer(t, apitest.Options{})
+	a := srv.AddVM(api.VM{Name: "agent-1", Status: "stopped", StatusDetail: "stopped"})
+	b := srv.AddVM(api.VM{Name: "builder", Status: "stopped", StatusDetail: "stopped"})
+
+	res := runCLIAt(t, srv.URL, nil, "destroy", "ag")
+	require.Equal(t, 1, res.ExitCode)
+	require.Contains(t, res.Stderr, `"ag" only partly matches VM `+a.ID+" (agent-1)")
+
+	res = runCLIAt(t, srv.URL, nil, "destroy", "builder", "ag")
+	require.Equal(t, 1, res.ExitCode)
+	require.Equal(t, 0, srv.CountRequests("DELETE", "/vms/"+b.ID))
+
+	// Prefixes still work for commands that can be undone.
+	res = runCLIAt(t, srv.URL, nil, "start", "ag")
+	require.Equal(t, 0, res.ExitCode, res.Stderr)
+
+	res = runCLIAt(t, srv.URL, nil, "destroy", "--force", a.ID, "builder")
+	require.Equal(t, 0, res.ExitCode, res.Stderr)
+	require.Contains(t, res.Stdout, "✓ 2 VMs destroyed")
+}
+
+func TestDestroy_ConfirmsSingleSelectedVM(t *testing.T) {
+	srv := apitest.NewServer(t, apitest.Options{})
+	a := srv.AddVM(api.VM{Name: "a", Labels: map[string]string{"ticket": "IR-42"}})
+	srv.AddVM(api.VM{Name: "b"})
+
+	// The user never named the VM, so even one is confirmed.
+	res := runCLIAt(t, srv.URL, nil, "destroy", "--force", "-l", "ticket=IR-42")
+	require.Equal(t, 1, res.ExitCode)
+	require.Contains(t, res.Stdout, "This VM will be destroyed:\n  a ("+a.ID+")")
+	require.Contains(t, res.Stderr, "destroy this VM needs confirmation")
+	require.Equal(t, 0, srv.CountRequests("DELETE", "/vms/"+a.ID))
+
+	res = runCLIAt(t, srv.URL, nil, "destroy", "--force", "-l", "ticket=IR-42", "-y")
+	require.Equal(t, 0, res.ExitCode, res.Stderr)
+	require.Contains(t, res.Stdout, "✓ 1 VMs destroyed")
+}
@@ -1,48 +1,197 @@
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
+	"sync"
 
 	"github.com/ironsh/irons/api"
+	"github.com/ironsh/irons/config"
 	"github.com/spf13/viper"
 )
 
-// requireAuth prints a descriptive error message when no API key is available
-// and exits with a non-zero status code. Call this whenever a command requires
-// authentication but none is configured.
-func requireAuth() {
-	fmt.Fprintf(os.Stderr, `Error: not authenticated.
-
-Run the following command to log in:
+// authHelp explains how to supply credentials. It is shared by requireAuth
+// and reportError so both failure modes give the same guidance.
+const authHelp = `Run the following command to log in:
 
   irons login
 
 Alternatively, supply your API key via the --api-key flag or the
 IRONS_API_KEY environment variable.
-`)
+`
+
+// requireAuth prints a descriptive error message when no API key is available
+// and exits with a non-zero status code. Call this whenever a command requires
+// authentication but none is configured. A key that is configured but
+// rejected by the API is reported by reportError instead.
+func requireAuth() {
+	cfg, err := config.Load()
+	if err != nil {
+		cfg = &config.Config{}
+	}
+	if name := viper.GetString("profile"); name != "" && cfg.Profile(name) == nil {
+		fmt.Fprintf(os.Stderr, "Error: profile %q does not exist.\n\nRun `irons login --profile %s` to create it.\n", name, name)
+		os.Exit(1)
+	}
+	fmt.Fprintf(os.Stderr, "Error: not authenticated: no API key is configured for profile %q.\n\n%s", activeProfile(cfg), authHelp)
 	os.Exit(1)
 }
 
-// newClient builds an api.Client from the current viper configuration.
-// It reads api-url, api-key, and debug-api so callers don't have to.
-func newClient() *api.Client {
-	return api.NewClientDebug(
+// reportError prints a command's error to stderr. API errors that indicate a
+// rejected API key say where the key came from and are followed by the login
+// guidance from requireAuth rather than being shown raw.
+func reportError(err error) {
+	if api.IsUnauthorized(err) {
+		fmt.Fprintf(os.Stderr, "Error: authentication failed: %v\n\nThe API key from %s was rejected; it may have expired or been revoked.\n%s", err, apiKeySource(), authHelp)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+}
+
+// apiKeySource describes where the API key in use comes from: a flag, an
+// environment variable, the config file or the credential helper.
+func apiKeySource() string {
+	if viper.GetString("api-key") == "" && credentialProcess() != nil {
+		return "the credential helper"
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		cfg = &config.Config{}
+	}
+	s, _ := lookupSetting("api_key")
+	return s.source(cfg, nil, activeProfile(cfg))
+}
+
+// ClientFactory builds the API services that commands talk to.
+type ClientFactory func() api.Services
+
+// clientFactory is the factory used by newClient. It is replaced through
+// SetClientFactory.
+var clientFactory ClientFactory = defaultClientFactory
+
+// defaultClientFactory builds an *api.Client from the global configuration.
+func defaultClientFactory() api.Services {
+	return newAPIClient()
+}
+
+// SetClientFactory replaces the factory commands use to obtain API services,
+// so programs and tests can run the irons commands against fakes instead of
+// the HTTP API. Passing nil restores the default, which builds an
+// *api.Client from the global flags and environment.
+func SetClientFactory(f ClientFactory) {
+	if f == nil {
+		f = defaultClientFactory
+	}
+	clientFactory = f
+}
+
+// newClient returns the API services for a command, built by the current
+// ClientFactory.
+func newClient() api.Services {
+	return clientFactory()
+}
+
+// newAnonymousClient returns API services that send no API key, for the
+// device authorization flow, which runs before any key exists. Services
+// from a custom ClientFactory are used as they are, since the flow does not
+// depend on the key they send.
+func newAnonymousClient() api.Services {
+	s := clientFactory()
+	if c, ok := s.(*api.Client); ok {
+		c.APIKey = ""
+		c.Credentials = nil
+	}
+	return s
+}
+
+// newClientWithKey returns API services that authenticate with key instead
+// of the configured API key or credential helper, e.g. to check a token
+// before it is saved. It fails if the current ClientFactory does not build
+// an *api.Client, whose key could not be replaced, rather than quietly
+// using the configured one.
+func newClientWithKey(key string) (api.Services, error) {
+	c, ok := clientFactory().(*api.Client)
+	if !ok {
+		return nil, errors.New("the API client in use cannot authenticate with a different API key")
+	}
+	c.APIKey = key
+	c.Credentials = nil
+	return c, nil
+}
+
+// credentialProcess returns the credential helper configured for the active
+// profile, or nil if there is none. It is created once so the key it
+// returns is cached for the rest of the process.
+var credentialProcess = sync.OnceValue(func() *config.CredentialProcess {
+	if command := viper.GetString("credential-process"); command != "" {
+		return config.NewCredentialProcess(command)
+	}
+	return nil
+})
+
+// newAPIClient builds an api.Client from the current viper configuration.
+// It reads api-url, api-key (falling back to the credential helper),
+// debug-api, the TLS and proxy settings, and retries so callers don't have
+// to, and identifies the CLI version to the server. An unusable TLS or proxy
+// setting is reported and exits, like requireAuth.
+func newAPIClient() *api.Client {
+	unsafe := viper.GetBool("debug-api-unsafe")
+	client := api.NewClientDebug(
 		viper.GetString("api-url"),
 		viper.GetString("api-key"),
-		viper.GetBool("debug-api"),
+		viper.GetBool("debug-api") || unsafe,
 		viper.GetBool("debug-tls-skip-verify"),
 	)
+	client.DebugUnsafe = unsafe
+	if p := credentialProcess(); p != nil && client.APIKey == "" {
+		client.Credentials = p
+	}
+	client.UserAgent = api.UserAgent(rootCmd.Version)
+	client.Notices = os.Stderr
+	err := client.ConfigureTransport(api.TransportOptions{
+		CACertFile:     viper.GetString("ca-cert"),
+		ClientCertFile: viper.GetString("client-cert"),
+		ClientKeyFile:  viper.GetString("client-key"),
+		Proxy:          viper.GetString("proxy"),
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	client.Retry.MaxRetries = max(viper.GetInt("retries"), 0)
+	return client
 }
 
 // resolveVM resolves a VM name or ID to a VM ID using the provided client.
-// If idOrName starts with "vm_" it is returned unchanged. Otherwise the list
-// VMs endpoint is queried by name and the first non-destroyed VM's ID is
-// returned. An error is returned if no matching VM is found.
-func resolveVM(client *api.Client, idOrName string) (string, error) {
-	id, err := client.ResolveVM(idOrName)
+// If idOrName is the ID of a VM it is returned unchanged. Otherwise it is
+// matched by exact name, then by unique name or ID prefix, among the
+// non-destroyed VMs. An error listing the candidates is returned if the match
+// is ambiguous, and one suggesting similar names if nothing matches. ctx
+// bounds the lookup requests.
+func resolveVM(ctx context.Context, client api.VMService, idOrName string) (string, error) {
+	id, err := client.ResolveVMContext(ctx, idOrName)
+	if err != nil {
+		return "", fmt.Errorf("resolving VM %q: %w", idOrName, err)
+	}
+	return id, nil
+}
+
+// resolveVMExact is like resolveVM, but fails unless idOrName is the whole
+// name or ID of the VM it resolves to, naming the VM that a prefix matched.
+// Commands that cannot be undone use it so a prefix never picks their VM.
+func resolveVMExact(ctx context.Context, client api.VMService, idOrName string) (string, error) {
+	id, err := resolveVM(ctx, client, idOrName)
+	if err != nil {
+		return "", err
+	}
+	vm, err := client.GetVMContext(ctx, id)
 	if err != nil {
 		return "", fmt.Errorf("resolving VM %q: %w", idOrName, err)
 	}
+	if vm.ID != idOrName && vm.Name != idOrName {
+		return "", fmt.Errorf("%q only partly matches VM %s (%s); give its full name or ID", idOrName, vm.ID, vm.Name)
+	}
 	return id, nil
 }
@@ -0,0 +1,305 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/ironsh/irons/config"
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// configCmd represents the config command
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Manage CLI configuration and profiles",
+	Long: `Manage the irons configuration stored in ~/.config/irons/config.yml.
+
+Settings are grouped into named profiles, each with its own API URL, API
+key, default SSH key and output format, so you can switch between IronCD
+accounts such as staging and production. The current profile is used unless
+--profile or IRONS_PROFILE selects another one. TLS and proxy settings are
+shared by every profile.
+
+A .irons.yml file in the working directory or one of its parents can set
+per-project defaults (name_prefix, ssh_key, egress_mode, output, retries and
+the egress rules the project needs), which take precedence over the config
+file. Flags and environment variables take precedence over both. Run 'irons
+config list' to see each effective value and which file it came from.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Help()
+	},
+}
+
+// configUseProfileCmd switches the current profile
+var configUseProfileCmd = &cobra.Command{
+	Use:   "use-profile <name>",
+	Short: "Set the current profile",
+	Long: `Set the profile used by every command that is not given --profile or
+IRONS_PROFILE. Profiles are created by logging in with --profile.
+
+Examples:
+  irons login --profile staging
+  irons config use-profile staging`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name := args[0]
+
+		cfg, err := config.Load()
+		if err != nil {
+			return err
+		}
+		if cfg.Profile(name) == nil {
+			return fmt.Errorf("profile %q does not exist; run `irons login --profile %s` to create it", name, name)
+		}
+
+		cfg.CurrentProfile = name
+		if err := config.Save(cfg); err != nil {
+			return fmt.Errorf("saving config: %w", err)
+		}
+
+		fmt.Printf("✓ Switched to profile %q\n", name)
+		return nil
+	},
+}
+
+// configProfilesCmd lists the configured profiles
+var configProfilesCmd = &cobra.Command{
+	Use:   "profiles",
+	Short: "List profiles",
+	Long: `List the configured profiles. The active profile is marked with *.
+
+Examples:
+  irons config profiles`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := config.Load()
+		if err != nil {
+			return err
+		}
+
+		names := cfg.ProfileNames()
+		if len(names) == 0 {
+			fmt.Println("No profiles found. Run `irons login` to create one.")
+			return nil
+		}
+
+		active := activeProfile(cfg)
+		table := tablewriter.NewTable(os.Stdout)
+		table.Header([]string{"", "Name", "API URL", "Authenticated"})
+		for _, name := range names {
+			p := cfg.Profile(name)
+			current := ""
+			if name == active {
+				current = "*"
+			}
+			apiURL := p.APIURL
+			if apiURL == "" {
+				apiURL = DefaultAPIURL
+			}
+			// A stored key takes precedence over the credential helper,
+			// which is not run here.
+			authenticated := "no"
+			switch {
+			case p.APIKey != "":
+				authenticated = "yes"
+			case p.CredentialProcess != "":
+				authenticated = "helper"
+			}
+			table.Append([]string{current, name, apiURL, authenticated})
+		}
+		table.Render()
+
+		return nil
+	},
+}
+
+// configGetCmd prints a setting
+var configGetCmd = &cobra.Command{
+	Use:   "get <key>",
+	Short: "Print the effective value of a setting",
+	Long: `Print the effective value of a setting, taking flags, environment
+variables and the config file into account. Secret settings such as
+api_key are masked unless --show-secret is given.
+
+Examples:
+  irons config get api_url
+  irons config get retries --profile staging
+  irons config get api_key --show-secret`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		s, err := lookupSetting(args[0])
+		if err != nil {
+			return err
+		}
+		if show, _ := cmd.Flags().GetBool("show-secret"); show {
+			fmt.Println(viper.GetString(s.Name))
+			return nil
+		}
+		fmt.Println(s.display())
+		return nil
+	},
+}
+
+// configSetCmd persists a setting
+var configSetCmd = &cobra.Command{
+	Use:   "set <key> [value]",
+	Short: "Save a setting to the config file",
+	Long: `Validate a setting and save it to the config file. Profile settings are
+saved to the active profile, which is created if needed.
+
+The value of a secret setting such as api_key is not taken as an
+argument, where it would be kept in shell history and shown by ps. It is
+read from stdin instead, and prompted for on a terminal.
+
+Examples:
+  irons config set retries 5
+  irons config set ssh_key ~/.ssh/work.pub
+  irons config set api_url https://api.staging.iron.sh/v1 --profile staging
+  pass show iron/staging | irons config set api_key --profile staging`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		s, err := lookupSetting(args[0])
+		if err != nil {
+			return err
+		}
+		var value string
+		switch {
+		case s.Secret && len(args) == 2:
+			return fmt.Errorf("%s is secret and cannot be given as an argument; pipe it to 'irons config set %s' or use 'irons login --with-token'", s.Key, s.Key)
+		case s.Secret:
+			if value, err = readToken(""); err != nil {
+				return err
+			}
+		case len(args) == 1:
+			return fmt.Errorf("a value for %s is required", s.Key)
+		default:
+			value = args[1]
+		}
+		if s.Validate != nil {
+			if value, err = s.Validate(value); err != nil {
+				return fmt.Errorf("invalid value for %s: %w", s.Key, err)
+			}
+		}
+
+		cfg, err := config.Load()
+		if err != nil {
+			return err
+		}
+		profile := activeProfile(cfg)
+		if err := cfg.Set(profile, s.Key, value); err != nil {
+			return err
+		}
+		if err := config.Save(cfg); err != nil {
+			return fmt.Errorf("saving config: %w", err)
+		}
+
+		shown := fmt.Sprintf("%q", value)
+		if s.Secret {
+			shown = mask(value)
+		}
+		fmt.Printf("✓ Set %s to %s%s\n", s.Key, shown, scopeSuffix(s.Key, profile))
+		return nil
+	},
+}
+
+// configUnsetCmd removes a setting
+var configUnsetCmd = &cobra.Command{
+	Use:   "unset <key>",
+	Short: "Remove a setting from the config file",
+	Long: `Remove a setting from the config file, so its default applies again.
+
+Examples:
+  irons config unset proxy`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		s, err := lookupSetting(args[0])
+		if err != nil {
+			return err
+		}
+
+		cfg, err := config.Load()
+		if err != nil {
+			return err
+		}
+		profile := activeProfile(cfg)
+		if err := cfg.Unset(profile, s.Key); err != nil {
+			return err
+		}
+		if err := config.Save(cfg); err != nil {
+			return fmt.Errorf("saving config: %w", err)
+		}
+
+		fmt.Printf("✓ Unset %s%s\n", s.Key, scopeSuffix(s.Key, profile))
+		return nil
+	},
+}
+
+// configListCmd lists every setting
+var configListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List settings and where their values come from",
+	Long: `List every setting with its effective value and its source: a flag, an
+environment variable, the project's .irons.yml, the config file, or the
+built-in default. Secret values are masked.
+
+Examples:
+  irons config list
+  irons config list --profile staging`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := config.Load()
+		if err != nil {
+			return err
+		}
+		proj, err := loadProject()
+		if err != nil {
+			return err
+		}
+		profile := activeProfile(cfg)
+
+		fmt.Printf("Profile: %s\n", profile)
+		if proj != nil {
+			fmt.Printf("Project: %s\n", proj.Path)
+		}
+		fmt.Println()
+		table := tablewriter.NewTable(os.Stdout)
+		table.Header([]string{"Key", "Value", "Source"})
+		for _, s := range settings {
+			table.Append([]string{s.Key, s.display(), s.source(cfg, proj, profile)})
+		}
+		table.Render()
+
+		return nil
+	},
+}
+
+// scopeSuffix describes where a setting is stored, for confirmation
+// messages.
+func scopeSuffix(key, profile string) string {
+	if scope, _ := config.KeyScope(key); scope == config.ProfileScope {
+		return fmt.Sprintf(" in profile %q", profile)
+	}
+	return " for all profiles"
+}
+
+// activeProfile returns the name of the profile selected by --profile,
+// IRONS_PROFILE or the current profile in cfg.
+func activeProfile(cfg *config.Config) string {
+	return cfg.ProfileName(viper.GetString("profile"))
+}
+
+func init() {
+	rootCmd.AddCommand(configCmd)
+
+	// Add subcommands
+	configCmd.AddCommand(configUseProfileCmd)
+	configCmd.AddCommand(configProfilesCmd)
+	configCmd.AddCommand(configGetCmd)
+	configCmd.AddCommand(configSetCmd)
+	configCmd.AddCommand(configUnsetCmd)
+	configCmd.AddCommand(configListCmd)
+
+	configGetCmd.Flags().Bool("show-secret", false, "Print secret settings such as api_key unmasked")
+}
@@ -0,0 +1,423 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ironsh/irons/api"
+	"github.com/ironsh/irons/apitest"
+	"github.com/stretchr/testify/require"
+)
+
+// writeConfig writes a config.yml into a fresh home directory and returns
+// the directory.
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	home := t.TempDir()
+	dir := filepath.Join(home, ".config", "irons")
+	require.NoError(t, os.MkdirAll(dir, 0o700))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "config.yml"), []byte(content), 0o600))
+	return home
+}
+
+// readConfig returns the contents of the config.yml in home.
+func readConfig(t *testing.T, home string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(home, ".config", "irons", "config.yml"))
+	require.NoError(t, err)
+	return string(data)
+}
+
+// hitServer reports whether srv received a request with the given API key.
+func hitServer(srv *apitest.Server, key string) bool {
+	for _, r := range srv.Requests() {
+		if r.Header.Get("Authorization") == "Bearer "+key {
+			return true
+		}
+	}
+	return false
+}
+
+func TestConfig_MigratesLegacyAPIKey(t *testing.T) {
+	srv := apitest.NewServer(t, apitest.Options{APIKeys: []string{"legacy-key"}})
+	home := writeConfig(t, "api_key: legacy-key\n")
+	env := []string{"HOME=" + home, "IRONS_API_URL=" + srv.URL}
+
+	res := runCLIWithEnv(t, env, nil, "list")
+	require.Equal(t, 0, res.ExitCode, res.Stderr)
+	require.True(t, hitServer(srv, "legacy-key"))
+
+	res = runCLIWithEnv(t, env, nil, "config", "profiles")
+	require.Equal(t, 0, res.ExitCode, res.Stderr)
+	require.Contains(t, res.Stdout, "default")
+
+	res = runCLIWithEnv(t, env, nil, "config", "use-profile", "default")
+	require.Equal(t, 0, res.ExitCode, res.Stderr)
+	cfg := readConfig(t, home)
+	require.Contains(t, cfg, "profiles:")
+	require.NotRegexp(t, `(?m)^api_key:`, cfg, "the legacy key is moved into the default profile")
+}
+
+func TestConfig_SelectsProfile(t *testing.T) {
+	staging := apitest.NewServer(t, apitest.Options{APIKeys: []string{"staging-key"}})
+	production := apitest.NewServer(t, apitest.Options{APIKeys: []string{"production-key"}})
+	home := writeConfig(t, `current_profile: production
+profiles:
+  staging:
+    api_url: `+staging.URL+`
+    api_key: staging-key
+  production:
+    api_url: `+production.URL+`
+    api_key: production-key
+`)
+	env := []string{"HOME=" + home}
+
+	res := runCLIWithEnv(t, env, nil, "list")
+	require.Equal(t, 0, res.ExitCode, res.Stderr)
+	require.True(t, hitServer(production, "production-key"))
+	require.Empty(t, staging.Requests())
+
+	res = runCLIWithEnv(t, env, nil, "list", "--profile", "staging")
+	require.Equal(t, 0, res.ExitCode, res.Stderr)
+	require.True(t, hitServer(staging, "staging-key"))
+
+	res = runCLIWithEnv(t, append(env, "IRONS_PROFILE=staging"), nil, "list")
+	require.Equal(t, 0, res.ExitCode, res.Stderr)
+	require.Len(t, staging.Requests(), 2)
+
+	res = runCLIWithEnv(t, append(env, "IRONS_API_KEY=staging-key"), nil, "list", "--profile", "staging")
+	require.Equal(t, 0, res.ExitCode, "environment variables override the profile: %s", res.Stderr)
+
+	res = runCLIWithEnv(t, env, nil, "config", "use-profile", "staging")
+	require.Equal(t, 0, res.ExitCode, res.Stderr)
+	res = runCLIWithEnv(t, env, nil, "list")
+	require.Equal(t, 0, res.ExitCode, res.Stderr)
+	require.Len(t, staging.Requests(), 4)
+
+	res = runCLIWithEnv(t, env, nil, "config", "use-profile", "qa")
+	require.Equal(t, 1, res.ExitCode)
+	require.Contains(t, res.Stderr, `profile "qa" does not exist`)
+
+	res = runCLIWithEnv(t, env, nil, "list", "--profile", "qa")
+	require.Equal(t, 1, res.ExitCode)
+	require.Contains(t, res.Stderr, "irons login --profile qa")
+}
+
+func TestConfig_ProfileSSHKey(t *testing.T) {
+	srv := apitest.NewServer(t, apitest.Options{})
+	key := writeTestKey(t)
+	home := writeConfig(t, `profiles:
+  default:
+    api_key: test-key
+    ssh_key: `+key+`
+`)
+
+	res := runCLIWithEnv(t, []string{"HOME=" + home, "IRONS_API_URL=" + srv.URL}, nil, "create", "--async", "agent-1")
+	require.Equal(t, 0, res.ExitCode, res.Stderr)
+	vms, err := api.Collect(srv.Client().AllVMs(t.Context()), 0)
+	require.NoError(t, err)
+	require.Len(t, vms, 1)
+}
+
+func TestLogin_WritesToProfile(t *testing.T) {
+	srv := apitest.NewServer(t, apitest.Options{})
+	home := writeConfig(t, "api_key: production-key\n")
+
+	done := make(chan cliResult)
+	go func() {
+		done <- runCLIWithEnv(t, []string{"HOME=" + home}, nil, "login", "--profile", "staging", "--api-url", srv.URL)
+	}()
+
+	var token string
+	require.Eventually(t, func() bool {
+		codes := srv.DeviceCodes()
+		if len(codes) == 0 {
+			return false
+		}
+		var err error
+		token, err = srv.AuthorizeDevice(codes[0])
+		return err == nil
+	}, 10*time.Second, 10*time.Millisecond)
+
+	res := <-done
+	require.Equal(t, 0, res.ExitCode, res.Stderr)
+	require.Contains(t, res.Stdout, `profile "staging"`)
+
+	cfg := readConfig(t, home)
+	require.Contains(t, cfg, "api_key: production-key", "the default profile is kept")
+	require.Contains(t, cfg, "current_profile: default")
+	require.Contains(t, cfg, "api_key: "+token)
+	require.Contains(t, cfg, "api_url: "+srv.URL)
+
+	res = runCLIWithEnv(t, []string{"HOME=" + home}, nil, "list", "--profile", "staging")
+	require.Equal(t, 0, res.ExitCode, res.Stderr)
+	require.True(t, hitServer(srv, token))
+}
+
+func TestConfig_SetGetUnset(t *testing.T) {
+	home := writeConfig(t, "profiles:\n  default:\n    api_key: test-key\n")
+	env := []string{"HOME=" + home}
+
+	res := runCLIWithEnv(t, env, nil, "config", "set", "retries", "5")
+	require.Equal(t, 0, res.ExitCode, res.Stderr)
+	require.Contains(t, readConfig(t, home), "retries: 5")
+
+	res = runCLIWithEnv(t, env, nil, "config", "get", "retries")
+	require.Equal(t, "5\n", res.Stdout)
+	res = runCLIWithEnv(t, append(env, "IRONS_RETRIES=2"), nil, "config", "get", "retries")
+	require.Equal(t, "2\n", res.Stdout, "environment variables override the file")
+	res = runCLIWithEnv(t, append(env, "IRONS_RETRIES=2"), nil, "config", "get", "retries", "--retries", "1")
+	require.Equal(t, "1\n", res.Stdout, "flags override environment variables")
+
+	proxy := "http://proxy.example:3128"
+	res = runCLIWithEnv(t, env, nil, "config", "set", "proxy", proxy)
+	require.Equal(t, 0, res.ExitCode, res.Stderr)
+	require.Contains(t, res.Stdout, "for all profiles")
+	require.Regexp(t, `(?m)^proxy: `+proxy, readConfig(t, home))
+
+	res = runCLIWithEnv(t, env, nil, "config", "unset", "retries")
+	require.Equal(t, 0, res.ExitCode, res.Stderr)
+	require.NotContains(t, readConfig(t, home), "retries")
+	res = runCLIWithEnv(t, env, nil, "config", "get", "retries")
+	require.Equal(t, "3\n", res.Stdout)
+}
+
+func TestConfig_SetValidates(t *testing.T) {
+	home := writeConfig(t, "")
+	env := []string{"HOME=" + home}
+
+	for _, tc := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"bogus", "1"}, `unknown config key "bogus"`},
+		{[]string{"--", "retries", "-1"}, "non-negative integer"},
+		{[]string{"output", "xml"}, "must be one of"},
+		{[]string{"egress_mode", "block"}, "must be one of enforce, warn"},
+		{[]string{"api_url", "api.iron.sh"}, "not an http or https URL"},
+		{[]string{"ca_cert", filepath.Join(home, "missing.pem")}, "no such file"},
+		{[]string{"proxy", "ftp://proxy.example"}, "unsupported scheme"},
+		{[]string{"retries"}, "a value for retries is required"},
+		{[]string{"api_key", "secret-key"}, "cannot be given as an argument"},
+	} {
+		res := runCLIWithEnv(t, env, nil, append([]string{"config", "set"}, tc.args...)...)
+		require.Equal(t, 1, res.ExitCode, tc.args)
+		require.Contains(t, res.Stderr, tc.want, tc.args)
+	}
+	require.Empty(t, readConfig(t, home), "nothing is saved")
+}
+
+func TestConfig_SetReadsSecretFromStdin(t *testing.T) {
+	home := writeConfig(t, "")
+	env := []string{"HOME=" + home}
+
+	res := runCLIWithEnv(t, env, strings.NewReader("staging-secret-key\n"), "config", "set", "api_key", "--profile", "staging")
+	require.Equal(t, 0, res.ExitCode, res.Stderr)
+	require.Contains(t, res.Stdout, "✓ Set api_key to ********-key")
+	require.NotContains(t, res.Stdout, "staging-secret-key")
+	require.Contains(t, readConfig(t, home), "api_key: staging-secret-key")
+
+	res = runCLIWithEnv(t, env, nil, "config", "get", "api_key", "--profile", "staging")
+	require.Equal(t, "********-key\n", res.Stdout)
+	res = runCLIWithEnv(t, env, nil, "config", "get", "api_key", "--profile", "staging", "--show-secret")
+	require.Equal(t, "staging-secret-key\n", res.Stdout)
+
+	res = runCLIWithEnv(t, env, strings.NewReader(""), "config", "set", "api_key")
+	require.Equal(t, 1, res.ExitCode)
+	require.Contains(t, res.Stderr, "no token provided")
+}
+
+func TestConfig_ListShowsSources(t *testing.T) {
+	key := writeTestKey(t)
+	home := writeConfig(t, `proxy: http://proxy.example:3128
+profiles:
+  default:
+    api_key: secret-api-key-1234
+    ssh_key: `+key+`
+`)
+
+	res := runCLIWithEnv(t, []string{"HOME=" + home, "IRONS_API_URL=http://localhost:1"}, nil,
+		"config", "list", "--retries", "0")
+	require.Equal(t, 0, res.ExitCode, res.Stderr)
+
+	configPath := filepath.Join(home, ".config", "irons", "config.yml")
+	rows := map[string]string{}
+	for _, line := range strings.Split(res.Stdout, "\n") {
+		fields := strings.Split(line, "│")
+		if len(fields) == 5 {
+			rows[strings.TrimSpace(fields[1])] = strings.TrimSpace(fields[2]) + " | " + strings.TrimSpace(fields[3])
+		}
+	}
+	require.Equal(t, "http://localhost:1 | env IRONS_API_URL", rows["api_url"])
+	require.Equal(t, "********1234 | file "+configPath+" (profile default)", rows["api_key"])
+	require.Equal(t, key+" | file "+configPath+" (profile default)", rows["ssh_key"])
+	require.Equal(t, "0 | flag --retries", rows["retries"])
+	require.Equal(t, "http://proxy.example:3128 | file "+configPath, rows["proxy"])
+	require.Equal(t, " | default", rows["output"])
+	require.NotContains(t, res.Stdout, "secret-api-key")
+}
+
+func TestConfig_EgressModeAppliedOnCreate(t *testing.T) {
+	srv := apitest.NewServer(t, apitest.Options{})
+	home := writeConfig(t, "profiles:\n  default:\n    api_key: test-key\n    egress_mode: warn\n")
+	env := []string{"HOME=" + home, "IRONS_API_URL=" + srv.URL}
+
+	res := runCLIWithEnv(t, env, nil, "create", "--async", "--key", writeTestKey(t), "agent-1")
+	require.Equal(t, 0, res.ExitCode, res.Stderr)
+	require.Contains(t, res.Stdout, "Egress mode: warn")
+
+	id, err := srv.Client().ResolveVM("agent-1")
+	require.NoError(t, err)
+	policy, err := srv.Client().VMEgressGetPolicy(id)
+	require.NoError(t, err)
+	require.Equal(t, "warn", policy.Mode)
+}
+
+// writeProject writes a .irons.yml into a fresh directory and returns a
+// subdirectory of it to run the CLI in.
+func writeProject(t *testing.T, content string) (root, subdir string) {
+	t.Helper()
+	root = t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(root, ".irons.yml"), []byte(content), 0o644))
+	subdir = filepath.Join(root, "src", "app")
+	require.NoError(t, os.MkdirAll(subdir, 0o755))
+	return root, subdir
+}
+
+func TestProject_DefaultsCreate(t *testing.T) {
+	srv := apitest.NewServer(t, apitest.Options{})
+	key := writeTestKey(t)
+	root, dir := writeProject(t, `name_prefix: acme-
+ssh_key: keys/dev.pub
+egress_mode: warn
+`)
+	keyData, err := os.ReadFile(key)
+	require.NoError(t, err)
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "keys"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "keys", "dev.pub"), keyData, 0o644))
+
+	// The global config points at a key that does not exist; the project's
+	// key, relative to .irons.yml, takes precedence.
+	home := writeConfig(t, "profiles:\n  default:\n    api_key: test-key\n    ssh_key: /nonexistent.pub\n    egress_mode: enforce\n")
+	env := []string{"HOME=" + home, "IRONS_API_URL=" + srv.URL}
+
+	res := runCLIIn(t, dir, env, nil, "create", "--async", "web")
+	require.Equal(t, 0, res.ExitCode, res.Stderr)
+	require.Contains(t, res.Stdout, "Creating VM 'acme-web'")
+	require.Contains(t, res.Stdout, "Egress mode: warn")
+
+	res = runCLIIn(t, dir, env, nil, "create", "--async")
+	require.Equal(t, 0, res.ExitCode, res.Stderr)
+	require.Contains(t, res.Stdout, "Creating VM 'acme-")
+
+	vms, err := api.Collect(srv.Client().AllVMs(t.Context()), 0)
+	require.NoError(t, err)
+	require.Len(t, vms, 2)
+	for _, vm := range vms {
+		require.True(t, strings.HasPrefix(vm.Name, "acme-"), vm.Name)
+	}
+
+	// Environment variables take precedence over the project.
+	res = runCLIIn(t, dir, append(env, "IRONS_NAME_PREFIX=other-"), nil, "create", "--async", "web")
+	require.Equal(t, 0, res.ExitCode, res.Stderr)
+	require.Contains(t, res.Stdout, "Creating VM 'other-web'")
+}
+
+func TestProject_DefaultsUploads(t *testing.T) {
+	srv := apitest.NewServer(t, apitest.Options{})
+	env, log := fakeSSH(t, srv)
+	root, dir := writeProject(t, `upload:
+  - dotfiles:.
+`)
+	require.NoError(t, os.Mkdir(filepath.Join(root, "dotfiles"), 0o755))
+	other := t.TempDir()
+
+	// The local path is relative to .irons.yml, not the working directory.
+	res := runCLIIn(t, dir, env, nil, "create", "agent-1")
+	require.Equal(t, 0, res.ExitCode, res.Stderr)
+	require.Contains(t, res.Stdout, "==> [1/1] upload "+filepath.Join(root, "dotfiles")+" -> .")
+	require.Contains(t, readLog(t, log), "-- "+filepath.Join(root, "dotfiles")+" iron@127.0.0.1:.")
+
+	// --upload replaces the project's list.
+	res = runCLIIn(t, dir, env, nil, "create", "agent-2", "--upload", other+":/srv")
+	require.Equal(t, 0, res.ExitCode, res.Stderr)
+	require.Contains(t, res.Stdout, "==> [1/1] upload "+other+" -> /srv")
+	require.NotContains(t, res.Stdout, "dotfiles")
+
+	// --async does not wait to upload, so the list is skipped.
+	res = runCLIIn(t, dir, env, nil, "create", "--async", "agent-3")
+	require.Equal(t, 0, res.ExitCode, res.Stderr)
+	require.Contains(t, res.Stdout, "Not uploading the files listed in .irons.yml")
+
+	_, dir = writeProject(t, "upload:\n  - dotfiles\n")
+	res = runCLIIn(t, dir, env, nil, "create", "agent-4")
+	require.Equal(t, 1, res.ExitCode)
+	require.Contains(t, res.Stderr, `upload "dotfiles" must be local:remote`)
+}
+
+func TestProject_RequiresNameWithoutPrefix(t *testing.T) {
+	res := runCLIWithEnv(t, []string{"HOME=" + t.TempDir(), "IRONS_API_KEY=test-key"}, nil,
+		"create", "--key", writeTestKey(t))
+	require.Equal(t, 1, res.ExitCode)
+	require.Contains(t, res.Stderr, "VM name is required")
+}
+
+func TestProject_MissingEgressRules(t *testing.T) {
+	srv := apitest.NewServer(t, apitest.Options{})
+	_, err := srv.Client().EgressCreateRule(api.EgressRuleRequest{Host: "github.com"})
+	require.NoError(t, err)
+	_, dir := writeProject(t, `egress:
+  - host: github.com
+  - host: proxy.golang.org
+    comment: Go modules
+  - cidr: 10.0.0.0/8
+`)
+	env := []string{"HOME=" + t.TempDir(), "IRONS_API_URL=" + srv.URL, "IRONS_API_KEY=test-key"}
+
+	// Without the opt-in, missing rules are only listed.
+	res := runCLIIn(t, dir, env, nil, "create", "--async", "--key", writeTestKey(t), "agent-1")
+	require.Equal(t, 1, res.ExitCode)
+	require.Contains(t, res.Stdout, "+ proxy.golang.org")
+	require.Contains(t, res.Stdout, "+ 10.0.0.0/8")
+	require.NotContains(t, res.Stdout, "+ github.com")
+	require.Contains(t, res.Stderr, "2 egress rule(s) from .irons.yml are missing")
+	require.Len(t, srv.EgressRules(), 1)
+	require.Equal(t, 0, srv.CountRequests("POST", "/vms"))
+
+	res = runCLIIn(t, dir, env, nil, "create", "--async", "--apply-project-egress", "--key", writeTestKey(t), "agent-1")
+	require.Equal(t, 0, res.ExitCode, res.Stderr)
+	require.Contains(t, res.Stdout, "for proxy.golang.org")
+	require.Contains(t, res.Stdout, "for 10.0.0.0/8")
+	require.NotContains(t, res.Stdout, "for github.com")
+
+	var targets []string
+	for _, r := range srv.EgressRules() {
+		targets = append(targets, r.Host+r.CIDR)
+	}
+	require.ElementsMatch(t, []string{"github.com", "proxy.golang.org", "10.0.0.0/8"}, targets)
+}
+
+func TestProject_ConfigListShowsFile(t *testing.T) {
+	root, dir := writeProject(t, "retries: 7\noutput: json\n")
+	home := writeConfig(t, "profiles:\n  default:\n    output: yaml\n")
+
+	res := runCLIIn(t, dir, []string{"HOME=" + home}, nil, "config", "list")
+	require.Equal(t, 0, res.ExitCode, res.Stderr)
+
+	projectPath := filepath.Join(root, ".irons.yml")
+	require.Contains(t, res.Stdout, "Project: "+projectPath)
+	require.Regexp(t, `retries\s+│\s+7\s+│\s+file `+regexp.QuoteMeta(projectPath), res.Stdout)
+	require.Regexp(t, `output\s+│\s+json\s+│\s+file `+regexp.QuoteMeta(projectPath), res.Stdout)
+}
+
+func TestProject_RejectsUnknownKeys(t *testing.T) {
+	_, dir := writeProject(t, "api_key: committed-by-mistake\n")
+
+	res := runCLIIn(t, dir, []string{"HOME=" + t.TempDir()}, nil, "config", "list")
+	require.Equal(t, 1, res.ExitCode)
+	require.Contains(t, res.Stderr, "field api_key not found")
+}
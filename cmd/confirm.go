@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// errNotConfirmed is returned by confirm when the user does not answer yes.
+var errNotConfirmed = errors.New("cancelled")
+
+// stdinIsTerminal reports whether the user can be asked questions.
+func stdinIsTerminal() bool {
+	return term.IsTerminal(int(os.Stdin.Fd()))
+}
+
+// confirm asks the user on stderr whether to go ahead with what, reading
+// the answer from stdin; only "yes" goes ahead. When stdin is not a
+// terminal there is no one to ask, so it fails, naming flag, which the
+// caller accepts instead of the answer.
+func confirm(what, flag string) error {
+	if !stdinIsTerminal() {
+		return fmt.Errorf("%s needs confirmation, but stdin is not a terminal; pass %s to go ahead", what, flag)
+	}
+
+	fmt.Fprintf(os.Stderr, "\nDo you want to %s? Only 'yes' will be accepted: ", what)
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && answer == "" {
+		return fmt.Errorf("reading confirmation: %w", err)
+	}
+	if strings.TrimSpace(answer) != "yes" {
+		return errNotConfirmed
+	}
+	return nil
+}
@@ -1,16 +1,25 @@
 package cmd
 
 import (
+	"context"
+	"crypto/rand"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"strings"
 
+	"github.com/ironsh/irons/api"
+	"github.com/ironsh/irons/config"
+	"github.com/ironsh/irons/labels"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 )
 
 // createCmd represents the create command
 var createCmd = &cobra.Command{
-	Use:   "create <name>",
+	Use:   "create [name]",
 	Short: "Create a new VM",
 	Long: `Create a new VM with the specified configuration.
 
@@ -22,23 +31,102 @@ returning. Pass --async to return immediately after the create
 request is accepted.
 
 SSH Key Detection:
-  If --key is not provided, the following key files are checked in
-  order and the first one found is used:
+  If --key is not provided, the ssh_key setting (see irons config) is
+  used. Otherwise the following key files are checked in order and the
+  first one found is used:
     ~/.ssh/id_ed25519.pub
     ~/.ssh/id_ed25519_sk.pub
     ~/.ssh/id_ecdsa.pub
     ~/.ssh/id_ecdsa_sk.pub
     ~/.ssh/id_rsa.pub
 
+Project Defaults:
+  Inside a project with a .irons.yml file, its ssh_key, egress_mode and
+  name_prefix are used unless overridden by flags or environment
+  variables. The name prefix is prepended to the VM name, and a name is
+  generated if none is given. Its upload list is used if --upload is
+  not given. If the project file lists egress rules that the account
+  does not have, they are shown and create fails; --apply-project-egress
+  adds them to the account's allowlist, which applies to every VM.
+
+Provisioning:
+  Once the VM is ready, files given with --upload local:remote are
+  copied to it, and then scripts given with --provision are copied over
+  and run, each in the order given. Their output is streamed. If a step
+  fails, the remaining steps are skipped, a report of the steps is
+  printed, and create fails, leaving the VM running for investigation.
+  An empty remote path means the home directory.
+
 Examples:
   irons create my-vm
+  irons create              # inside a project with name_prefix set
   irons create --async my-vm
-  irons create --key ~/.ssh/my_key.pub my-vm`,
-	Args: cobra.ExactArgs(1),
+  irons create --key ~/.ssh/my_key.pub my-vm
+  irons create --egress-mode warn my-vm
+  irons create --label ticket=IR-42 --label agent=claude my-vm
+  irons create --upload ./dotfiles:. --provision setup.sh my-vm`,
+	Args: cobra.RangeArgs(0, 1),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		keyPath, _ := cmd.Flags().GetString("key")
-		name := args[0]
+		// --key and --egress-mode are bound to the ssh_key and egress_mode
+		// settings, so they can default from the config files.
+		keyPath := viper.GetString("ssh-key")
+		egressMode := viper.GetString("egress-mode")
 		async, _ := cmd.Flags().GetBool("async")
+		uploads, _ := cmd.Flags().GetStringArray("upload")
+		scripts, _ := cmd.Flags().GetStringArray("provision")
+		labelArgs, _ := cmd.Flags().GetStringArray("label")
+		applyEgress, _ := cmd.Flags().GetBool("apply-project-egress")
+		wait, err := waitFlags(cmd)
+		if err != nil {
+			return err
+		}
+		output, err := newPrinter()
+		if err != nil {
+			return err
+		}
+		msgs := output.messages()
+
+		var name string
+		if len(args) > 0 {
+			name = args[0]
+		}
+		name, err = vmName(name, viper.GetString("name-prefix"))
+		if err != nil {
+			return err
+		}
+
+		if egressMode != "" && egressMode != "enforce" && egressMode != "warn" {
+			return fmt.Errorf("invalid egress mode %q: must be enforce or warn", egressMode)
+		}
+
+		vmLabels := map[string]string{}
+		for _, arg := range labelArgs {
+			key, value, err := labels.ParsePair(arg)
+			if err != nil {
+				return err
+			}
+			vmLabels[key] = value
+		}
+
+		proj, err := loadProject()
+		if err != nil {
+			return err
+		}
+		if proj != nil && len(proj.Upload) > 0 && !cmd.Flags().Changed("upload") {
+			if async {
+				fmt.Fprintf(msgs, "Not uploading the files listed in %s, since --async does not wait for the VM.\n", config.ProjectFile)
+			} else {
+				uploads = proj.Upload
+			}
+		}
+
+		steps, err := provisionSteps(uploads, scripts)
+		if err != nil {
+			return err
+		}
+		if async && len(steps) > 0 {
+			return fmt.Errorf("--async cannot be used with --upload or --provision")
+		}
 
 		// Read SSH key file
 		keyContent, err := os.ReadFile(keyPath)
@@ -48,35 +136,71 @@ Examples:
 
 		// Create API client
 		client := newClient()
+		ctx := cmd.Context()
+
+		if proj != nil {
+			if err := checkProjectEgress(ctx, client, proj, applyEgress, msgs); err != nil {
+				return err
+			}
+		}
 
 		// Show what we're creating
-		fmt.Printf("Creating VM '%s'...\n", name)
+		fmt.Fprintf(msgs, "Creating VM '%s'...\n", name)
 
 		// Make API call
-		resp, err := client.Create(keyContent, name)
+		resp, err := client.CreateVMContext(ctx, api.CreateRequest{
+			PublicKey: string(keyContent),
+			Name:      name,
+			Labels:    vmLabels,
+		})
 		if err != nil {
 			return fmt.Errorf("creating VM: %w", err)
 		}
 
 		// Show initial response
-		fmt.Printf("✓ VM created successfully!\n")
-		fmt.Printf("  ID: %s\n", resp.ID)
-		fmt.Printf("  Name: %s\n", resp.Name)
-		fmt.Printf("  Status: %s\n", resp.Status)
+		fmt.Fprintf(msgs, "✓ VM created successfully!\n")
+		fmt.Fprintf(msgs, "  ID: %s\n", resp.ID)
+		fmt.Fprintf(msgs, "  Name: %s\n", resp.Name)
+		fmt.Fprintf(msgs, "  Status: %s\n", resp.Status)
 		if resp.StatusDetail != "" {
-			fmt.Printf("  Detail: %s\n", resp.StatusDetail)
+			fmt.Fprintf(msgs, "  Detail: %s\n", resp.StatusDetail)
+		}
+		if len(resp.Labels) > 0 {
+			fmt.Fprintf(msgs, "  Labels: %s\n", labels.Format(resp.Labels))
+		}
+
+		if egressMode != "" {
+			if err := client.VMEgressSetPolicyContext(ctx, resp.ID, egressMode); err != nil {
+				return fmt.Errorf("setting egress mode: %w", err)
+			}
+			fmt.Fprintf(msgs, "  Egress mode: %s\n", egressMode)
 		}
 
 		if async {
-			return nil
+			// The VM has been described above for people.
+			return output.print(resp, func() {})
 		}
 
-		if err := waitForVMCond(cmd.Context(), client, resp.ID, statusAndDetailEq("running", "ready")); err != nil {
+		if err := waitForVM(ctx, client, resp.ID, statusAndDetailEq("running", "ready"), wait); err != nil {
 			return err
 		}
 
-		fmt.Printf("✓ VM '%s' is ready!\n", name)
-		return nil
+		if len(steps) > 0 {
+			if err := provision(ctx, client, resp.ID, steps, msgs); err != nil {
+				fmt.Fprintf(msgs, "VM '%s' is running but was not fully provisioned. Connect with 'irons ssh %s' to investigate.\n", name, name)
+				return err
+			}
+		}
+
+		fmt.Fprintf(msgs, "✓ VM '%s' is ready!\n", name)
+		if !output.structured() {
+			return nil
+		}
+		vm, err := client.GetVMContext(ctx, resp.ID)
+		if err != nil {
+			return fmt.Errorf("getting VM: %w", err)
+		}
+		return output.print(vm, func() {})
 	},
 }
 
@@ -112,7 +236,87 @@ func init() {
 
 	// Define flags
 	createCmd.Flags().StringP("key", "k", defaultKeyPath, "SSH public key path")
+	createCmd.Flags().String("egress-mode", "", "Egress mode for the new VM: enforce or warn (default: the account's mode)")
 	createCmd.Flags().Bool("async", false, "Return immediately without waiting for the VM to reach the running state")
+	addWaitFlags(createCmd, "the VM to be ready")
+	createCmd.Flags().Bool("apply-project-egress", false, "Add egress rules listed in .irons.yml that the account is missing")
+	createCmd.Flags().StringArray("label", nil, "Label the VM with key=value (repeatable)")
+	createCmd.Flags().StringArray("upload", nil, "Copy a local file or directory to the VM once it is ready, as local:remote (repeatable)")
+	createCmd.Flags().StringArray("provision", nil, "Run a local script on the VM once it is ready, after any uploads (repeatable)")
+}
+
+// vmName applies the name prefix to name, generating a name from the prefix
+// if name is empty. Names that already start with the prefix are kept as
+// they are.
+func vmName(name, prefix string) (string, error) {
+	if name == "" {
+		if prefix == "" {
+			return "", errors.New("a VM name is required (or set name_prefix to generate one)")
+		}
+		return prefix + strings.ToLower(rand.Text()[:6]), nil
+	}
+	if strings.HasPrefix(name, prefix) {
+		return name, nil
+	}
+	return prefix + name, nil
+}
+
+// checkProjectEgress compares the egress rules listed in the project file
+// with the account's, matching them by host or CIDR. The egress allowlist
+// applies to every VM on the account, so rules the account is missing are
+// only added if apply is set; otherwise they are listed on out and an error
+// is returned.
+func checkProjectEgress(ctx context.Context, client api.Services, proj *config.Project, apply bool, out io.Writer) error {
+	if len(proj.Egress) == 0 {
+		return nil
+	}
+
+	rules, err := api.Collect(client.AllEgressRules(ctx), 0)
+	if err != nil {
+		return fmt.Errorf("listing egress rules: %w", err)
+	}
+	existing := make(map[string]bool, len(rules))
+	for _, r := range rules {
+		existing[r.Host+"|"+r.CIDR] = true
+	}
+
+	var missing []config.EgressRule
+	for _, r := range proj.Egress {
+		if !existing[r.Host+"|"+r.CIDR] {
+			missing = append(missing, r)
+			existing[r.Host+"|"+r.CIDR] = true
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	if !apply {
+		fmt.Fprintf(out, "%s lists egress rules the account does not have:\n", proj.Path)
+		for _, r := range missing {
+			fmt.Fprintf(out, "  + %s\n", r.Host+r.CIDR)
+		}
+		fmt.Fprintln(out, "They would allow egress from every VM on the account. Add them with --apply-project-egress, 'irons apply' or 'irons egress add'.")
+		return fmt.Errorf("%d egress rule(s) from %s are missing from the account", len(missing), config.ProjectFile)
+	}
+
+	for _, r := range missing {
+		rule, err := client.EgressCreateRuleContext(ctx, api.EgressRuleRequest{
+			Name:    r.Name,
+			Host:    r.Host,
+			CIDR:    r.CIDR,
+			Comment: r.Comment,
+		})
+		if err != nil {
+			return fmt.Errorf("adding egress rule from %s: %w", proj.Path, err)
+		}
+		target := rule.Host
+		if target == "" {
+			target = rule.CIDR
+		}
+		fmt.Fprintf(out, "✓ Added egress rule %s for %s (from %s)\n", rule.ID, target, config.ProjectFile)
+	}
+	return nil
 }
 
 // fileExists returns true if the file at path exists and is accessible.
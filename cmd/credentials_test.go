@@ -0,0 +1,161 @@
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ironsh/irons/api"
+	"github.com/ironsh/irons/apitest"
+	"github.com/stretchr/testify/require"
+)
+
+// credentialHelper is a credential process that logs each action to $LOG.
+// get prints $STORE if it exists and $CREDENTIAL otherwise; store saves
+// stdin to $STORE and erase removes it.
+const credentialHelper = `#!/bin/sh
+echo "$1" >> "$LOG"
+case "$1" in
+get)
+	if [ -n "$FAIL" ]; then echo "$FAIL" >&2; exit 1; fi
+	if [ -f "$STORE" ]; then cat "$STORE"; else printf '%s' "$CREDENTIAL"; fi ;;
+store)
+	cat > "$STORE" ;;
+erase)
+	rm -f "$STORE" ;;
+esac
+`
+
+// helperSetup is a home directory whose default profile uses the credential
+// helper.
+type helperSetup struct {
+	env   []string // environment to run the CLI with
+	home  string
+	log   string
+	store string
+}
+
+// newHelperSetup writes the credential helper into the config of a fresh
+// home, with get printing credential until a key has been stored.
+func newHelperSetup(t *testing.T, srv *apitest.Server, credential string) helperSetup {
+	t.Helper()
+	dir := t.TempDir()
+	script := filepath.Join(dir, "helper.sh")
+	require.NoError(t, os.WriteFile(script, []byte(credentialHelper), 0o700))
+
+	h := helperSetup{
+		home:  writeConfig(t, "profiles:\n  default:\n    credential_process: "+script+"\n"),
+		log:   filepath.Join(dir, "log"),
+		store: filepath.Join(dir, "store.json"),
+	}
+	h.env = []string{
+		"HOME=" + h.home,
+		"IRONS_API_URL=" + srv.URL,
+		"LOG=" + h.log,
+		"STORE=" + h.store,
+		"CREDENTIAL=" + credential,
+	}
+	return h
+}
+
+// actions returns the actions the credential helper was run with.
+func (h helperSetup) actions(t *testing.T) []string {
+	t.Helper()
+	data, err := os.ReadFile(h.log)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	require.NoError(t, err)
+	return strings.Fields(string(data))
+}
+
+func TestCredentialProcess_SuppliesAPIKey(t *testing.T) {
+	srv := apitest.NewServer(t, apitest.Options{APIKeys: []string{"vault-key"}})
+	srv.AddVM(api.VM{ID: "vm_abc123", Name: "agent-1"})
+	h := newHelperSetup(t, srv, `{"api_key": "vault-key"}`)
+
+	res := runCLIWithEnv(t, h.env, nil, "status", "agent-1")
+	require.Equal(t, 0, res.ExitCode, res.Stderr)
+	require.Greater(t, len(srv.Requests()), 1)
+	require.Equal(t, []string{"get"}, h.actions(t), "the key is cached for the process")
+}
+
+func TestCredentialProcess_APIKeyTakesPrecedence(t *testing.T) {
+	srv := apitest.NewServer(t, apitest.Options{})
+	h := newHelperSetup(t, srv, `{"api_key": "vault-key"}`)
+
+	res := runCLIWithEnv(t, append(h.env, "IRONS_API_KEY=test-key"), nil, "list")
+	require.Equal(t, 0, res.ExitCode, res.Stderr)
+	require.Empty(t, h.actions(t))
+}
+
+func TestCredentialProcess_ShownInProfiles(t *testing.T) {
+	srv := apitest.NewServer(t, apitest.Options{})
+	h := newHelperSetup(t, srv, `{"api_key": "vault-key"}`)
+
+	res := runCLIWithEnv(t, h.env, nil, "config", "profiles")
+	require.Equal(t, 0, res.ExitCode, res.Stderr)
+	require.Regexp(t, `default\s+│.*│\s+helper\s`, res.Stdout)
+	require.Empty(t, h.actions(t), "listing profiles does not run the helper")
+}
+
+func TestCredentialProcess_Failure(t *testing.T) {
+	srv := apitest.NewServer(t, apitest.Options{})
+	h := newHelperSetup(t, srv, "")
+
+	res := runCLIWithEnv(t, append(h.env, "FAIL=vault is sealed"), nil, "list")
+	require.Equal(t, 1, res.ExitCode)
+	require.Contains(t, res.Stderr, "vault is sealed")
+	require.Equal(t, []string{"get"}, h.actions(t), "helper failures are not retried")
+	require.Empty(t, srv.Requests())
+}
+
+func TestCredentialProcess_ExpiredKey(t *testing.T) {
+	srv := apitest.NewServer(t, apitest.Options{})
+	expired := time.Now().Add(-time.Minute).UTC().Format(time.RFC3339)
+	h := newHelperSetup(t, srv, `{"api_key": "test-key", "expires_at": "`+expired+`"}`)
+
+	res := runCLIWithEnv(t, h.env, nil, "list")
+	require.Equal(t, 1, res.ExitCode)
+	require.Contains(t, res.Stderr, "expired")
+}
+
+func TestLogin_StoresTokenWithCredentialProcess(t *testing.T) {
+	srv := apitest.NewServer(t, apitest.Options{})
+	h := newHelperSetup(t, srv, "")
+
+	done := make(chan cliResult)
+	go func() { done <- runCLIWithEnv(t, h.env, nil, "login") }()
+
+	var token string
+	require.Eventually(t, func() bool {
+		codes := srv.DeviceCodes()
+		if len(codes) == 0 {
+			return false
+		}
+		var err error
+		token, err = srv.AuthorizeDevice(codes[0])
+		return err == nil
+	}, 10*time.Second, 10*time.Millisecond)
+
+	res := <-done
+	require.Equal(t, 0, res.ExitCode, res.Stderr)
+	require.Contains(t, res.Stdout, "credential helper")
+	require.Equal(t, []string{"store"}, h.actions(t), "login must not ask the helper for a key")
+
+	var stored map[string]string
+	data, err := os.ReadFile(h.store)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(data, &stored))
+	require.Equal(t, token, stored["api_key"])
+	require.Equal(t, srv.URL, stored["api_url"])
+
+	require.NotContains(t, readConfig(t, h.home), token, "the token must not be written to disk")
+
+	res = runCLIWithEnv(t, h.env, nil, "list")
+	require.Equal(t, 0, res.ExitCode, res.Stderr)
+	require.True(t, hitServer(srv, token))
+}
@@ -1,15 +1,19 @@
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"strings"
 
+	"github.com/ironsh/irons/api"
 	"github.com/spf13/cobra"
 )
 
 // destroyCmd represents the destroy command
 var destroyCmd = &cobra.Command{
-	Use:   "destroy ID",
+	Use:   "destroy [ID...]",
 	Short: "Destroy a VM",
 	Long: `Destroy a VM and clean up associated components.
 
@@ -19,62 +23,125 @@ and remove it from the system with proper cleanup.
 Use --force to automatically stop the VM first if it is
 currently running.
 
+VMs must be given by their full name or ID; unlike other commands,
+destroy does not accept a prefix.
+
+Several VMs can be given at once. --all acts on every VM that has not
+been destroyed, and -l/--selector on those whose labels match. VMs are
+acted on concurrently, --parallel at a time, and a table of results is
+shown; the command fails if any VM does.
+
+VMs picked by --all or --selector are listed, and destroy asks before
+going ahead. -y/--yes skips the question, and is
+required when stdin is not a terminal.
+
 Examples:
   irons destroy vm_abc123
-  irons destroy --force vm_abc123`,
-	Args: cobra.ExactArgs(1),
+  irons destroy --force vm_abc123
+  irons destroy --force -l ticket=IR-42
+  irons destroy --force --all --yes`,
+	Args: cobra.ArbitraryArgs,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		idOrName := args[0]
 		force, _ := cmd.Flags().GetBool("force")
+		parallel, _ := cmd.Flags().GetInt("parallel")
+		yes, _ := cmd.Flags().GetBool("yes")
+
+		wait, err := waitFlags(cmd)
+		if err != nil {
+			return err
+		}
+		output, err := newPrinter()
+		if err != nil {
+			return err
+		}
+		msgs := output.messages()
 
 		// Create API client
 		client := newClient()
+		ctx := cmd.Context()
 
-		id, err := resolveVM(client, idOrName)
+		targets, bulk, err := targetVMs(cmd, client, args, func(*api.VM) bool { return true }, true, msgs)
 		if err != nil {
 			return err
 		}
-
-		if force {
-			// Check current status before deciding whether to stop first.
-			vm, err := client.GetVM(id)
-			if err != nil {
-				return fmt.Errorf("getting VM status: %w", err)
+		if !bulk {
+			if err := destroyVM(ctx, client, targets[0].ID, force, msgs, wait); err != nil {
+				return err
+			}
+			return printTargets(ctx, client, output, targets, false)
+		}
+		// VMs picked by --all or --selector were not named by the user, so
+		// they are shown before anything is destroyed.
+		if len(args) == 0 && len(targets) > 0 && !yes {
+			what := fmt.Sprintf("these %d VMs", len(targets))
+			if len(targets) == 1 {
+				what = "this VM"
 			}
+			fmt.Fprintf(msgs, "%s will be destroyed:\n", strings.ToUpper(what[:1])+what[1:])
+			for _, t := range targets {
+				fmt.Fprintf(msgs, "  %s (%s)\n", t.Name, t.ID)
+			}
+			if err := confirm("destroy "+what, "--yes"); err != nil {
+				return err
+			}
+		}
+		// The results table reports on each VM instead.
+		wait.Progress = nil
+		err = runBulk(ctx, targets, parallel, "destroy", "destroyed", msgs, func(ctx context.Context, id string, out io.Writer) error {
+			return destroyVM(ctx, client, id, force, out, wait)
+		})
+		if perr := printTargets(ctx, client, output, targets, true); perr != nil {
+			return errors.Join(err, perr)
+		}
+		return err
+	},
+}
 
-			if vm.Status == "running" {
-				fmt.Printf("Stopping VM '%s' before destroying...\n", id)
+// destroyVM destroys a VM, first stopping it if force is set and it is
+// running.
+func destroyVM(ctx context.Context, client api.VMService, id string, force bool, out io.Writer, wait waitOptions) error {
+	if force {
+		// Check current status before deciding whether to stop first.
+		vm, err := client.GetVMContext(ctx, id)
+		if err != nil {
+			return fmt.Errorf("getting VM status: %w", err)
+		}
 
-				if _, err := client.Stop(id); err != nil {
-					return fmt.Errorf("stopping VM: %w", err)
-				}
+		if vm.Status == "running" {
+			fmt.Fprintf(out, "Stopping VM '%s' before destroying...\n", id)
 
-				if err := waitForVMCond(cmd.Context(), client, id, statusAndDetailEq("stopped", "stopped")); err != nil {
-					return err
-				}
+			if _, err := client.StopContext(ctx, id); err != nil {
+				return fmt.Errorf("stopping VM: %w", err)
+			}
 
-				fmt.Printf("✓ VM '%s' stopped.\n", id)
+			if err := waitForVM(ctx, client, id, statusAndDetailEq("stopped", "stopped"), wait); err != nil {
+				return err
 			}
+
+			fmt.Fprintf(out, "✓ VM '%s' stopped.\n", id)
 		}
+	}
 
-		// Show what we're destroying
-		fmt.Printf("Destroying VM '%s'...\n", id)
+	// Show what we're destroying
+	fmt.Fprintf(out, "Destroying VM '%s'...\n", id)
 
-		// Make API call
-		if err := client.Destroy(id); err != nil {
-			if strings.Contains(err.Error(), "409") || strings.Contains(err.Error(), "not stopped") {
-				return fmt.Errorf("VM must be stopped before destroying. Use --force to stop it first")
-			}
-			return fmt.Errorf("destroying VM: %w", err)
+	// Make API call
+	if err := client.DestroyContext(ctx, id); err != nil {
+		if api.IsConflict(err) {
+			return fmt.Errorf("VM must be stopped before destroying. Use --force to stop it first")
 		}
+		return fmt.Errorf("destroying VM: %w", err)
+	}
 
-		// Show success
-		fmt.Printf("✓ VM destroyed successfully!\n")
-		return nil
-	},
+	// Show success
+	fmt.Fprintf(out, "✓ VM destroyed successfully!\n")
+	return nil
 }
 
 func init() {
 	rootCmd.AddCommand(destroyCmd)
+	addTargetFlags(destroyCmd)
 	destroyCmd.Flags().Bool("force", false, "Stop the VM first if it is currently running")
+	destroyCmd.Flags().BoolP("yes", "y", false, "Destroy the VMs picked by --all or --selector without asking")
+	addWaitFlags(destroyCmd, "a running VM to stop with --force")
 }
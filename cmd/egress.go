@@ -38,6 +38,11 @@ Examples:
 		name, _ := cmd.Flags().GetString("name")
 		comment, _ := cmd.Flags().GetString("comment")
 
+		output, err := newPrinter()
+		if err != nil {
+			return err
+		}
+
 		if host == "" && cidr == "" {
 			return fmt.Errorf("either --host or --cidr is required")
 		}
@@ -46,6 +51,7 @@ Examples:
 		}
 
 		client := newClient()
+		ctx := cmd.Context()
 
 		req := api.EgressRuleRequest{
 			Name:    name,
@@ -54,13 +60,12 @@ Examples:
 			Comment: comment,
 		}
 
-		rule, err := client.EgressCreateRule(req)
+		rule, err := client.EgressCreateRuleContext(ctx, req)
 		if err != nil {
 			return fmt.Errorf("creating egress rule: %w", err)
 		}
 
-		fmt.Printf("✓ Egress rule created (ID: %s)\n", rule.ID)
-		return nil
+		return output.print(rule, func() { fmt.Printf("✓ Egress rule created (ID: %s)\n", rule.ID) })
 	},
 }
 
@@ -76,13 +81,19 @@ Examples:
 	RunE: func(cmd *cobra.Command, args []string) error {
 		ruleID := args[0]
 
+		output, err := newPrinter()
+		if err != nil {
+			return err
+		}
+
 		client := newClient()
+		ctx := cmd.Context()
 
-		if err := client.EgressDeleteRule(ruleID); err != nil {
+		if err := client.EgressDeleteRuleContext(ctx, ruleID); err != nil {
 			return fmt.Errorf("removing egress rule: %w", err)
 		}
 
-		fmt.Printf("✓ Egress rule '%s' removed.\n", ruleID)
+		fmt.Fprintf(output.messages(), "✓ Egress rule '%s' removed.\n", ruleID)
 		return nil
 	},
 }
@@ -91,38 +102,60 @@ Examples:
 var egressListCmd = &cobra.Command{
 	Use:   "list",
 	Short: "List egress rules for the account",
-	Long: `List all current egress rules for the account.
+	Long: `List all current egress rules for the account. All pages are fetched
+unless --limit is given.
 
 Examples:
-  irons egress list`,
+  irons egress list
+  irons egress list --limit 50`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		client := newClient()
+		limit, _ := cmd.Flags().GetInt("limit")
 
-		resp, err := client.EgressListRules()
+		output, err := newPrinter()
 		if err != nil {
-			return fmt.Errorf("listing egress rules: %w", err)
+			return err
 		}
 
-		if len(resp.Data) == 0 {
-			fmt.Println("No egress rules found.")
-			return nil
-		}
+		client := newClient()
+		ctx := cmd.Context()
 
-		table := tablewriter.NewTable(os.Stdout)
-		table.Header([]string{"ID", "Name", "Host/CIDR", "Comment"})
-		for _, r := range resp.Data {
-			target := r.Host
-			if target == "" {
-				target = r.CIDR
-			}
-			table.Append([]string{r.ID, r.Name, target, r.Comment})
+		rules, err := api.Collect(client.AllEgressRules(ctx), limit)
+		if err != nil {
+			return fmt.Errorf("listing egress rules: %w", err)
 		}
-		table.Render()
 
-		return nil
+		return output.print(rules, func() { printEgressRuleTable(rules, output.wide()) })
 	},
 }
 
+// printEgressRuleTable prints rules as a table. wide adds when each rule
+// was created.
+func printEgressRuleTable(rules []api.EgressRule, wide bool) {
+	if len(rules) == 0 {
+		fmt.Println("No egress rules found.")
+		return
+	}
+
+	header := []string{"ID", "Name", "Host/CIDR", "Comment"}
+	if wide {
+		header = append(header, "Created")
+	}
+	table := tablewriter.NewTable(os.Stdout)
+	table.Header(header)
+	for _, r := range rules {
+		target := r.Host
+		if target == "" {
+			target = r.CIDR
+		}
+		row := []string{r.ID, r.Name, target, r.Comment}
+		if wide {
+			row = append(row, r.CreatedAt)
+		}
+		table.Append(row)
+	}
+	table.Render()
+}
+
 // egressModeCmd represents the egress mode command
 var egressModeCmd = &cobra.Command{
 	Use:   "mode",
@@ -134,15 +167,20 @@ Examples:
   irons egress mode enforce
   irons egress mode warn`,
 	RunE: func(cmd *cobra.Command, args []string) error {
+		output, err := newPrinter()
+		if err != nil {
+			return err
+		}
+
 		client := newClient()
+		ctx := cmd.Context()
 
-		resp, err := client.EgressGetPolicy()
+		resp, err := client.EgressGetPolicyContext(ctx)
 		if err != nil {
 			return fmt.Errorf("getting egress mode: %w", err)
 		}
 
-		fmt.Printf("Egress mode: %s\n", resp.Mode)
-		return nil
+		return output.print(resp, func() { fmt.Printf("Egress mode: %s\n", resp.Mode) })
 	},
 }
 
@@ -152,13 +190,19 @@ var egressModeEnforceCmd = &cobra.Command{
 	Short: "Set egress mode to enforce",
 	Long:  `Set the egress mode to enforce. Egress traffic not matching allow rules will be blocked.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
+		output, err := newPrinter()
+		if err != nil {
+			return err
+		}
+
 		client := newClient()
+		ctx := cmd.Context()
 
-		if err := client.EgressSetPolicy("enforce"); err != nil {
+		if err := client.EgressSetPolicyContext(ctx, "enforce"); err != nil {
 			return fmt.Errorf("setting egress mode: %w", err)
 		}
 
-		fmt.Printf("✓ Egress mode set to enforce\n")
+		fmt.Fprintf(output.messages(), "✓ Egress mode set to enforce\n")
 		return nil
 	},
 }
@@ -169,13 +213,19 @@ var egressModeWarnCmd = &cobra.Command{
 	Short: "Set egress mode to warn",
 	Long:  `Set the egress mode to warn. Egress traffic not matching allow rules will be logged but not blocked.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
+		output, err := newPrinter()
+		if err != nil {
+			return err
+		}
+
 		client := newClient()
+		ctx := cmd.Context()
 
-		if err := client.EgressSetPolicy("warn"); err != nil {
+		if err := client.EgressSetPolicyContext(ctx, "warn"); err != nil {
 			return fmt.Errorf("setting egress mode: %w", err)
 		}
 
-		fmt.Printf("✓ Egress mode set to warn\n")
+		fmt.Fprintf(output.messages(), "✓ Egress mode set to warn\n")
 		return nil
 	},
 }
@@ -196,4 +246,7 @@ func init() {
 	egressAddCmd.Flags().String("cidr", "", "CIDR range to allow egress to")
 	egressAddCmd.Flags().String("name", "", "Optional name for the rule")
 	egressAddCmd.Flags().String("comment", "", "Optional comment for the rule")
+
+	// Flags for list command
+	egressListCmd.Flags().Int("limit", 0, "Maximum number of rules to show (0 for all)")
 }
@@ -37,15 +37,16 @@ Example:
 		}
 
 		client := newClient()
+		ctx := cmd.Context()
 
-		id, err := resolveVM(client, idOrName)
+		id, err := resolveVM(ctx, client, idOrName)
 		if err != nil {
 			return err
 		}
 
 		fmt.Printf("Getting SSH connection info for VM '%s'...\n", id)
 
-		resp, err := client.SSH(id)
+		resp, err := client.SSHContext(ctx, id)
 		if err != nil {
 			return fmt.Errorf("getting SSH info: %w", err)
 		}
@@ -16,6 +16,9 @@ import (
 // binaryPath is the path to the compiled irons binary, built once in TestMain.
 var binaryPath string
 
+// testVersion is the version stamped into the test binary.
+const testVersion = "1.2.0"
+
 func TestMain(m *testing.M) {
 	// Build the binary once for all tests.
 	tmp, err := os.MkdirTemp("", "irons-test-*")
@@ -25,7 +28,7 @@ func TestMain(m *testing.M) {
 	defer os.RemoveAll(tmp)
 
 	binaryPath = filepath.Join(tmp, "irons")
-	build := exec.Command("go", "build", "-o", binaryPath, "..")
+	build := exec.Command("go", "build", "-ldflags", "-X main.version="+testVersion, "-o", binaryPath, "..")
 	build.Stderr = os.Stderr
 	if err := build.Run(); err != nil {
 		panic("failed to build binary: " + err.Error())
@@ -137,45 +140,43 @@ type cliResult struct {
 // entirely from environment variables, avoiding any cobra/viper state issues.
 func runCLI(t *testing.T, server *mockServer, args ...string) cliResult {
 	t.Helper()
-	cmd := exec.Command(binaryPath, args...)
-	cmd.Env = append(os.Environ(),
-		"IRONS_API_URL="+server.Server.URL,
-		"IRONS_API_KEY=test-key",
-		"HOME="+t.TempDir(), // avoid reading real config
-	)
-
-	var stdout, stderr strings.Builder
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
+	return runCLIAt(t, server.Server.URL, nil, args...)
+}
 
-	err := cmd.Run()
+// runCLIWithStdin is like runCLI but pipes the given string to stdin.
+func runCLIWithStdin(t *testing.T, server *mockServer, stdin string, args ...string) cliResult {
+	t.Helper()
+	return runCLIAt(t, server.Server.URL, strings.NewReader(stdin), args...)
+}
 
-	exitCode := 0
-	if err != nil {
-		if exitErr, ok := err.(*exec.ExitError); ok {
-			exitCode = exitErr.ExitCode()
-		} else {
-			t.Fatalf("failed to run binary: %v", err)
-		}
-	}
+// runCLIAt executes the irons binary against the API at url, e.g. an
+// apitest.Server, with optional stdin.
+func runCLIAt(t *testing.T, url string, stdin io.Reader, args ...string) cliResult {
+	t.Helper()
+	return runCLIWithEnv(t, []string{
+		"IRONS_API_URL=" + url,
+		"IRONS_API_KEY=test-key",
+		"HOME=" + t.TempDir(), // avoid reading real config
+	}, stdin, args...)
+}
 
-	return cliResult{
-		Stdout:   stdout.String(),
-		Stderr:   stderr.String(),
-		ExitCode: exitCode,
-	}
+// runCLIWithEnv executes the irons binary with env added to the test's
+// environment, e.g. to point HOME at a prepared config file.
+func runCLIWithEnv(t *testing.T, env []string, stdin io.Reader, args ...string) cliResult {
+	t.Helper()
+	return runCLIIn(t, "", env, stdin, args...)
 }
 
-// runCLIWithStdin is like runCLI but pipes the given string to stdin.
-func runCLIWithStdin(t *testing.T, server *mockServer, stdin string, args ...string) cliResult {
+// runCLIIn is like runCLIWithEnv but runs the binary in dir, e.g. a project
+// with a .irons.yml. An empty dir means the test's working directory.
+func runCLIIn(t *testing.T, dir string, env []string, stdin io.Reader, args ...string) cliResult {
 	t.Helper()
 	cmd := exec.Command(binaryPath, args...)
-	cmd.Env = append(os.Environ(),
-		"IRONS_API_URL="+server.Server.URL,
-		"IRONS_API_KEY=test-key",
-		"HOME="+t.TempDir(),
-	)
-	cmd.Stdin = strings.NewReader(stdin)
+	cmd.Dir = dir
+	// BROWSER=true keeps irons login from opening a real browser.
+	cmd.Env = append(os.Environ(), "XDG_CONFIG_HOME=", "BROWSER=true")
+	cmd.Env = append(cmd.Env, env...)
+	cmd.Stdin = stdin
 
 	var stdout, stderr strings.Builder
 	cmd.Stdout = &stdout
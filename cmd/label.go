@@ -0,0 +1,115 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/ironsh/irons/api"
+	"github.com/ironsh/irons/labels"
+	"github.com/spf13/cobra"
+)
+
+// labelCmd represents the label command
+var labelCmd = &cobra.Command{
+	Use:   "label ID [key=value...] [key-...]",
+	Short: "Show or change the labels of a VM",
+	Long: `Show or change the labels of a VM.
+
+Labels are key=value pairs used to organise VMs, e.g. by agent, ticket or
+user, and to select them with -l/--selector on list, start, stop and
+destroy. key=value adds a label or changes its value, and key- removes it.
+With no changes, the VM's labels are shown.
+
+Keys are names of up to 63 alphanumerics, '-', '_' or '.', optionally
+prefixed with a DNS subdomain and a slash, e.g. iron.sh/agent. Values
+follow the same rules as names and may be empty.
+
+Examples:
+  irons label my-vm
+  irons label my-vm ticket=IR-42 agent=claude
+  irons label my-vm ticket-`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		idOrName := args[0]
+
+		changes := map[string]*string{}
+		for _, arg := range args[1:] {
+			if key, ok := strings.CutSuffix(arg, "-"); ok && !strings.Contains(arg, "=") {
+				if err := labels.ValidateKey(key); err != nil {
+					return err
+				}
+				changes[key] = nil
+				continue
+			}
+			key, value, err := labels.ParsePair(arg)
+			if err != nil {
+				return err
+			}
+			changes[key] = &value
+		}
+
+		output, err := newPrinter()
+		if err != nil {
+			return err
+		}
+
+		client := newClient()
+		ctx := cmd.Context()
+
+		id, err := resolveVM(ctx, client, idOrName)
+		if err != nil {
+			return err
+		}
+
+		var vm *api.VM
+		if len(changes) == 0 {
+			if vm, err = client.GetVMContext(ctx, id); err != nil {
+				return fmt.Errorf("getting VM: %w", err)
+			}
+		} else {
+			if vm, err = client.UpdateVMContext(ctx, id, api.UpdateVMRequest{Labels: changes}); err != nil {
+				return fmt.Errorf("updating labels: %w", err)
+			}
+			fmt.Fprintf(output.messages(), "✓ Labels of VM '%s' updated\n", vm.Name)
+		}
+
+		return output.print(vm, func() {
+			if len(vm.Labels) == 0 {
+				fmt.Printf("VM '%s' has no labels.\n", vm.Name)
+				return
+			}
+			for _, pair := range strings.Split(labels.Format(vm.Labels), ",") {
+				fmt.Printf("  %s\n", pair)
+			}
+		})
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(labelCmd)
+}
+
+// addSelectorFlag adds the -l/--selector flag to cmd.
+func addSelectorFlag(cmd *cobra.Command) {
+	cmd.Flags().StringP("selector", "l", "", "Select VMs by label, e.g. env=prod,ticket or 'agent in (a,b)'")
+}
+
+// selectVMs returns the VMs that have not been destroyed and whose labels
+// match selector.
+func selectVMs(ctx context.Context, client api.VMService, selector string) ([]api.VM, error) {
+	sel, err := labels.Parse(selector)
+	if err != nil {
+		return nil, err
+	}
+	var vms []api.VM
+	for vm, err := range client.AllVMs(ctx) {
+		if err != nil {
+			return nil, fmt.Errorf("listing VMs: %w", err)
+		}
+		if vm.Status != "destroyed" && sel.Matches(vm.Labels) {
+			vms = append(vms, vm)
+		}
+	}
+	return vms, nil
+}
@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/ironsh/irons/api"
+	"github.com/ironsh/irons/apitest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLabel_CreateAndEdit(t *testing.T) {
+	srv := apitest.NewServer(t, apitest.Options{})
+
+	res := runCLIAt(t, srv.URL, nil, "create", "--async", "--key", writeTestKey(t),
+		"--label", "ticket=IR-42", "--label", "agent=claude", "agent-1")
+	require.Equal(t, 0, res.ExitCode, res.Stderr)
+	require.Contains(t, res.Stdout, "Labels: agent=claude,ticket=IR-42")
+
+	res = runCLIAt(t, srv.URL, nil, "label", "agent-1", "ticket-", "env=ci")
+	require.Equal(t, 0, res.ExitCode, res.Stderr)
+	require.Contains(t, res.Stdout, "  agent=claude\n  env=ci\n")
+
+	vm := onlyVM(t, srv)
+	require.Equal(t, map[string]string{"agent": "claude", "env": "ci"}, vm.Labels)
+
+	res = runCLIAt(t, srv.URL, nil, "label", "agent-1", "bad key=x")
+	require.Equal(t, 1, res.ExitCode)
+	require.Contains(t, res.Stderr, "invalid label key")
+}
+
+func TestLabel_SelectorFilters(t *testing.T) {
+	srv := apitest.NewServer(t, apitest.Options{})
+	srv.AddVM(api.VM{Name: "a", Labels: map[string]string{"env": "ci", "ticket": "IR-1"}})
+	srv.AddVM(api.VM{Name: "b", Labels: map[string]string{"env": "ci"}})
+	srv.AddVM(api.VM{Name: "c", Labels: map[string]string{"env": "prod"}})
+	srv.AddVM(api.VM{Name: "d"})
+
+	for selector, want := range map[string][]string{
+		"env=ci":                   {"a", "b"},
+		"env in (ci,prod),!ticket": {"b", "c"},
+		"env!=ci":                  {"c", "d"},
+	} {
+		res := runCLIAt(t, srv.URL, nil, "list", "-l", selector)
+		require.Equal(t, 0, res.ExitCode, res.Stderr)
+		for _, name := range []string{"a", "b", "c", "d"} {
+			vm := "│ " + name + " "
+			if slices.Contains(want, name) {
+				require.Contains(t, res.Stdout, vm, selector)
+			} else {
+				require.NotContains(t, res.Stdout, vm, selector)
+			}
+		}
+	}
+
+	res := runCLIAt(t, srv.URL, nil, "list", "-l", "env in (ci")
+	require.Equal(t, 1, res.ExitCode)
+	require.Contains(t, res.Stderr, "invalid selector")
+}
+
+func TestLabel_StopBySelector(t *testing.T) {
+	srv := apitest.NewServer(t, apitest.Options{})
+	a := srv.AddVM(api.VM{Name: "a", Labels: map[string]string{"env": "ci"}})
+	b := srv.AddVM(api.VM{Name: "b", Labels: map[string]string{"env": "prod"}})
+
+	res := runCLIAt(t, srv.URL, nil, "stop", "-l", "env=ci")
+	require.Equal(t, 0, res.ExitCode, res.Stderr)
+	vm, _ := srv.VM(a.ID)
+	require.Equal(t, "stopped", vm.Status)
+	vm, _ = srv.VM(b.ID)
+	require.Equal(t, "running", vm.Status)
+
+	res = runCLIAt(t, srv.URL, nil, "stop", "-l", "env=none")
+	require.Equal(t, 0, res.ExitCode, res.Stderr)
+	require.Contains(t, res.Stdout, `No VMs match selector "env=none"`)
+
+	res = runCLIAt(t, srv.URL, nil, "stop", "b", "-l", "env=prod")
+	require.Equal(t, 1, res.ExitCode)
+	require.Contains(t, res.Stderr, "cannot be combined")
+
+	res = runCLIAt(t, srv.URL, nil, "destroy")
+	require.Equal(t, 1, res.ExitCode)
+	require.Contains(t, res.Stderr, "a VM, --all or --selector is required")
+}
@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ironsh/irons/apitest"
+	"github.com/stretchr/testify/require"
+)
+
+// writeTestKey writes a dummy SSH public key and returns its path.
+func writeTestKey(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "id_ed25519.pub")
+	require.NoError(t, os.WriteFile(path, []byte("ssh-ed25519 AAAA test\n"), 0o600))
+	return path
+}
+
+func TestLifecycle_CreateStopDestroy(t *testing.T) {
+	srv := apitest.NewServer(t, apitest.Options{})
+	key := writeTestKey(t)
+
+	res := runCLIAt(t, srv.URL, nil, "create", "--key", key, "agent-1")
+	require.Equal(t, 0, res.ExitCode, res.Stderr)
+	require.Contains(t, res.Stdout, "is ready")
+
+	res = runCLIAt(t, srv.URL, nil, "destroy", "agent-1")
+	require.NotEqual(t, 0, res.ExitCode)
+	require.Contains(t, res.Stderr, "Use --force")
+
+	res = runCLIAt(t, srv.URL, nil, "destroy", "--force", "agent-1")
+	require.Equal(t, 0, res.ExitCode, res.Stderr)
+	require.Contains(t, res.Stdout, "destroyed successfully")
+
+	res = runCLIAt(t, srv.URL, nil, "status", "agent-1")
+	require.NotEqual(t, 0, res.ExitCode)
+	require.Contains(t, res.Stderr, "no active VM found")
+}
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/ironsh/irons/api"
+	"github.com/ironsh/irons/labels"
 	"github.com/olekukonko/tablewriter"
 	"github.com/spf13/cobra"
 )
@@ -15,51 +17,98 @@ var listCmd = &cobra.Command{
 	Long: `List all VMs associated with your account.
 
 This command displays a summary of every VM, including its name,
-ID, current status, and creation date.
+ID, current status, and creation date. All pages are fetched
+unless --limit is given.
+
+With -l/--selector, only VMs that have not been destroyed and whose
+labels match the selector are shown.
 
 Examples:
-  irons list`,
+  irons list
+  irons list --limit 20
+  irons list -l ticket=IR-42
+  irons list -l 'env in (ci,staging),!keep'`,
 	Args: cobra.NoArgs,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		client := newClient()
+		limit, _ := cmd.Flags().GetInt("limit")
+		selector, _ := cmd.Flags().GetString("selector")
 
-		// Make API call
-		resp, err := client.ListVMs()
+		output, err := newPrinter()
 		if err != nil {
-			return fmt.Errorf("listing VMs: %w", err)
+			return err
 		}
 
-		if len(resp.Data) == 0 {
-			fmt.Println("No VMs found.")
-			return nil
-		}
+		client := newClient()
+		ctx := cmd.Context()
 
-		hasDetail := false
-		for _, vm := range resp.Data {
-			if vm.StatusDetail != "" {
-				hasDetail = true
-				break
+		var vms []api.VM
+		if selector != "" {
+			// Labels are matched locally, so every page is read before
+			// applying --limit.
+			if vms, err = selectVMs(ctx, client, selector); err != nil {
+				return err
 			}
-		}
-
-		table := tablewriter.NewTable(os.Stdout)
-		if hasDetail {
-			table.Header([]string{"Name", "ID", "Status", "Status Detail", "Created At"})
-			for _, vm := range resp.Data {
-				table.Append([]string{vm.Name, vm.ID, vm.Status, vm.StatusDetail, vm.CreatedAt})
+			if limit > 0 && len(vms) > limit {
+				vms = vms[:limit]
 			}
 		} else {
-			table.Header([]string{"Name", "ID", "Status", "Created At"})
-			for _, vm := range resp.Data {
-				table.Append([]string{vm.Name, vm.ID, vm.Status, vm.CreatedAt})
+			// Walk every page, stopping early once --limit VMs have been read.
+			if vms, err = api.Collect(client.AllVMs(ctx), limit); err != nil {
+				return fmt.Errorf("listing VMs: %w", err)
 			}
 		}
-		table.Render()
 
-		return nil
+		return output.print(vms, func() { printVMTable(vms, output.wide()) })
 	},
 }
 
+// printVMTable prints vms as a table. Status details and labels are only
+// shown if some VM has them, unless wide is set, which also shows when each
+// VM was last updated.
+func printVMTable(vms []api.VM, wide bool) {
+	if len(vms) == 0 {
+		fmt.Println("No VMs found.")
+		return
+	}
+
+	hasDetail, hasLabels := wide, wide
+	for _, vm := range vms {
+		hasDetail = hasDetail || vm.StatusDetail != ""
+		hasLabels = hasLabels || len(vm.Labels) > 0
+	}
+
+	header := []string{"Name", "ID", "Status"}
+	if hasDetail {
+		header = append(header, "Status Detail")
+	}
+	if hasLabels {
+		header = append(header, "Labels")
+	}
+	header = append(header, "Created At")
+	if wide {
+		header = append(header, "Updated At")
+	}
+	table := tablewriter.NewTable(os.Stdout)
+	table.Header(header)
+	for _, vm := range vms {
+		row := []string{vm.Name, vm.ID, vm.Status}
+		if hasDetail {
+			row = append(row, vm.StatusDetail)
+		}
+		if hasLabels {
+			row = append(row, labels.Format(vm.Labels))
+		}
+		row = append(row, vm.CreatedAt)
+		if wide {
+			row = append(row, vm.UpdatedAt)
+		}
+		table.Append(row)
+	}
+	table.Render()
+}
+
 func init() {
 	rootCmd.AddCommand(listCmd)
+	addSelectorFlag(listCmd)
+	listCmd.Flags().Int("limit", 0, "Maximum number of VMs to show (0 for all)")
 }
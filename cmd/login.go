@@ -1,12 +1,21 @@
 package cmd
 
 import (
+	"bufio"
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"strings"
 	"time"
 
+	"github.com/ironsh/irons/api"
 	"github.com/ironsh/irons/config"
+	"github.com/ironsh/irons/qr"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"golang.org/x/term"
 )
 
 var loginCmd = &cobra.Command{
@@ -14,72 +23,324 @@ var loginCmd = &cobra.Command{
 	Short: "Authenticate with IronCD",
 	Long: `Authenticate with IronCD using device code authorization.
 
-This command initiates a browser-based login flow. You will be given a URL
-to visit where you can authorize this device. Once authorized, your API token
-will be saved to ~/.config/irons/config.yml automatically.`,
+This command initiates a browser-based login flow. The URL where you can
+authorize this device is opened in your browser, or printed with a QR code
+to scan from your phone if no browser can be opened or --no-browser is
+given. Once authorized, your API token will be saved to
+~/.config/irons/config.yml automatically.
+
+The token is stored in the current profile, or in the profile named by
+--profile, which is created if it does not exist yet. A non-default
+--api-url is saved with it. If the profile has a credential_process, the
+token is handed to its store action instead of being written to disk.
+
+Non-interactive login:
+  For CI and other unattended setups, pass an existing API token with
+  --with-token, which reads it from stdin, or --token-file. The token is
+  checked against the API before it is saved, and --no-save only checks
+  it. Tokens are never accepted as command-line arguments, where they
+  would end up in shell history and process listings.
+
+Examples:
+  irons login
+  irons login --profile staging --api-url https://api.staging.iron.sh/v1
+  irons login --with-token < token.txt
+  irons login --token-file /run/secrets/irons-token --no-save`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(args) > 0 {
+			// Don't echo the argument back: it is probably a token.
+			return errors.New("login takes no arguments; to log in with an existing token, pipe it to `irons login --with-token` or use --token-file so it does not end up in your shell history")
+		}
+		return nil
+	},
 	RunE: func(cmd *cobra.Command, args []string) error {
-		// Use the standard API URL for auth endpoints (no key needed for login).
-		client := newClient()
+		withToken, _ := cmd.Flags().GetBool("with-token")
+		tokenFile, _ := cmd.Flags().GetString("token-file")
+		noSave, _ := cmd.Flags().GetBool("no-save")
+		ctx := cmd.Context()
+
+		if withToken && tokenFile != "" {
+			return errors.New("--with-token and --token-file cannot be used together")
+		}
+		if noSave && !withToken && tokenFile == "" {
+			return errors.New("--no-save requires --with-token or --token-file")
+		}
+
+		// The token is saved to the active profile along with the API URL it
+		// was issued by, unless that is the default.
+		cfg, err := config.Load()
+		if err != nil {
+			return err
+		}
+		profile := activeProfile(cfg)
+		apiURL := viper.GetString("api-url")
+		if apiURL == DefaultAPIURL {
+			apiURL = ""
+		}
+
+		var token string
+		if withToken || tokenFile != "" {
+			if token, err = readToken(tokenFile); err != nil {
+				return err
+			}
+			info, err := checkToken(ctx, token)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("✓ Token is valid for %s in %s\n", formatAccount(info.Account), formatOrganization(info.Organization))
+			if noSave {
+				return nil
+			}
+		} else {
+			// Use the standard API URL for auth endpoints (no key needed for login).
+			noBrowser, _ := cmd.Flags().GetBool("no-browser")
+			if token, err = deviceLogin(ctx, newAnonymousClient(), noBrowser); err != nil || token == "" {
+				return err
+			}
+		}
 
-		// Step 1: request a device code.
-		fmt.Println("Requesting device code...")
-		codeResp, err := client.DeviceCode()
+		where, err := saveToken(ctx, profile, apiURL, token)
 		if err != nil {
-			return fmt.Errorf("requesting device code: %w", err)
+			return fmt.Errorf("saving token: %w", err)
 		}
+		fmt.Printf("✓ Authorized! Your API token has been saved to %s\n", where)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(loginCmd)
+
+	loginCmd.Flags().Bool("with-token", false, "Read an API token from stdin instead of using the browser flow")
+	loginCmd.Flags().String("token-file", "", "Read an API token from this file instead of using the browser flow")
+	loginCmd.Flags().Bool("no-browser", false, "Don't open the verification URL in a browser")
+	loginCmd.Flags().Bool("no-save", false, "Only check the token given by --with-token or --token-file, without saving it")
+}
+
+// slowDownStep is how much the poll interval grows when the server answers
+// slow_down without saying what the new interval is, as in RFC 8628.
+const slowDownStep = 5 * time.Second
 
-		fmt.Printf("\nOpen the following URL in your browser to authenticate:\n\n  %s\n\n", codeResp.VerificationURI)
+// deviceLogin runs the device authorization flow and returns the issued
+// token. It returns an empty token and no error if the user cancels. The
+// verification URL is opened in the browser unless noBrowser is set; if it
+// is not opened, a QR code of it is shown on terminals so it can be
+// scanned from a phone.
+func deviceLogin(ctx context.Context, client api.AuthService, noBrowser bool) (string, error) {
+	// Step 1: request a device code.
+	fmt.Println("Requesting device code...")
+	codeResp, err := client.DeviceCodeContext(ctx)
+	if err != nil {
+		return "", fmt.Errorf("requesting device code: %w", err)
+	}
+
+	tty := term.IsTerminal(int(os.Stdout.Fd()))
+	url := codeResp.VerificationURI
+	if codeResp.VerificationURIComplete != "" {
+		url = codeResp.VerificationURIComplete
+	}
+
+	opened := false
+	if !noBrowser {
+		opened = openBrowser(url) == nil
+	}
+	if opened {
+		fmt.Printf("\nOpened the following URL in your browser:\n\n  %s\n\n", url)
+	} else {
+		fmt.Printf("\nOpen the following URL in your browser to authenticate:\n\n  %s\n\n", url)
+		if tty {
+			if code, err := qr.Encode([]byte(url), qr.L); err == nil {
+				fmt.Printf("or scan this QR code:\n\n%s\n", code.Terminal(2))
+			}
+		}
+	}
+	if codeResp.VerificationURIComplete != "" {
+		fmt.Printf("Check that the page shows this device code: %s\n\n", codeResp.Code)
+	} else {
 		fmt.Printf("Paste in the following device code: %s\n\n", codeResp.Code)
+	}
+	if !tty {
 		fmt.Printf("This code expires at %s.\n\n", codeResp.ExpiresAt.Local().Format(time.RFC1123))
 		fmt.Println("Waiting for authorization...")
+	}
 
-		// Step 2: poll until authorized, expired, or timed out.
-		// We use a 1-second interval here regardless of the shared pollInterval.
-		deadline := time.Now().Add(pollTimeout)
-		ticker := time.NewTicker(1 * time.Second)
-		defer ticker.Stop()
+	// Step 2: poll until authorized, expired, or timed out, no more often
+	// than the server asks. The countdown is redrawn every second on
+	// terminals.
+	interval := time.Second
+	if codeResp.Interval > 0 {
+		interval = time.Duration(codeResp.Interval) * time.Second
+	}
+	// The code is no use once it expires, but a server that does not say
+	// when that is gets pollTimeout.
+	deadline := codeResp.ExpiresAt
+	if deadline.IsZero() {
+		deadline = time.Now().Add(pollTimeout)
+	}
+	nextPoll := time.Now().Add(interval)
+	ticker := time.NewTicker(1 * time.Second)
+	defer ticker.Stop()
 
-		ctx := cmd.Context()
-		for {
-			select {
-			case <-ctx.Done():
-				fmt.Fprintln(os.Stderr, "\nLogin cancelled.")
-				return nil
+	// status clears the countdown line before anything else is printed.
+	status := func(format string, args ...any) {
+		if tty {
+			fmt.Print("\r\x1b[K")
+		}
+		fmt.Printf(format, args...)
+	}
+	if tty {
+		status("Waiting for authorization... %s left", countdown(codeResp.ExpiresAt))
+	}
 
-			case <-ticker.C:
-				if time.Now().After(deadline) {
-					return fmt.Errorf("timed out waiting for authorization")
-				}
+	for {
+		select {
+		case <-ctx.Done():
+			status("")
+			fmt.Fprintln(os.Stderr, "\nLogin cancelled.")
+			return "", nil
 
-				pollResp, err := client.PollDevice(codeResp.Code)
-				if err != nil {
-					// Treat transient errors as non-fatal; keep polling.
-					fmt.Fprintf(os.Stderr, "warning: poll error (retrying): %v\n", err)
-					continue
+		case now := <-ticker.C:
+			if tty {
+				status("Waiting for authorization... %s left", countdown(codeResp.ExpiresAt))
+			}
+			if now.After(deadline) {
+				status("")
+				if !codeResp.ExpiresAt.IsZero() {
+					return "", fmt.Errorf("device code expired — please run `irons login` again")
 				}
+				return "", fmt.Errorf("timed out waiting for authorization")
+			}
+			// Ticks are a second apart; allow for jitter so a 1s interval
+			// polls on every tick.
+			if nextPoll.Sub(now) > 100*time.Millisecond {
+				continue
+			}
 
-				switch pollResp.Status {
-				case "authorized":
-					if err := config.SetAPIKey(pollResp.Token); err != nil {
-						return fmt.Errorf("saving token: %w", err)
-					}
-					fmt.Println("✓ Authorized! Your API token has been saved to ~/.config/irons/config.yml")
-					return nil
+			pollResp, err := client.PollDeviceContext(ctx, codeResp.Code)
+			nextPoll = now.Add(interval)
+			if err != nil {
+				if ctx.Err() != nil {
+					status("")
+					fmt.Fprintln(os.Stderr, "\nLogin cancelled.")
+					return "", nil
+				}
+				// Treat transient errors as non-fatal; keep polling.
+				status("")
+				fmt.Fprintf(os.Stderr, "warning: poll error (retrying): %v\n", err)
+				continue
+			}
 
-				case "expired":
-					return fmt.Errorf("device code expired — please run `irons login` again")
+			switch pollResp.Status {
+			case "authorized":
+				status("")
+				return pollResp.Token, nil
 
-				case "pending":
-					// Still waiting; continue polling.
+			case "expired":
+				status("")
+				return "", fmt.Errorf("device code expired — please run `irons login` again")
 
-				default:
-					return fmt.Errorf("unexpected poll status %q", pollResp.Status)
+			case "slow_down":
+				if pollResp.Interval > 0 {
+					interval = max(interval, time.Duration(pollResp.Interval)*time.Second)
+				} else {
+					interval += slowDownStep
+				}
+				nextPoll = now.Add(interval)
+				if !tty {
+					fmt.Printf("Server asked to slow down; polling every %s\n", interval)
 				}
+
+			case "pending":
+				// Still waiting; continue polling.
+
+			default:
+				status("")
+				return "", fmt.Errorf("unexpected poll status %q", pollResp.Status)
 			}
 		}
-	},
+	}
 }
 
-func init() {
-	rootCmd.AddCommand(loginCmd)
+// countdown formats the time left until t as minutes and seconds.
+func countdown(t time.Time) string {
+	left := max(time.Until(t).Round(time.Second), 0)
+	return fmt.Sprintf("%d:%02d", int(left.Minutes()), int(left.Seconds())%60)
+}
+
+// readToken reads an API token from path, or from stdin if path is empty.
+// On a terminal the token is prompted for with echo disabled; otherwise the
+// first line of input is used.
+func readToken(path string) (string, error) {
+	var token string
+	switch {
+	case path != "":
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("reading token: %w", err)
+		}
+		token, _, _ = strings.Cut(string(data), "\n")
+
+	case term.IsTerminal(int(os.Stdin.Fd())):
+		fmt.Fprint(os.Stderr, "Paste your API token: ")
+		data, err := term.ReadPassword(int(os.Stdin.Fd()))
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return "", fmt.Errorf("reading token: %w", err)
+		}
+		token = string(data)
+
+	default:
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && err != io.EOF {
+			return "", fmt.Errorf("reading token from stdin: %w", err)
+		}
+		token = line
+	}
+
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return "", errors.New("no token provided")
+	}
+	return token, nil
+}
+
+// checkToken asks the API who token belongs to, so a mistyped or revoked
+// token is not saved.
+func checkToken(ctx context.Context, token string) (*api.TokenInfo, error) {
+	client, err := newClientWithKey(token)
+	if err != nil {
+		return nil, err
+	}
+	info, err := client.TokenInfoContext(ctx)
+	if api.IsUnauthorized(err) {
+		// Not wrapped: reportError would blame the configured key.
+		return nil, errors.New("the API rejected the token; it may be mistyped, expired or revoked")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("checking token: %w", err)
+	}
+	return info, nil
+}
+
+// saveToken stores an API token for profile, through the credential helper
+// if one is configured and in the config file otherwise, and describes where
+// it went. apiURL is recorded in the profile unless it is empty.
+func saveToken(ctx context.Context, profile, apiURL, token string) (string, error) {
+	helper := credentialProcess()
+	if helper == nil {
+		if err := config.SetProfileAPIKey(profile, apiURL, token); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("profile %q in ~/.config/irons/config.yml", profile), nil
+	}
+
+	if err := helper.Store(ctx, viper.GetString("api-url"), token); err != nil {
+		return "", err
+	}
+	// Record the profile and its API URL, and drop any plaintext key left
+	// over from before the helper was configured.
+	if err := config.SetProfileAPIKey(profile, apiURL, ""); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("the credential helper for profile %q", profile), nil
 }
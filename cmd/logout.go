@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/ironsh/irons/api"
+	"github.com/ironsh/irons/config"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// logoutCmd represents the logout command
+var logoutCmd = &cobra.Command{
+	Use:   "logout",
+	Short: "Revoke and forget the saved API key",
+	Long: `Revoke the API key of the active profile and remove it from this machine.
+
+The key is revoked on the server so it stops working everywhere, then
+removed from ~/.config/irons/config.yml, or erased through the credential
+helper if the profile has a credential_process. The profile's other
+settings are kept. If the key cannot be revoked, for example because the
+API is unreachable, it is still removed locally and a warning is printed.
+
+A key supplied with --api-key or IRONS_API_KEY is revoked but cannot be
+removed; stop passing it instead.
+
+Examples:
+  irons logout
+  irons logout --profile staging
+  irons logout --local   # keep the key valid, only forget it here`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		local, _ := cmd.Flags().GetBool("local")
+		ctx := cmd.Context()
+
+		cfg, err := config.Load()
+		if err != nil {
+			return err
+		}
+		profile := activeProfile(cfg)
+		helper := credentialProcess()
+		if viper.GetString("api-key") == "" && helper == nil {
+			fmt.Printf("Not logged in to profile %q.\n", profile)
+			return nil
+		}
+
+		if !local {
+			client := newClient()
+			switch err := client.RevokeTokenContext(ctx); {
+			case err == nil:
+				fmt.Println("✓ Revoked the API key")
+			case api.IsUnauthorized(err):
+				fmt.Println("The API key was already invalid")
+			default:
+				fmt.Fprintf(os.Stderr, "Warning: %v\nThe key is removed locally but stays valid until it expires or is revoked from the dashboard.\n", err)
+			}
+		}
+
+		if s, _ := lookupSetting("api_key"); s.explicit() {
+			fmt.Printf("The API key comes from %s, so it was not removed.\n", s.source(cfg, nil, profile))
+			return nil
+		}
+
+		if viper.GetString("api-key") == "" {
+			if err := helper.Erase(ctx); err != nil {
+				return fmt.Errorf("erasing the API key: %w", err)
+			}
+		} else {
+			if err := cfg.Unset(profile, "api_key"); err != nil {
+				return err
+			}
+			if err := config.Save(cfg); err != nil {
+				return fmt.Errorf("saving config: %w", err)
+			}
+		}
+
+		fmt.Printf("✓ Logged out of profile %q\n", profile)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(logoutCmd)
+
+	logoutCmd.Flags().Bool("local", false, "Only remove the API key from this machine, without revoking it")
+}
@@ -0,0 +1,182 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"text/template"
+
+	"github.com/ironsh/irons/jsonpath"
+	"github.com/spf13/viper"
+	"go.yaml.in/yaml/v3"
+)
+
+// printer prints the resources a command shows, such as api.VM or
+// api.Secret values or slices of them, in the format chosen with
+// -o/--output or the output setting:
+//
+//	table              for people (the default)
+//	wide               for people, with more detail
+//	json, yaml         the resource as the API returns it
+//	jsonpath=TEMPLATE  fields picked with a kubectl-style JSONPath template
+//	template=TEMPLATE  a Go text/template applied to the resource
+//
+// Templates see the resource as its JSON decodes, so fields are named as in
+// the API, e.g. {{.status_detail}} or {.status_detail}.
+type printer struct {
+	format string
+	path   *jsonpath.Template
+	tmpl   *template.Template
+}
+
+// newPrinter returns the printer for the output setting.
+func newPrinter() (*printer, error) {
+	return parsePrinter(viper.GetString("output"))
+}
+
+// parsePrinter returns the printer for an output format.
+func parsePrinter(output string) (*printer, error) {
+	format, arg, hasArg := strings.Cut(output, "=")
+	p := &printer{format: format}
+	switch format {
+	case "":
+		p.format = "table"
+		return p, nil
+	case "table", "wide", "json", "yaml":
+		if !hasArg {
+			return p, nil
+		}
+	case "jsonpath":
+		path, err := jsonpath.Parse(arg)
+		if err != nil {
+			return nil, err
+		}
+		p.path = path
+		return p, nil
+	case "template", "go-template":
+		tmpl, err := template.New("output").Parse(arg)
+		if err != nil {
+			return nil, fmt.Errorf("invalid template: %w", err)
+		}
+		p.format, p.tmpl = "template", tmpl
+		return p, nil
+	}
+	return nil, fmt.Errorf("invalid output format %q: must be table, wide, json, yaml, jsonpath=TEMPLATE or template=TEMPLATE", output)
+}
+
+// structured reports whether the output is for programs rather than
+// people.
+func (p *printer) structured() bool {
+	return p.format != "table" && p.format != "wide"
+}
+
+// wide reports whether tables should show more detail.
+func (p *printer) wide() bool {
+	return p.format == "wide"
+}
+
+// messages returns where a command prints messages meant for people, such
+// as progress and confirmations: stdout, unless the output is structured,
+// in which case stdout is kept for the resources it prints.
+func (p *printer) messages() io.Writer {
+	if p.structured() {
+		return os.Stderr
+	}
+	return os.Stdout
+}
+
+// print prints v to stdout. For table and wide output it calls table to
+// print v for people instead.
+func (p *printer) print(v any, table func()) error {
+	if !p.structured() {
+		table()
+		return nil
+	}
+	return p.write(os.Stdout, v, false)
+}
+
+// printEvent is like print for one of a stream of resources, such as audit
+// events that are printed as they arrive: JSON is printed on one line, so
+// the stream is JSON Lines, and YAML as a document of its own.
+func (p *printer) printEvent(v any, line func()) error {
+	if !p.structured() {
+		line()
+		return nil
+	}
+	return p.write(os.Stdout, v, true)
+}
+
+func (p *printer) write(w io.Writer, v any, stream bool) error {
+	// A nil slice is an empty list, not null.
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Slice && rv.IsNil() {
+		v = []any{}
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	switch p.format {
+	case "json":
+		if stream {
+			buf.Write(data)
+		} else if err := json.Indent(&buf, data, "", "  "); err != nil {
+			return err
+		}
+
+	case "yaml":
+		// JSON is YAML, so decoding it into a node keeps the API's field
+		// names and order.
+		var doc yaml.Node
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return err
+		}
+		blockStyle(&doc)
+		out, err := yaml.Marshal(&doc)
+		if err != nil {
+			return err
+		}
+		if stream {
+			buf.WriteString("---\n")
+		}
+		buf.Write(out)
+
+	default:
+		var obj any
+		if err := json.Unmarshal(data, &obj); err != nil {
+			return err
+		}
+		if p.path != nil {
+			err = p.path.Execute(&buf, obj)
+		} else {
+			err = p.tmpl.Execute(&buf, obj)
+		}
+		if err != nil {
+			return fmt.Errorf("applying %s: %w", p.format, err)
+		}
+	}
+
+	if buf.Len() > 0 && !bytes.HasSuffix(buf.Bytes(), []byte("\n")) {
+		buf.WriteByte('\n')
+	}
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+// blockStyle clears the flow style that decoding JSON gives YAML nodes, so
+// they are written in the usual block style.
+func blockStyle(n *yaml.Node) {
+	n.Style &^= yaml.FlowStyle
+	if n.Kind == yaml.ScalarNode && n.Tag == "!!str" {
+		// Quoting is only kept where it is needed.
+		n.Style &^= yaml.DoubleQuotedStyle
+	}
+	for _, c := range n.Content {
+		blockStyle(c)
+	}
+}
@@ -0,0 +1,149 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/ironsh/irons/api"
+	"github.com/ironsh/irons/apitest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrinter_Formats(t *testing.T) {
+	vms := []api.VM{
+		{ID: "vm_1", Name: "a", Status: "running", Labels: map[string]string{"flag": "true"}},
+		{ID: "vm_2", Name: "b", Status: "stopped"},
+	}
+
+	for output, want := range map[string]string{
+		"json":                "[\n  {\n    \"id\": \"vm_1\",\n    \"name\": \"a\",\n    \"status\": \"running\",\n    \"labels\": {\n      \"flag\": \"true\"\n    },\n    \"created_at\": \"\",\n    \"updated_at\": \"\"\n  },\n",
+		"yaml":                "- id: vm_1\n  name: a\n  status: running\n  labels:\n    flag: \"true\"\n  created_at: \"\"\n",
+		"jsonpath={[*].name}": "a b\n",
+		`template={{range .}}{{.id}} {{.status}}{{"\n"}}{{end}}`: "vm_1 running\nvm_2 stopped\n",
+	} {
+		p, err := parsePrinter(output)
+		require.NoError(t, err, output)
+		var b strings.Builder
+		require.NoError(t, p.write(&b, vms, false), output)
+		require.True(t, strings.HasPrefix(b.String(), want), "%s:\n%s", output, b.String())
+	}
+
+	p, err := parsePrinter("json")
+	require.NoError(t, err)
+	var b strings.Builder
+	require.NoError(t, p.write(&b, []api.VM(nil), false))
+	require.Equal(t, "[]\n", b.String())
+
+	b.Reset()
+	require.NoError(t, p.write(&b, vms[1], true))
+	require.Equal(t, `{"id":"vm_2","name":"b","status":"stopped","created_at":"","updated_at":""}`+"\n", b.String())
+
+	for _, output := range []string{"xml", "json=x", "jsonpath={.a", "template={{.a"} {
+		_, err := parsePrinter(output)
+		require.Error(t, err, output)
+	}
+}
+
+func TestOutput_ListAndStatus(t *testing.T) {
+	srv := apitest.NewServer(t, apitest.Options{})
+	a := srv.AddVM(api.VM{Name: "a", Labels: map[string]string{"env": "ci"}})
+	srv.AddVM(api.VM{Name: "b", Status: "stopped", StatusDetail: "stopped"})
+
+	res := runCLIAt(t, srv.URL, nil, "list", "-o", "json")
+	require.Equal(t, 0, res.ExitCode, res.Stderr)
+	var vms []api.VM
+	require.NoError(t, json.Unmarshal([]byte(res.Stdout), &vms))
+	require.Len(t, vms, 2)
+
+	res = runCLIAt(t, srv.URL, nil, "list", "-o", "jsonpath={range [*]}{.name}={.status}{\"\\n\"}{end}")
+	require.Equal(t, 0, res.ExitCode, res.Stderr)
+	require.Equal(t, "a=running\nb=stopped\n", res.Stdout)
+
+	res = runCLIAt(t, srv.URL, nil, "list", "-o", "wide")
+	require.Equal(t, 0, res.ExitCode, res.Stderr)
+	require.Contains(t, res.Stdout, "UPDATED AT")
+
+	res = runCLIAt(t, srv.URL, nil, "status", "a", "-o", "yaml")
+	require.Equal(t, 0, res.ExitCode, res.Stderr)
+	require.Contains(t, res.Stdout, "id: "+a.ID+"\n")
+	require.Contains(t, res.Stdout, "labels:\n    env: ci\n")
+
+	// The output setting applies when -o is not given.
+	res = runCLIWithEnv(t, []string{"IRONS_API_URL=" + srv.URL, "IRONS_API_KEY=test-key", "HOME=" + t.TempDir(), "IRONS_OUTPUT=template={{.name}}"}, nil, "status", "a")
+	require.Equal(t, 0, res.ExitCode, res.Stderr)
+	require.Equal(t, "a\n", res.Stdout)
+
+	res = runCLIAt(t, srv.URL, nil, "list", "-o", "xml")
+	require.NotEqual(t, 0, res.ExitCode)
+	require.Contains(t, res.Stderr, `invalid output format "xml"`)
+}
+
+func TestOutput_ChatterGoesToStderr(t *testing.T) {
+	srv := apitest.NewServer(t, apitest.Options{})
+	key := writeTestKey(t)
+
+	res := runCLIAt(t, srv.URL, nil, "create", "--key", key, "-o", "json", "agent-1")
+	require.Equal(t, 0, res.ExitCode, res.Stderr)
+	var vm api.VM
+	require.NoError(t, json.Unmarshal([]byte(res.Stdout), &vm), res.Stdout)
+	require.Equal(t, "running", vm.Status)
+	require.Contains(t, res.Stderr, "Creating VM 'agent-1'")
+	require.Contains(t, res.Stderr, "is ready!")
+
+	res = runCLIAt(t, srv.URL, nil, "stop", "agent-1", "-o", "json")
+	require.Equal(t, 0, res.ExitCode, res.Stderr)
+	require.NoError(t, json.Unmarshal([]byte(res.Stdout), &vm), res.Stdout)
+	require.Equal(t, "stopped", vm.Status)
+
+	srv.AddVM(api.VM{Name: "agent-2", Status: "stopped", StatusDetail: "stopped"})
+	res = runCLIAt(t, srv.URL, nil, "start", "--all", "-o", "json")
+	require.Equal(t, 0, res.ExitCode, res.Stderr)
+	var vms []api.VM
+	require.NoError(t, json.Unmarshal([]byte(res.Stdout), &vms), res.Stdout)
+	require.Len(t, vms, 2)
+	require.Contains(t, res.Stderr, "✓ 2 VMs started")
+
+	res = runCLIAt(t, srv.URL, nil, "ssh", "agent-1", "-o", "jsonpath={.username}@{.host}:{.port}")
+	require.Equal(t, 0, res.ExitCode, res.Stderr)
+	require.Regexp(t, `^\w+@127\.0\.0\.1:22\n$`, res.Stdout)
+}
+
+func TestOutput_EgressSecretsAndAudit(t *testing.T) {
+	srv := apitest.NewServer(t, apitest.Options{})
+	srv.AddAuditEvents(
+		api.EgressAuditEvent{VMID: "vm_1", Host: "github.com", Allowed: true},
+		api.EgressAuditEvent{VMID: "vm_1", Host: "evil.example", Allowed: false},
+	)
+
+	res := runCLIAt(t, srv.URL, nil, "egress", "add", "--host", "crates.io", "-o", "json")
+	require.Equal(t, 0, res.ExitCode, res.Stderr)
+	var rule api.EgressRule
+	require.NoError(t, json.Unmarshal([]byte(res.Stdout), &rule), res.Stdout)
+	require.Equal(t, "crates.io", rule.Host)
+
+	res = runCLIAt(t, srv.URL, nil, "egress", "list", "-o", "jsonpath={[*].host}")
+	require.Equal(t, 0, res.ExitCode, res.Stderr)
+	require.Equal(t, "crates.io\n", res.Stdout)
+
+	res = runCLIAt(t, srv.URL, nil, "secrets", "add", "--name", "gh", "--env-var", "GITHUB_TOKEN", "--secret", "ghp_x", "-o", "yaml")
+	require.Equal(t, 0, res.ExitCode, res.Stderr)
+	require.Contains(t, res.Stdout, "env_var: GITHUB_TOKEN\n")
+	require.NotContains(t, res.Stdout, "ghp_x")
+
+	res = runCLIAt(t, srv.URL, nil, "secrets", "list", "-o", "wide")
+	require.Equal(t, 0, res.ExitCode, res.Stderr)
+	require.Contains(t, res.Stdout, "COMMENT")
+
+	res = runCLIAt(t, srv.URL, nil, "audit", "egress", "--since", "2000-01-01T00:00:00Z", "-o", "json")
+	require.Equal(t, 0, res.ExitCode, res.Stderr)
+	var hosts []string
+	sc := bufio.NewScanner(strings.NewReader(res.Stdout))
+	for sc.Scan() {
+		var ev api.EgressAuditEvent
+		require.NoError(t, json.Unmarshal(sc.Bytes(), &ev), sc.Text())
+		hosts = append(hosts, ev.Host)
+	}
+	require.Equal(t, []string{"github.com", "evil.example"}, hosts)
+}
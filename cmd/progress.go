@@ -0,0 +1,149 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"golang.org/x/term"
+)
+
+// waitErrorThreshold is how many checks in a row must fail before a wait
+// reports the errors. Occasional failures are expected and retried.
+const waitErrorThreshold = 3
+
+// spinnerFrames are drawn in turn by waitProgress on terminals.
+var spinnerFrames = []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
+
+// waitProgress reports the progress of a wait: the elapsed time, each state
+// the VM is seen in, and checks that keep failing. On terminals it draws a
+// spinner line that is redrawn in place, with a line kept for each change of
+// state; otherwise it prints a timestamped line for each change. A nil out
+// reports nothing.
+type waitProgress struct {
+	mu      sync.Mutex
+	out     io.Writer
+	tty     bool
+	what    string
+	start   time.Time
+	state   string
+	errs    int
+	lastErr error
+	frame   int
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// newWaitProgress starts reporting on a wait for what, e.g. "VM 'vm_abc'".
+// finish must be called when the wait ends.
+func newWaitProgress(out io.Writer, what string) *waitProgress {
+	p := &waitProgress{out: out, what: what, start: time.Now(), stop: make(chan struct{}), done: make(chan struct{})}
+	if out == nil {
+		p.out = io.Discard
+		close(p.done)
+		return p
+	}
+	if f, ok := out.(*os.File); ok && term.IsTerminal(int(f.Fd())) {
+		p.tty = true
+		p.redraw()
+		go p.spin()
+	} else {
+		p.println("Waiting for " + what)
+		close(p.done)
+	}
+	return p
+}
+
+// spin redraws the spinner until finish is called.
+func (p *waitProgress) spin() {
+	defer close(p.done)
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+			p.mu.Lock()
+			p.frame++
+			p.redraw()
+			p.mu.Unlock()
+		}
+	}
+}
+
+// observe records a successful check that found the VM in state, reporting
+// it if it has changed.
+func (p *waitProgress) observe(state string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.errs, p.lastErr = 0, nil
+	if state == p.state {
+		return
+	}
+	p.state = state
+	p.println(fmt.Sprintf("%s is %s", p.what, state))
+}
+
+// fail records a failed check. Once waitErrorThreshold checks in a row have
+// failed, the count and the last error are reported.
+func (p *waitProgress) fail(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.errs, p.lastErr = p.errs+1, err
+	if p.tty {
+		p.redraw()
+	} else if p.errs >= waitErrorThreshold {
+		p.println(p.errorSummary())
+	}
+}
+
+// finish stops reporting and clears the spinner.
+func (p *waitProgress) finish() {
+	select {
+	case <-p.stop:
+		return
+	default:
+		close(p.stop)
+	}
+	<-p.done
+	if p.tty {
+		p.mu.Lock()
+		fmt.Fprint(p.out, "\r\x1b[K")
+		p.mu.Unlock()
+	}
+}
+
+// println prints a line that is kept, prefixed with the elapsed time, and
+// with the time of day too when not on a terminal. It must be called with
+// p.mu held, except before the spinner starts.
+func (p *waitProgress) println(s string) {
+	elapsed := time.Since(p.start).Round(time.Second)
+	if p.tty {
+		fmt.Fprintf(p.out, "\r\x1b[K[%s] %s\n", elapsed, s)
+		p.redraw()
+		return
+	}
+	fmt.Fprintf(p.out, "%s [%s] %s\n", time.Now().Format("15:04:05"), elapsed, s)
+}
+
+// redraw draws the spinner line. It must be called with p.mu held.
+func (p *waitProgress) redraw() {
+	line := fmt.Sprintf("%s Waiting for %s (%s)", spinnerFrames[p.frame%len(spinnerFrames)], p.what, time.Since(p.start).Round(time.Second))
+	if p.state != "" {
+		line += ": " + p.state
+	}
+	if p.errs >= waitErrorThreshold {
+		line += " — " + p.errorSummary()
+	}
+	fmt.Fprintf(p.out, "\r\x1b[K%s", line)
+}
+
+// errorSummary describes the failed checks. It must be called with p.mu
+// held.
+func (p *waitProgress) errorSummary() string {
+	return fmt.Sprintf("%d checks in a row failed, last: %v", p.errs, p.lastErr)
+}
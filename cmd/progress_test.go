@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ironsh/irons/api"
+	"github.com/ironsh/irons/apitest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWaitProgress_Lines(t *testing.T) {
+	var buf bytes.Buffer
+	p := newWaitProgress(&buf, "VM 'vm_abc123'")
+	p.observe("creating/provisioning")
+	p.observe("creating/provisioning")
+	for range waitErrorThreshold - 1 {
+		p.fail(errors.New("connection reset"))
+	}
+	require.NotContains(t, buf.String(), "failed")
+	p.fail(errors.New("connection refused"))
+	p.observe("running/ready")
+	p.finish()
+
+	out := buf.String()
+	require.Contains(t, out, "Waiting for VM 'vm_abc123'")
+	require.Equal(t, 1, bytes.Count(buf.Bytes(), []byte("is creating/provisioning")))
+	require.Contains(t, out, "3 checks in a row failed, last: connection refused")
+	require.Contains(t, out, "VM 'vm_abc123' is running/ready")
+	require.Regexp(t, `(?m)^\d\d:\d\d:\d\d \[0s\] VM 'vm_abc123' is running/ready$`, out)
+}
+
+func TestWaitProgress_Nil(t *testing.T) {
+	p := newWaitProgress(nil, "VM 'vm_abc123'")
+	p.observe("running")
+	p.fail(errors.New("boom"))
+	p.finish()
+}
+
+func TestCreate_ReportsProgressOnStderr(t *testing.T) {
+	srv := apitest.NewServer(t, apitest.Options{TransitionDelay: 100 * time.Millisecond})
+	key := writeTestKey(t)
+
+	res := runCLIAt(t, srv.URL, nil, "create", "--key", key, "agent-1")
+	require.Equal(t, 0, res.ExitCode, res.Stderr)
+	require.Contains(t, res.Stderr, "is creating/provisioning")
+	require.Contains(t, res.Stderr, "is running/ready")
+	require.NotContains(t, res.Stdout, "Waiting for")
+	require.Contains(t, res.Stdout, "is ready!")
+
+	vm := srv.AddVM(api.VM{Name: "agent-2", Status: "stopped", StatusDetail: "stopped"})
+	res = runCLIAt(t, srv.URL, nil, "start", "--quiet", vm.ID)
+	require.Equal(t, 0, res.ExitCode, res.Stderr)
+	require.Empty(t, res.Stderr)
+	require.Contains(t, res.Stdout, "started successfully")
+}
@@ -0,0 +1,120 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/ironsh/irons/api"
+)
+
+// provisionStep is one file upload or script run performed on a new VM.
+type provisionStep struct {
+	// Desc describes the step in progress output and the failure report.
+	Desc string
+	run  func(ctx context.Context, resp *api.SSHResponse, out io.Writer) error
+}
+
+// provisionSteps checks the --upload and --provision arguments and returns
+// the steps that perform them: the uploads, then the scripts, each in the
+// order given. Uploads take the form local:remote, where an empty remote is
+// the VM user's home directory.
+func provisionSteps(uploads, scripts []string) ([]provisionStep, error) {
+	var steps []provisionStep
+
+	for _, u := range uploads {
+		// Split at the last colon so Windows drive letters stay with the
+		// local path.
+		i := strings.LastIndex(u, ":")
+		if i <= 0 {
+			return nil, fmt.Errorf("invalid --upload %q: must be local:remote", u)
+		}
+		local, remote := u[:i], u[i+1:]
+		if _, err := os.Stat(local); err != nil {
+			return nil, fmt.Errorf("invalid --upload %q: %w", u, err)
+		}
+		dest := remote
+		if dest == "" {
+			dest = "~"
+		}
+		steps = append(steps, provisionStep{
+			Desc: fmt.Sprintf("upload %s -> %s", local, dest),
+			run: func(ctx context.Context, resp *api.SSHResponse, out io.Writer) error {
+				return streamCommand(scpCommand(ctx, resp, local, remote), out)
+			},
+		})
+	}
+
+	for i, script := range scripts {
+		if _, err := os.Stat(script); err != nil {
+			return nil, fmt.Errorf("invalid --provision %q: %w", script, err)
+		}
+		// Scripts are copied over and executed rather than piped to a shell,
+		// so their #! line is honoured.
+		remote := fmt.Sprintf("/tmp/irons-provision-%d-%s", i+1, filepath.Base(script))
+		steps = append(steps, provisionStep{
+			Desc: "run " + script,
+			run: func(ctx context.Context, resp *api.SSHResponse, out io.Writer) error {
+				if err := streamCommand(scpCommand(ctx, resp, script, remote), out); err != nil {
+					return fmt.Errorf("copying script: %w", err)
+				}
+				q := shellQuote(remote)
+				return streamCommand(sshCommand(ctx, resp, "chmod +x "+q+" && "+q+"; status=$?; rm -f "+q+"; exit $status"), out)
+			},
+		})
+	}
+
+	return steps, nil
+}
+
+// provision runs steps on the VM in order, streaming their output to out.
+// It stops at the first failure and prints a report of which steps ran.
+func provision(ctx context.Context, client api.VMService, id string, steps []provisionStep, out io.Writer) error {
+	resp, err := client.SSHContext(ctx, id)
+	if err != nil {
+		return fmt.Errorf("getting SSH info: %w", err)
+	}
+
+	for i, step := range steps {
+		fmt.Fprintf(out, "==> [%d/%d] %s\n", i+1, len(steps), step.Desc)
+		if err := step.run(ctx, resp, out); err != nil {
+			if ctx.Err() != nil {
+				err = ctx.Err()
+			}
+			printProvisionReport(out, steps, i, err)
+			return fmt.Errorf("provisioning failed at step %d of %d (%s): %w", i+1, len(steps), step.Desc, err)
+		}
+	}
+	fmt.Fprintf(out, "✓ Provisioned with %d step(s)\n", len(steps))
+	return nil
+}
+
+// streamCommand runs c with its output going to out and its errors to
+// stderr.
+func streamCommand(c *exec.Cmd, out io.Writer) error {
+	c.Stdout = out
+	c.Stderr = os.Stderr
+	return c.Run()
+}
+
+// printProvisionReport lists the steps, marking those before failed as done,
+// failed with its error, and the rest as skipped.
+func printProvisionReport(out io.Writer, steps []provisionStep, failed int, err error) {
+	fmt.Fprintln(out, "\nProvisioning report:")
+	for i, step := range steps {
+		switch {
+		case i < failed:
+			fmt.Fprintf(out, "  %s [%d/%d] %s\n", color.GreenString("✓"), i+1, len(steps), step.Desc)
+		case i == failed:
+			fmt.Fprintf(out, "  %s [%d/%d] %s: %v\n", color.RedString("✗"), i+1, len(steps), step.Desc, err)
+		default:
+			fmt.Fprintf(out, "  - [%d/%d] %s (skipped)\n", i+1, len(steps), step.Desc)
+		}
+	}
+	fmt.Fprintln(out)
+}
@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ironsh/irons/apitest"
+	"github.com/stretchr/testify/require"
+)
+
+// writeScripts writes empty provisioning scripts and returns their paths.
+func writeScripts(t *testing.T, names ...string) []string {
+	t.Helper()
+	dir := t.TempDir()
+	var paths []string
+	for _, name := range names {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, []byte("#!/bin/sh\n"), 0o755))
+		paths = append(paths, path)
+	}
+	return paths
+}
+
+func TestCreate_Provisions(t *testing.T) {
+	srv := apitest.NewServer(t, apitest.Options{})
+	env, log := fakeSSH(t, srv)
+	scripts := writeScripts(t, "a.sh", "b.sh")
+	data := writeScripts(t, "data.txt")[0]
+
+	res := runCLIWithEnv(t, env, nil, "create", "agent-1",
+		"--provision", scripts[0], "--upload", data+":/etc/data.txt", "--provision", scripts[1])
+	require.Equal(t, 0, res.ExitCode, res.Stderr)
+	require.Contains(t, res.Stdout, "==> [1/3] upload "+data+" -> /etc/data.txt")
+	require.Contains(t, res.Stdout, "==> [2/3] run "+scripts[0])
+	require.Contains(t, res.Stdout, "==> [3/3] run "+scripts[1])
+	require.Contains(t, res.Stdout, "✓ Provisioned with 3 step(s)")
+	require.Contains(t, res.Stdout, "is ready!")
+
+	out := readLog(t, log)
+	require.Contains(t, out, "-- "+data+" iron@127.0.0.1:/etc/data.txt")
+	require.Contains(t, out, "-- "+scripts[0]+" iron@127.0.0.1:/tmp/irons-provision-1-a.sh")
+	require.Contains(t, out, "iron@127.0.0.1 chmod +x /tmp/irons-provision-2-b.sh && /tmp/irons-provision-2-b.sh;")
+}
+
+func TestCreate_ProvisionFailureReport(t *testing.T) {
+	srv := apitest.NewServer(t, apitest.Options{})
+	env, _ := fakeSSH(t, srv)
+	scripts := writeScripts(t, "a.sh", "b.sh")
+
+	res := runCLIWithEnv(t, append(env, "FAKE_SSH_EXIT=2"), nil, "create", "agent-1",
+		"--provision", scripts[0], "--provision", scripts[1])
+	require.Equal(t, 1, res.ExitCode)
+	require.Contains(t, res.Stdout, "✗ [1/2] run "+scripts[0]+": exit status 2")
+	require.Contains(t, res.Stdout, "- [2/2] run "+scripts[1]+" (skipped)")
+	require.Contains(t, res.Stdout, "was not fully provisioned")
+	require.Contains(t, res.Stderr, "provisioning failed at step 1 of 2")
+	require.Equal(t, "running", onlyVM(t, srv).Status)
+}
+
+func TestCreate_ProvisionValidatesBeforeCreating(t *testing.T) {
+	srv := apitest.NewServer(t, apitest.Options{})
+	env, _ := fakeSSH(t, srv)
+
+	for _, args := range [][]string{
+		{"--provision", "/nonexistent.sh"},
+		{"--upload", "no-remote-path"},
+		{"--async", "--provision", writeScripts(t, "a.sh")[0]},
+	} {
+		res := runCLIWithEnv(t, env, nil, append([]string{"create", "agent-1"}, args...)...)
+		require.Equal(t, 1, res.ExitCode, args)
+	}
+	require.Zero(t, srv.CountRequests("POST", "/vms"))
+}
@@ -2,10 +2,13 @@ package cmd
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"os"
 	"os/signal"
 	"syscall"
 
+	"github.com/ironsh/irons/api"
 	"github.com/ironsh/irons/config"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -27,12 +30,20 @@ level, meaning you can allowlist only the domains an agent needs to reach (e.g.
 internal API) and block everything else. Rules can also be set to warn mode, which logs violations without
 blocking them — useful for auditing before locking things down.`,
 	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		// From here on any error comes from running the command rather than
+		// from parsing its arguments, so Execute reports it and usage is noise.
+		cmd.SilenceErrors = true
+		cmd.SilenceUsage = true
+
 		// Skip validation for commands that don't need an API key.
-		if cmd.Name() == "help" || cmd.Name() == "login" || (cmd.Name() == "irons" && len(args) == 0) {
+		if cmd.Name() == "help" || cmd.Name() == "login" || cmd.Name() == "logout" || cmd.Name() == "version" || (cmd.Name() == "irons" && len(args) == 0) {
+			return
+		}
+		if cmd.HasParent() && cmd.Parent() == configCmd {
 			return
 		}
 
-		if viper.GetString("api-key") == "" {
+		if viper.GetString("api-key") == "" && viper.GetString("credential-process") == "" {
 			requireAuth()
 		}
 	},
@@ -41,42 +52,102 @@ blocking them — useful for auditing before locking things down.`,
 func Execute(version string) {
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer stop()
+	// Commands may clean up after the first signal, e.g. run destroying its
+	// VM, so a second one restores the default behaviour and kills irons.
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
 
 	rootCmd.Version = version
-	err := rootCmd.ExecuteContext(ctx)
+	cmd, err := rootCmd.ExecuteContextC(ctx)
+	var exit *exitError
+	if errors.As(err, &exit) {
+		os.Exit(exit.Code)
+	}
 	if err != nil {
+		// Cobra has already printed argument and flag errors; runtime errors
+		// are silenced in PersistentPreRun so they can be reported here.
+		if cmd.SilenceErrors {
+			reportError(err)
+		}
 		os.Exit(1)
 	}
 }
 
+// exitError makes Execute exit with Code without printing anything, for
+// commands such as run that pass on the exit status of another program.
+type exitError struct {
+	Code int
+}
+
+func (e *exitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.Code)
+}
+
 func init() {
+	cobra.OnInitialize(initConfig)
+
 	// Global persistent flags
+	rootCmd.PersistentFlags().String("profile", "", "Configuration profile to use instead of the current one")
 	rootCmd.PersistentFlags().String("api-url", DefaultAPIURL, "API endpoint URL")
 	rootCmd.PersistentFlags().String("api-key", "", "API key for authentication")
 	rootCmd.PersistentFlags().Bool("debug-api", false, "Dump API requests and responses to stderr")
+	rootCmd.PersistentFlags().Bool("debug-api-unsafe", false, "Like --debug-api, but without redacting credentials and secret values")
 	rootCmd.PersistentFlags().Bool("debug-tls-skip-verify", false, "Disable TLS certificate verification (insecure, for debugging only)")
+	rootCmd.PersistentFlags().String("ca-cert", "", "PEM file of additional certificate authorities to trust")
+	rootCmd.PersistentFlags().String("client-cert", "", "PEM client certificate for mutual TLS")
+	rootCmd.PersistentFlags().String("client-key", "", "PEM private key for --client-cert")
+	rootCmd.PersistentFlags().String("proxy", "", "Proxy URL for API requests (default from HTTPS_PROXY/HTTP_PROXY)")
+	rootCmd.PersistentFlags().StringP("output", "o", "", "Output format: table, wide, json, yaml, jsonpath=TEMPLATE or template=TEMPLATE (default table)")
+	rootCmd.PersistentFlags().Int("retries", api.DefaultRetryPolicy().MaxRetries, "Number of times to retry failed API requests (0 to disable)")
 
-	// Bind flags to environment variables
-	viper.BindPFlag("api-url", rootCmd.PersistentFlags().Lookup("api-url"))
-	viper.BindPFlag("api-key", rootCmd.PersistentFlags().Lookup("api-key"))
+	// Bind the remaining flags to environment variables. Flags that can be
+	// persisted with `irons config set` are bound by initConfig.
+	viper.BindPFlag("profile", rootCmd.PersistentFlags().Lookup("profile"))
 	viper.BindPFlag("debug-api", rootCmd.PersistentFlags().Lookup("debug-api"))
+	viper.BindPFlag("debug-api-unsafe", rootCmd.PersistentFlags().Lookup("debug-api-unsafe"))
 	viper.BindPFlag("debug-tls-skip-verify", rootCmd.PersistentFlags().Lookup("debug-tls-skip-verify"))
 
-	// Set environment variable names
-	viper.BindEnv("api-url", "IRONS_API_URL")
-	viper.BindEnv("api-key", "IRONS_API_KEY")
+	viper.BindEnv("profile", "IRONS_PROFILE")
 	viper.BindEnv("debug-api", "IRONS_DEBUG_API")
+	viper.BindEnv("debug-api-unsafe", "IRONS_DEBUG_API_UNSAFE")
 	viper.BindEnv("debug-tls-skip-verify", "IRONS_DEBUG_TLS_SKIP_VERIFY")
 
-	// Load the API key from ~/.config/irons/config.yml (written by `irons login`).
-	// A flag or environment variable always takes precedence over the config file.
-	if viper.GetString("api-key") == "" {
-		if cfg, err := config.Load(); err == nil && cfg.APIKey != "" {
-			viper.Set("api-key", cfg.APIKey)
-		}
-	}
-
 	// Cobra also supports local flags which will only run
 	// when this action is called directly.
 	rootCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
 }
+
+// initConfig binds every setting through viper and applies the config
+// files below flags and environment variables: values from the project's
+// .irons.yml, then from the active profile and the global settings in the
+// user's config file, become viper defaults. It runs after flags are parsed
+// so --profile is known.
+func initConfig() {
+	for _, s := range settings {
+		s.bind()
+	}
+
+	if cfg, err := config.Load(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	} else {
+		profile := activeProfile(cfg)
+		for _, s := range settings {
+			if v, ok := cfg.Get(profile, s.Key); ok {
+				viper.SetDefault(s.Name, v)
+			}
+		}
+	}
+
+	// The project file is applied last so that it wins.
+	if proj, err := loadProject(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	} else if proj != nil {
+		for _, s := range settings {
+			if v, ok := proj.Get(s.Key); ok {
+				viper.SetDefault(s.Name, v)
+			}
+		}
+	}
+}
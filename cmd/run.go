@@ -0,0 +1,218 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/ironsh/irons/api"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// runCmd represents the run command
+var runCmd = &cobra.Command{
+	Use:   "run [flags] -- command [args...]",
+	Short: "Run a command in a temporary VM",
+	Long: `Create a VM, run a command in it over SSH, and destroy the VM.
+
+The command's output is streamed as it runs, and irons exits with the
+command's exit status. The VM is destroyed however the command ends,
+including when irons is interrupted with Ctrl-C or SIGTERM. With
+--keep-on-failure, a VM whose command fails is left running so it can be
+inspected with 'irons ssh'; destroy it with 'irons destroy --force' when
+done.
+
+The command and its arguments are passed to the remote shell quoted, so
+they are run as given. To use pipes or other shell syntax, run a shell:
+
+  irons run -- sh -c 'make test | tee test.log'
+
+With --upload, a local directory is copied to the VM's home directory
+before the command runs, and the command runs inside it.
+
+The VM is created as by 'irons create': its SSH key, egress mode and name
+prefix come from the settings and the project's .irons.yml. Without
+--name, a name is generated.
+
+Examples:
+  irons run -- uname -a
+  irons run --upload . -- make test
+  irons run --name agent-job-42 --keep-on-failure -- ./agent.sh`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name, _ := cmd.Flags().GetString("name")
+		upload, _ := cmd.Flags().GetString("upload")
+		keep, _ := cmd.Flags().GetBool("keep-on-failure")
+		applyEgress, _ := cmd.Flags().GetBool("apply-project-egress")
+		keyPath := viper.GetString("ssh-key")
+		egressMode := viper.GetString("egress-mode")
+
+		prefix := viper.GetString("name-prefix")
+		if name == "" && prefix == "" {
+			prefix = "run-"
+		}
+		name, err := vmName(name, prefix)
+		if err != nil {
+			return err
+		}
+
+		if upload != "" {
+			if info, err := os.Stat(upload); err != nil {
+				return fmt.Errorf("reading upload directory: %w", err)
+			} else if !info.IsDir() {
+				return fmt.Errorf("%s is not a directory", upload)
+			}
+		}
+
+		keyContent, err := os.ReadFile(keyPath)
+		if err != nil {
+			return fmt.Errorf("reading SSH key file %s: %w", keyPath, err)
+		}
+
+		client := newClient()
+		ctx := cmd.Context()
+
+		proj, err := loadProject()
+		if err != nil {
+			return err
+		}
+		if proj != nil {
+			if err := checkProjectEgress(ctx, client, proj, applyEgress, os.Stderr); err != nil {
+				return err
+			}
+		}
+
+		// Progress goes to stderr so that stdout is the command's output.
+		fmt.Fprintf(os.Stderr, "Creating VM '%s'...\n", name)
+		// A signal must not abandon the create, or the VM could be created
+		// without its ID ever being known to tear it down. Retries reuse the
+		// request's idempotency key, so only one VM is created.
+		vm, err := client.CreateContext(context.WithoutCancel(ctx), keyContent, name)
+		if err != nil {
+			return fmt.Errorf("creating VM: %w", err)
+		}
+
+		code, err := func() (int, error) {
+			if err := ctx.Err(); err != nil {
+				return 0, fmt.Errorf("interrupted: %w", err)
+			}
+			if egressMode != "" {
+				if err := client.VMEgressSetPolicyContext(ctx, vm.ID, egressMode); err != nil {
+					return 0, fmt.Errorf("setting egress mode: %w", err)
+				}
+			}
+			return runInVM(ctx, client, vm.ID, upload, args)
+		}()
+
+		if keep && (err != nil || code != 0) {
+			fmt.Fprintf(os.Stderr, "Keeping VM '%s' for debugging. Destroy it with: irons destroy --force %s\n", name, name)
+		} else {
+			// Tear down even if ctx was cancelled by a signal.
+			teardownCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), pollTimeout)
+			defer cancel()
+			if ctx.Err() != nil {
+				fmt.Fprintln(os.Stderr, "Interrupted; interrupt again to stop waiting and leave the VM.")
+			}
+			fmt.Fprintf(os.Stderr, "Destroying VM '%s'...\n", name)
+			if derr := stopAndDestroy(teardownCtx, client, vm.ID); derr != nil {
+				derr = fmt.Errorf("VM '%s' was not destroyed: %w; destroy it with: irons destroy --force %s", name, derr, name)
+				return errors.Join(err, derr)
+			}
+		}
+
+		if err != nil {
+			return err
+		}
+		if code != 0 {
+			return &exitError{Code: code}
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(runCmd)
+
+	runCmd.Flags().String("name", "", "Name of the VM (default: generated)")
+	runCmd.Flags().String("upload", "", "Local directory to copy to the VM and run the command in")
+	runCmd.Flags().Bool("keep-on-failure", false, "Leave the VM running if the command fails or is interrupted")
+	runCmd.Flags().Bool("apply-project-egress", false, "Add egress rules listed in .irons.yml that the account is missing")
+}
+
+// runInVM waits for the VM to be ready, uploads dir to it if dir is not
+// empty, and runs args over SSH with the standard streams attached. It
+// returns the command's exit status, or an error if it could not be run or
+// ctx was cancelled.
+func runInVM(ctx context.Context, client api.VMService, id, dir string, args []string) (int, error) {
+	if err := waitForVMCond(ctx, client, id, statusAndDetailEq("running", "ready")); err != nil {
+		return 0, err
+	}
+
+	resp, err := client.SSHContext(ctx, id)
+	if err != nil {
+		return 0, fmt.Errorf("getting SSH info: %w", err)
+	}
+
+	remote := shellJoin(args)
+	if dir != "" {
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			return 0, err
+		}
+		fmt.Fprintf(os.Stderr, "Uploading %s...\n", dir)
+		scp := scpCommand(ctx, resp, abs, "")
+		scp.Stdout = os.Stderr
+		scp.Stderr = os.Stderr
+		if err := scp.Run(); err != nil {
+			if ctx.Err() != nil {
+				return 0, fmt.Errorf("interrupted while uploading: %w", ctx.Err())
+			}
+			return 0, fmt.Errorf("uploading %s: %w", dir, err)
+		}
+		remote = "cd " + shellQuote(filepath.Base(abs)) + " && " + remote
+	}
+
+	ssh := sshCommand(ctx, resp, remote)
+	ssh.Stdin = os.Stdin
+	ssh.Stdout = os.Stdout
+	ssh.Stderr = os.Stderr
+
+	err = ssh.Run()
+	if ctx.Err() != nil {
+		return 0, fmt.Errorf("interrupted: %w", ctx.Err())
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode(), nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("running ssh: %w", err)
+	}
+	return 0, nil
+}
+
+// shellSafe matches words that need no quoting in a POSIX shell.
+var shellSafe = regexp.MustCompile(`^[A-Za-z0-9_@%+=:,./-]+$`)
+
+// shellQuote quotes s for a POSIX shell.
+func shellQuote(s string) string {
+	if shellSafe.MatchString(s) {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// shellJoin quotes each of args and joins them into a shell command line.
+func shellJoin(args []string) string {
+	quoted := make([]string, len(args))
+	for i, a := range args {
+		quoted[i] = shellQuote(a)
+	}
+	return strings.Join(quoted, " ")
+}
@@ -0,0 +1,154 @@
+package cmd
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/ironsh/irons/api"
+	"github.com/ironsh/irons/apitest"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSSH puts ssh and scp scripts first on the PATH that log their
+// arguments. ssh exits with $FAKE_SSH_EXIT, or sleeps if $FAKE_SSH_SLEEP is
+// set. It returns the environment to run the CLI with and the log file.
+func fakeSSH(t *testing.T, srv *apitest.Server) (env []string, log string) {
+	t.Helper()
+	bin := t.TempDir()
+	log = filepath.Join(bin, "log")
+	for _, tool := range []string{"ssh", "scp"} {
+		script := "#!/bin/sh\necho " + tool + ` "$@" >> ` + log + "\n"
+		if tool == "ssh" {
+			script += "[ -n \"$FAKE_SSH_SLEEP\" ] && exec sleep 30\necho remote output\nexit ${FAKE_SSH_EXIT:-0}\n"
+		}
+		require.NoError(t, os.WriteFile(filepath.Join(bin, tool), []byte(script), 0o755))
+	}
+	return []string{
+		"PATH=" + bin + string(os.PathListSeparator) + os.Getenv("PATH"),
+		"HOME=" + t.TempDir(),
+		"IRONS_API_URL=" + srv.URL,
+		"IRONS_API_KEY=test-key",
+		"IRONS_SSH_KEY=" + writeTestKey(t),
+	}, log
+}
+
+// onlyVM returns the single VM on srv.
+func onlyVM(t *testing.T, srv *apitest.Server) api.VM {
+	t.Helper()
+	vms, err := api.Collect(srv.Client().AllVMs(t.Context()), 0)
+	require.NoError(t, err)
+	require.Len(t, vms, 1)
+	return vms[0]
+}
+
+func readLog(t *testing.T, log string) string {
+	t.Helper()
+	data, err := os.ReadFile(log)
+	require.NoError(t, err)
+	return string(data)
+}
+
+func TestRun_RunsCommandAndDestroysVM(t *testing.T) {
+	srv := apitest.NewServer(t, apitest.Options{})
+	env, log := fakeSSH(t, srv)
+
+	res := runCLIWithEnv(t, env, nil, "run", "--name", "job", "--", "echo", "hello world", "it's")
+	require.Equal(t, 0, res.ExitCode, res.Stderr)
+	require.Contains(t, res.Stdout, "remote output")
+	require.Contains(t, res.Stderr, "Destroying VM 'job'")
+	require.Contains(t, readLog(t, log), `iron@127.0.0.1 echo 'hello world' 'it'\''s'`)
+
+	vm := onlyVM(t, srv)
+	require.Equal(t, "job", vm.Name)
+	require.Equal(t, "destroyed", vm.Status)
+}
+
+func TestRun_PassesOnExitStatus(t *testing.T) {
+	srv := apitest.NewServer(t, apitest.Options{})
+	env, _ := fakeSSH(t, srv)
+
+	res := runCLIWithEnv(t, append(env, "FAKE_SSH_EXIT=3"), nil, "run", "--", "false")
+	require.Equal(t, 3, res.ExitCode, res.Stderr)
+	require.NotContains(t, res.Stderr, "Error")
+
+	vm := onlyVM(t, srv)
+	require.True(t, strings.HasPrefix(vm.Name, "run-"), vm.Name)
+	require.Equal(t, "destroyed", vm.Status)
+}
+
+func TestRun_KeepOnFailure(t *testing.T) {
+	srv := apitest.NewServer(t, apitest.Options{})
+	env, _ := fakeSSH(t, srv)
+
+	res := runCLIWithEnv(t, append(env, "FAKE_SSH_EXIT=1"), nil, "run", "--name", "job", "--keep-on-failure", "--", "false")
+	require.Equal(t, 1, res.ExitCode, res.Stderr)
+	require.Contains(t, res.Stderr, "Keeping VM 'job'")
+	require.Equal(t, "running", onlyVM(t, srv).Status)
+}
+
+func TestRun_Upload(t *testing.T) {
+	srv := apitest.NewServer(t, apitest.Options{})
+	env, log := fakeSSH(t, srv)
+	dir := filepath.Join(t.TempDir(), "my project")
+	require.NoError(t, os.Mkdir(dir, 0o755))
+
+	res := runCLIWithEnv(t, env, nil, "run", "--upload", dir, "--", "make", "test")
+	require.Equal(t, 0, res.ExitCode, res.Stderr)
+	out := readLog(t, log)
+	require.Contains(t, out, "scp -P 22 -r -q")
+	require.Contains(t, out, "-- "+dir+" iron@127.0.0.1:")
+	require.Contains(t, out, `iron@127.0.0.1 cd 'my project' && make test`)
+}
+
+func TestRun_DestroysVMOnSIGTERM(t *testing.T) {
+	srv := apitest.NewServer(t, apitest.Options{})
+	env, log := fakeSSH(t, srv)
+
+	cmd := exec.Command(binaryPath, "run", "--", "sleep", "30")
+	cmd.Env = append(os.Environ(), "XDG_CONFIG_HOME=")
+	cmd.Env = append(cmd.Env, append(env, "FAKE_SSH_SLEEP=1")...)
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	require.NoError(t, cmd.Start())
+
+	require.Eventually(t, func() bool {
+		_, err := os.Stat(log)
+		return err == nil
+	}, 10*time.Second, 50*time.Millisecond)
+	require.NoError(t, cmd.Process.Signal(syscall.SIGTERM))
+
+	err := cmd.Wait()
+	require.Error(t, err, stderr.String())
+	require.Contains(t, stderr.String(), "interrupted")
+	require.Equal(t, "destroyed", onlyVM(t, srv).Status)
+}
+
+func TestRun_DestroysVMWhenSignalledDuringCreate(t *testing.T) {
+	srv := apitest.NewServer(t, apitest.Options{})
+	srv.InjectFault("POST", "/vms", apitest.Fault{Latency: time.Second, Times: 1})
+	env, log := fakeSSH(t, srv)
+
+	cmd := exec.Command(binaryPath, "run", "--", "true")
+	cmd.Env = append(os.Environ(), "XDG_CONFIG_HOME=")
+	cmd.Env = append(cmd.Env, env...)
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	require.NoError(t, cmd.Start())
+
+	require.Eventually(t, func() bool {
+		return srv.CountRequests("POST", "/vms") == 1
+	}, 10*time.Second, 10*time.Millisecond)
+	require.NoError(t, cmd.Process.Signal(syscall.SIGTERM))
+
+	err := cmd.Wait()
+	require.Error(t, err, stderr.String())
+	require.Contains(t, stderr.String(), "interrupted")
+	require.Equal(t, "destroyed", onlyVM(t, srv).Status)
+	_, err = os.Stat(log)
+	require.True(t, os.IsNotExist(err), "the command should not run once interrupted")
+}
@@ -66,8 +66,9 @@ automatically from the API.`,
 
 		// Create API client
 		client := newClient()
+		ctx := cmd.Context()
 
-		id, err := resolveVM(client, idOrName)
+		id, err := resolveVM(ctx, client, idOrName)
 		if err != nil {
 			return err
 		}
@@ -75,7 +76,7 @@ automatically from the API.`,
 		// Resolve SSH connection info (scp connects over SSH)
 		fmt.Printf("Getting SSH connection info for VM '%s'...\n", id)
 
-		resp, err := client.SSH(id)
+		resp, err := client.SSHContext(ctx, id)
 		if err != nil {
 			return fmt.Errorf("getting SSH info: %w", err)
 		}
@@ -100,11 +101,7 @@ automatically from the API.`,
 		}
 
 		if !strictHostKeys {
-			scpArgs = append(scpArgs,
-				"-o", "StrictHostKeyChecking=no",
-				"-o", "UserKnownHostsFile=/dev/null",
-				"-o", "LogLevel=ERROR",
-			)
+			scpArgs = append(scpArgs, insecureHostKeyArgs...)
 		}
 
 		scpArgs = append(scpArgs, src, dst)
@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"bufio"
+	"context"
 	"fmt"
 	"os"
 	"strings"
@@ -32,35 +33,60 @@ var secretsListCmd = &cobra.Command{
 	Long: `List all secrets on the account.
 
 Displays a table with name, env var, hosts, proxy value, and creation date.
-Never displays the secret value.
+Never displays the secret value. All pages are fetched unless --limit is given.
 
 Examples:
-  irons secrets list`,
+  irons secrets list
+  irons secrets list --limit 10`,
 	Args: cobra.NoArgs,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		client := newClient()
+		limit, _ := cmd.Flags().GetInt("limit")
 
-		resp, err := client.SecretsList()
+		output, err := newPrinter()
 		if err != nil {
-			return fmt.Errorf("listing secrets: %w", err)
+			return err
 		}
 
-		if len(resp.Data) == 0 {
-			fmt.Println("No secrets found.")
-			return nil
-		}
+		client := newClient()
+		ctx := cmd.Context()
 
-		table := tablewriter.NewTable(os.Stdout)
-		table.Header([]string{"Name", "Env Var", "Hosts", "Proxy Value", "Created"})
-		for _, s := range resp.Data {
-			table.Append([]string{s.Name, s.EnvVar, formatHosts(s.Hosts), s.ProxyValue, s.CreatedAt})
+		secrets, err := api.Collect(client.AllSecrets(ctx), limit)
+		if err != nil {
+			return fmt.Errorf("listing secrets: %w", err)
 		}
-		table.Render()
 
-		return nil
+		return output.print(secrets, func() { printSecretTable(secrets, output.wide()) })
 	},
 }
 
+// printSecretTable prints secrets as a table. wide adds their IDs,
+// comments and when they were last updated.
+func printSecretTable(secrets []api.Secret, wide bool) {
+	if len(secrets) == 0 {
+		fmt.Println("No secrets found.")
+		return
+	}
+
+	header := []string{"Name", "Env Var", "Hosts", "Proxy Value", "Created"}
+	if wide {
+		header = append([]string{"ID"}, append(header, "Updated", "Comment")...)
+	}
+	table := tablewriter.NewTable(os.Stdout)
+	table.Header(header)
+	for _, s := range secrets {
+		row := []string{s.Name, s.EnvVar, formatHosts(s.Hosts), s.ProxyValue, s.CreatedAt}
+		if wide {
+			comment := ""
+			if s.Comment != nil {
+				comment = *s.Comment
+			}
+			row = append([]string{s.ID}, append(row, s.UpdatedAt, comment)...)
+		}
+		table.Append(row)
+	}
+	table.Render()
+}
+
 // secretsAddCmd adds a new secret
 var secretsAddCmd = &cobra.Command{
 	Use:   "add",
@@ -81,6 +107,11 @@ Examples:
 		secret, _ := cmd.Flags().GetString("secret")
 		comment, _ := cmd.Flags().GetString("comment")
 
+		output, err := newPrinter()
+		if err != nil {
+			return err
+		}
+
 		if name == "" {
 			return fmt.Errorf("--name is required")
 		}
@@ -89,7 +120,6 @@ Examples:
 		}
 
 		if secret == "" {
-			var err error
 			secret, err = readSecret()
 			if err != nil {
 				return err
@@ -97,6 +127,7 @@ Examples:
 		}
 
 		client := newClient()
+		ctx := cmd.Context()
 
 		req := api.CreateSecretRequest{
 			Name:    name,
@@ -109,13 +140,12 @@ Examples:
 			req.Hosts = hosts
 		}
 
-		s, err := client.SecretsCreate(req)
+		s, err := client.SecretsCreateContext(ctx, req)
 		if err != nil {
 			return fmt.Errorf("creating secret: %w", err)
 		}
 
-		printSecretDetail(s)
-		return nil
+		return output.print(s, func() { printSecretDetail(s) })
 	},
 }
 
@@ -125,8 +155,8 @@ var secretsRemoveCmd = &cobra.Command{
 	Short: "Remove a secret",
 	Long: `Remove a secret by name or ID.
 
-If the value starts with "sec_", it is treated as an ID. Otherwise it is
-treated as a name and resolved via the API.
+The secret must be given by its full name or ID; a prefix that matches
+a single secret is not enough to remove it.
 
 Examples:
   irons secrets remove github-main
@@ -135,18 +165,24 @@ Examples:
 	RunE: func(cmd *cobra.Command, args []string) error {
 		idOrName := args[0]
 
+		output, err := newPrinter()
+		if err != nil {
+			return err
+		}
+
 		client := newClient()
+		ctx := cmd.Context()
 
-		id, err := resolveSecret(client, idOrName)
+		id, err := resolveSecretExact(ctx, client, idOrName)
 		if err != nil {
 			return err
 		}
 
-		if err := client.SecretsDelete(id); err != nil {
+		if err := client.SecretsDeleteContext(ctx, id); err != nil {
 			return fmt.Errorf("removing secret: %w", err)
 		}
 
-		fmt.Printf("Secret %q removed.\n", idOrName)
+		fmt.Fprintf(output.messages(), "Secret %q removed.\n", idOrName)
 		return nil
 	},
 }
@@ -178,9 +214,13 @@ Examples:
 		hostsSet := cmd.Flags().Changed("host")
 		commentSet := cmd.Flags().Changed("comment")
 
+		output, err := newPrinter()
+		if err != nil {
+			return err
+		}
+
 		// If no flags at all, prompt for secret value
 		if !secretSet && !envVarSet && !hostsSet && !commentSet {
-			var err error
 			secret, err = readSecret()
 			if err != nil {
 				return err
@@ -189,8 +229,9 @@ Examples:
 		}
 
 		client := newClient()
+		ctx := cmd.Context()
 
-		id, err := resolveSecret(client, idOrName)
+		id, err := resolveSecret(ctx, client, idOrName)
 		if err != nil {
 			return err
 		}
@@ -209,13 +250,12 @@ Examples:
 			req.Comment = comment
 		}
 
-		s, err := client.SecretsUpdate(id, req)
+		s, err := client.SecretsUpdateContext(ctx, id, req)
 		if err != nil {
 			return fmt.Errorf("updating secret: %w", err)
 		}
 
-		printSecretDetail(s)
-		return nil
+		return output.print(s, func() { printSecretDetail(s) })
 	},
 }
 
@@ -234,20 +274,25 @@ Examples:
 	RunE: func(cmd *cobra.Command, args []string) error {
 		idOrName := args[0]
 
+		output, err := newPrinter()
+		if err != nil {
+			return err
+		}
+
 		client := newClient()
+		ctx := cmd.Context()
 
-		id, err := resolveSecret(client, idOrName)
+		id, err := resolveSecret(ctx, client, idOrName)
 		if err != nil {
 			return err
 		}
 
-		s, err := client.SecretsGet(id)
+		s, err := client.SecretsGetContext(ctx, id)
 		if err != nil {
 			return fmt.Errorf("getting secret: %w", err)
 		}
 
-		printSecretDetail(s)
-		return nil
+		return output.print(s, func() { printSecretDetail(s) })
 	},
 }
 
@@ -274,14 +319,31 @@ func formatHosts(hosts []string) string {
 }
 
 // resolveSecret resolves a secret name or ID to a secret ID.
-func resolveSecret(client *api.Client, idOrName string) (string, error) {
-	id, err := client.ResolveSecret(idOrName)
+func resolveSecret(ctx context.Context, client api.SecretsService, idOrName string) (string, error) {
+	id, err := client.ResolveSecretContext(ctx, idOrName)
 	if err != nil {
 		return "", fmt.Errorf("resolving secret %q: %w", idOrName, err)
 	}
 	return id, nil
 }
 
+// resolveSecretExact is like resolveSecret, but fails unless idOrName is
+// the whole name or ID of the secret it resolves to.
+func resolveSecretExact(ctx context.Context, client api.SecretsService, idOrName string) (string, error) {
+	id, err := resolveSecret(ctx, client, idOrName)
+	if err != nil {
+		return "", err
+	}
+	s, err := client.SecretsGetContext(ctx, id)
+	if err != nil {
+		return "", fmt.Errorf("resolving secret %q: %w", idOrName, err)
+	}
+	if s.ID != idOrName && s.Name != idOrName {
+		return "", fmt.Errorf("%q only partly matches secret %s (%s); give its full name or ID", idOrName, s.ID, s.Name)
+	}
+	return id, nil
+}
+
 // readSecret reads a secret value from stdin. If stdin is a terminal, it
 // prompts interactively with echo disabled and requires confirmation. If
 // stdin is piped, it reads a single line.
@@ -334,6 +396,9 @@ func init() {
 	secretsCmd.AddCommand(secretsUpdateCmd)
 	secretsCmd.AddCommand(secretsShowCmd)
 
+	// Flags for list command
+	secretsListCmd.Flags().Int("limit", 0, "Maximum number of secrets to show (0 for all)")
+
 	// Flags for add command
 	secretsAddCmd.Flags().String("name", "", "Human-readable name for the secret")
 	secretsAddCmd.Flags().String("env-var", "", "Environment variable name for VMs")
@@ -246,6 +246,7 @@ func TestSecretsShow_DisplaysHosts(t *testing.T) {
 func TestSecretsUpdate_WithSecret(t *testing.T) {
 	s := sampleSecret()
 	ms := newMockServer(t, []route{
+		secretsGetRoute("sec_m4xk9wp2", s),
 		secretsPatchRoute("sec_m4xk9wp2", s),
 	})
 
@@ -298,6 +299,7 @@ func TestSecretsUpdate_EnvVar(t *testing.T) {
 func TestSecretsUpdate_PipedStdin(t *testing.T) {
 	s := sampleSecret()
 	ms := newMockServer(t, []route{
+		secretsGetRoute("sec_m4xk9wp2", s),
 		secretsPatchRoute("sec_m4xk9wp2", s),
 	})
 
@@ -317,6 +319,7 @@ func TestSecretsRemove_ByName(t *testing.T) {
 	s := sampleSecret()
 	ms := newMockServer(t, []route{
 		secretsListRoute(s),
+		secretsGetRoute("sec_m4xk9wp2", s),
 		secretsDeleteRoute("sec_m4xk9wp2"),
 	})
 
@@ -328,6 +331,7 @@ func TestSecretsRemove_ByName(t *testing.T) {
 
 func TestSecretsRemove_ByID(t *testing.T) {
 	ms := newMockServer(t, []route{
+		secretsGetRoute("sec_m4xk9wp2", sampleSecret()),
 		secretsDeleteRoute("sec_m4xk9wp2"),
 	})
 
@@ -390,13 +394,25 @@ func TestSecretsAPI_ResolveByName(t *testing.T) {
 }
 
 func TestSecretsAPI_ResolveByID(t *testing.T) {
-	ms := newMockServer(t, nil)
+	ms := newMockServer(t, []route{secretsGetRoute("sec_m4xk9wp2", sampleSecret())})
 	client := api.NewClient(ms.Server.URL, "test-key")
 
 	id, err := client.ResolveSecret("sec_m4xk9wp2")
 	require.NoError(t, err)
 	require.Equal(t, "sec_m4xk9wp2", id)
-	require.Empty(t, ms.Requests(), "should not make any API calls for ID resolution")
+	require.Len(t, ms.Requests(), 1, "an exact ID should need only a lookup")
+}
+
+func TestSecretsAPI_ResolveByIDPrefix(t *testing.T) {
+	ms := newMockServer(t, []route{secretsListRoute(sampleSecret())})
+	client := api.NewClient(ms.Server.URL, "test-key")
+
+	id, err := client.ResolveSecret("sec_m4x")
+	require.NoError(t, err)
+	require.Equal(t, "sec_m4xk9wp2", id)
+
+	_, err = client.ResolveSecret("sec_typo")
+	require.True(t, api.IsNotFound(err))
 }
 
 func TestSecretsAPI_Delete(t *testing.T) {
@@ -466,3 +482,50 @@ func TestSecretsList_TableColumns(t *testing.T) {
 	require.Contains(t, res.Stdout, "npm-token")
 	require.Contains(t, res.Stdout, "registry.npmjs.org")
 }
+
+// --- debug output ---
+
+func TestSecretsAdd_DebugRedactsSecret(t *testing.T) {
+	s := sampleSecret()
+	ms := newMockServer(t, []route{secretsPostRoute(s)})
+
+	res := runCLI(t, ms, "--debug-api", "secrets", "add",
+		"--name", "github-main",
+		"--env-var", "GITHUB_TOKEN",
+		"--secret", "ghp_abc123",
+	)
+	require.Equal(t, 0, res.ExitCode, res.Stderr)
+	require.Contains(t, res.Stderr, ">>> POST /secrets")
+	require.Contains(t, res.Stderr, "Authorization: Bearer [REDACTED]")
+	require.NotContains(t, res.Stderr, "ghp_abc123")
+	require.NotContains(t, res.Stderr, "test-key")
+	require.NotContains(t, res.Stderr, "IRONSH_PROXY_github-main")
+	require.Contains(t, res.Stderr, "GITHUB_TOKEN")
+}
+
+func TestSecretsAdd_DebugUnsafeShowsSecret(t *testing.T) {
+	s := sampleSecret()
+	ms := newMockServer(t, []route{secretsPostRoute(s)})
+
+	res := runCLI(t, ms, "--debug-api-unsafe", "secrets", "add",
+		"--name", "github-main",
+		"--env-var", "GITHUB_TOKEN",
+		"--secret", "ghp_abc123",
+	)
+	require.Equal(t, 0, res.ExitCode, res.Stderr)
+	require.Contains(t, res.Stderr, "ghp_abc123")
+	require.Contains(t, res.Stderr, "Bearer test-key")
+}
+
+func TestSecretsRemove_RequiresExactNameOrID(t *testing.T) {
+	ms := newMockServer(t, []route{
+		secretsListRoute(sampleSecret()),
+		secretsGetRoute("sec_m4xk9wp2", sampleSecret()),
+		secretsDeleteRoute("sec_m4xk9wp2"),
+	})
+
+	res := runCLI(t, ms, "secrets", "remove", "github")
+	require.Equal(t, 1, res.ExitCode)
+	require.Contains(t, res.Stderr, `"github" only partly matches secret sec_m4xk9wp2 (github-main)`)
+	require.False(t, ms.HasRequest("DELETE", "/secrets/sec_m4xk9wp2"))
+}
@@ -0,0 +1,256 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/ironsh/irons/api"
+	"github.com/ironsh/irons/config"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// outputFormats lists the values accepted for the output setting.
+var outputFormats = []string{"table", "wide", "json", "yaml"}
+
+// setting is a value that can be persisted with `irons config set`. Every
+// setting is bound through viper, so its effective value comes from, in
+// order of precedence, its flag, its environment variable, the config file,
+// or its default.
+type setting struct {
+	// Key is the name of the setting in the config file and in `irons
+	// config`, e.g. "api_url".
+	Key string
+	// Name is the viper key, which is also the flag name unless Flag says
+	// otherwise, e.g. "api-url".
+	Name string
+	// Env is the environment variable that sets it.
+	Env string
+	// Flag returns the flag that sets it, if there is one.
+	Flag func() *pflag.Flag
+	// Help describes the setting in `irons config list`.
+	Help string
+	// Secret values are masked when listed.
+	Secret bool
+	// Validate checks a value before it is saved and may normalize it, e.g.
+	// by making a path absolute.
+	Validate func(string) (string, error)
+}
+
+// settings lists every setting, in the order `irons config list` shows them.
+var settings = []setting{
+	{
+		Key: "api_url", Name: "api-url", Env: "IRONS_API_URL",
+		Flag: persistentFlag("api-url"), Help: "API endpoint URL",
+		Validate: validateURL,
+	},
+	{
+		Key: "api_key", Name: "api-key", Env: "IRONS_API_KEY",
+		Flag: persistentFlag("api-key"), Help: "API key for authentication",
+		Secret: true,
+	},
+	{
+		Key: "credential_process", Name: "credential-process", Env: "IRONS_CREDENTIAL_PROCESS",
+		Help: "Command that supplies the API key",
+	},
+	{
+		Key: "ssh_key", Name: "ssh-key", Env: "IRONS_SSH_KEY",
+		Flag: localFlag(createCmd, "key"), Help: "SSH public key used by create",
+		Validate: validateFile,
+	},
+	{
+		Key: "egress_mode", Name: "egress-mode", Env: "IRONS_EGRESS_MODE",
+		Flag: localFlag(createCmd, "egress-mode"), Help: "Egress mode for VMs made by create",
+		Validate: validateOneOf("enforce", "warn"),
+	},
+	{
+		Key: "name_prefix", Name: "name-prefix", Env: "IRONS_NAME_PREFIX",
+		Help: "Prefix for the names of VMs made by create",
+	},
+	{
+		Key: "output", Name: "output", Env: "IRONS_OUTPUT",
+		Flag: persistentFlag("output"), Help: "Output format (" + strings.Join(outputFormats, ", ") + ")",
+		Validate: validateOneOf(outputFormats...),
+	},
+	{
+		Key: "retries", Name: "retries", Env: "IRONS_RETRIES",
+		Flag: persistentFlag("retries"), Help: "Number of times to retry failed API requests",
+		Validate: validateRetries,
+	},
+	{
+		Key: "ca_cert", Name: "ca-cert", Env: "IRONS_CA_CERT",
+		Flag: persistentFlag("ca-cert"), Help: "PEM file of additional certificate authorities",
+		Validate: validateFile,
+	},
+	{
+		Key: "client_cert", Name: "client-cert", Env: "IRONS_CLIENT_CERT",
+		Flag: persistentFlag("client-cert"), Help: "PEM client certificate for mutual TLS",
+		Validate: validateFile,
+	},
+	{
+		Key: "client_key", Name: "client-key", Env: "IRONS_CLIENT_KEY",
+		Flag: persistentFlag("client-key"), Help: "PEM private key for client_cert",
+		Validate: validateFile,
+	},
+	{
+		Key: "proxy", Name: "proxy", Env: "IRONS_PROXY",
+		Flag: persistentFlag("proxy"), Help: "Proxy URL for API requests",
+		Validate: validateProxy,
+	},
+}
+
+// lookupSetting returns the setting stored under key.
+func lookupSetting(key string) (setting, error) {
+	i := slices.IndexFunc(settings, func(s setting) bool { return s.Key == key })
+	if i < 0 {
+		keys := make([]string, len(settings))
+		for i, s := range settings {
+			keys[i] = s.Key
+		}
+		return setting{}, fmt.Errorf("unknown config key %q (valid keys: %s)", key, strings.Join(keys, ", "))
+	}
+	return settings[i], nil
+}
+
+// persistentFlag returns a Flag func for a global flag.
+func persistentFlag(name string) func() *pflag.Flag {
+	return func() *pflag.Flag { return rootCmd.PersistentFlags().Lookup(name) }
+}
+
+// localFlag returns a Flag func for a flag of cmd. The lookup is deferred
+// because settings is initialized before the commands' flags are defined.
+func localFlag(cmd *cobra.Command, name string) func() *pflag.Flag {
+	return func() *pflag.Flag { return cmd.Flags().Lookup(name) }
+}
+
+// bind binds the setting's flag and environment variable to its viper key.
+func (s setting) bind() {
+	viper.BindEnv(s.Name, s.Env)
+	if s.Flag != nil {
+		if f := s.Flag(); f != nil {
+			viper.BindPFlag(s.Name, f)
+		}
+	}
+}
+
+// explicit reports whether the setting is given by its flag or environment
+// variable rather than a config file or its default.
+func (s setting) explicit() bool {
+	if s.Flag != nil {
+		if f := s.Flag(); f != nil && f.Changed {
+			return true
+		}
+	}
+	v, ok := os.LookupEnv(s.Env)
+	return ok && v != ""
+}
+
+// source returns where the effective value of the setting comes from:
+// "flag", "env", "file" or "default", with the flag, variable or file name.
+// proj may be nil if there is no project file.
+func (s setting) source(cfg *config.Config, proj *config.Project, profile string) string {
+	if s.Flag != nil {
+		if f := s.Flag(); f != nil && f.Changed {
+			return "flag --" + f.Name
+		}
+	}
+	if v, ok := os.LookupEnv(s.Env); ok && v != "" {
+		return "env " + s.Env
+	}
+	if proj != nil {
+		if _, ok := proj.Get(s.Key); ok {
+			return "file " + proj.Path
+		}
+	}
+	if _, ok := cfg.Get(profile, s.Key); ok {
+		path, _ := config.Path()
+		if scope, _ := config.KeyScope(s.Key); scope == config.ProfileScope {
+			return fmt.Sprintf("file %s (profile %s)", path, profile)
+		}
+		return "file " + path
+	}
+	return "default"
+}
+
+// display returns the setting's effective value for printing, masking
+// secrets.
+func (s setting) display() string {
+	v := viper.GetString(s.Name)
+	if s.Secret {
+		return mask(v)
+	}
+	return v
+}
+
+// mask hides a secret value, showing only its last four characters if it
+// is long enough for that to give nothing away.
+func mask(v string) string {
+	if len(v) <= 8 {
+		return strings.Repeat("*", len(v))
+	}
+	return strings.Repeat("*", 8) + v[len(v)-4:]
+}
+
+func validateURL(v string) (string, error) {
+	u, err := url.Parse(v)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("%q is not an http or https URL", v)
+	}
+	return strings.TrimSuffix(v, "/"), nil
+}
+
+func validateFile(v string) (string, error) {
+	path, err := filepath.Abs(v)
+	if err != nil {
+		return "", err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	if info.IsDir() {
+		return "", fmt.Errorf("%s is a directory", path)
+	}
+	return path, nil
+}
+
+func validateOneOf(values ...string) func(string) (string, error) {
+	return func(v string) (string, error) {
+		if !slices.Contains(values, v) {
+			return "", fmt.Errorf("%q must be one of %s", v, strings.Join(values, ", "))
+		}
+		return v, nil
+	}
+}
+
+func validateRetries(v string) (string, error) {
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		return "", errors.New("retries must be a non-negative integer")
+	}
+	return strconv.Itoa(n), nil
+}
+
+func validateProxy(v string) (string, error) {
+	if _, err := api.NewTransport(api.TransportOptions{Proxy: v}); err != nil {
+		return "", err
+	}
+	return v, nil
+}
+
+// loadProject returns the project config from the nearest .irons.yml above
+// the working directory, or nil if there is none.
+func loadProject() (*config.Project, error) {
+	wd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	return config.LoadProject(wd)
+}
@@ -1,13 +1,24 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"os/exec"
 
+	"github.com/ironsh/irons/api"
 	"github.com/spf13/cobra"
 )
 
+// insecureHostKeyArgs are passed to ssh and scp unless --strict-hostkeys is
+// given. VMs get fresh host keys every time they are created, so checking
+// them would only produce warnings about changed keys.
+var insecureHostKeyArgs = []string{
+	"-o", "StrictHostKeyChecking=no",
+	"-o", "UserKnownHostsFile=/dev/null",
+	"-o", "LogLevel=ERROR",
+}
+
 // sshCmd represents the ssh command
 var sshCmd = &cobra.Command{
 	Use:   "ssh ID [command...]",
@@ -19,7 +30,11 @@ with the specified configuration and credentials.
 
 Optionally, pass a command to execute on the remote VM:
   irons ssh myvm ls -la
-  irons ssh -t myvm tmux attach`,
+  irons ssh -t myvm tmux attach
+
+With -o json, yaml, jsonpath or template, the connection details are
+printed instead of connecting:
+  irons ssh myvm -o jsonpath='{.username}@{.host}:{.port}'`,
 	Args: cobra.MinimumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		idOrName := args[0]
@@ -27,33 +42,40 @@ Optionally, pass a command to execute on the remote VM:
 		strictHostKeys, _ := cmd.Flags().GetBool("strict-hostkeys")
 		forceTTY, _ := cmd.Flags().GetBool("tty")
 
+		output, err := newPrinter()
+		if err != nil {
+			return err
+		}
+
 		// Create API client
 		client := newClient()
+		ctx := cmd.Context()
 
-		id, err := resolveVM(client, idOrName)
+		id, err := resolveVM(ctx, client, idOrName)
 		if err != nil {
 			return err
 		}
 
 		// Get SSH connection info
-		fmt.Printf("Getting SSH connection info for VM '%s'...\n", id)
+		fmt.Fprintf(output.messages(), "Getting SSH connection info for VM '%s'...\n", id)
 
-		resp, err := client.SSH(id)
+		resp, err := client.SSHContext(ctx, id)
 		if err != nil {
 			return fmt.Errorf("getting SSH info: %w", err)
 		}
 
+		// Structured output describes the connection instead of making it.
+		if output.structured() {
+			return output.print(resp, nil)
+		}
+
 		// Build SSH command
 		sshArgs := []string{
 			"-p", fmt.Sprintf("%d", resp.Port),
 		}
 
 		if !strictHostKeys {
-			sshArgs = append(sshArgs,
-				"-o", "StrictHostKeyChecking=no",
-				"-o", "UserKnownHostsFile=/dev/null",
-				"-o", "LogLevel=ERROR",
-			)
+			sshArgs = append(sshArgs, insecureHostKeyArgs...)
 		}
 
 		remoteCmd := args[1:]
@@ -101,3 +123,21 @@ func init() {
 	sshCmd.Flags().Bool("strict-hostkeys", false, "Enable strict host key checking (disabled by default)")
 	sshCmd.Flags().BoolP("tty", "t", false, "Force pseudo-TTY allocation (useful for interactive commands like tmux)")
 }
+
+// sshCommand returns an ssh command that runs the shell command line remote
+// on the VM described by resp, without host key checking. The command is
+// killed if ctx is cancelled.
+func sshCommand(ctx context.Context, resp *api.SSHResponse, remote string) *exec.Cmd {
+	args := append([]string{"-p", fmt.Sprint(resp.Port)}, insecureHostKeyArgs...)
+	args = append(args, "--", fmt.Sprintf("%s@%s", resp.Username, resp.Host), remote)
+	return exec.CommandContext(ctx, "ssh", args...)
+}
+
+// scpCommand returns an scp command that copies local to remote on the VM
+// described by resp, without host key checking. An empty remote is the
+// home directory. Directories are copied recursively.
+func scpCommand(ctx context.Context, resp *api.SSHResponse, local, remote string) *exec.Cmd {
+	args := append([]string{"-P", fmt.Sprint(resp.Port), "-r", "-q"}, insecureHostKeyArgs...)
+	args = append(args, "--", local, fmt.Sprintf("%s@%s:%s", resp.Username, resp.Host, remote))
+	return exec.CommandContext(ctx, "scp", args...)
+}
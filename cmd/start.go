@@ -1,14 +1,18 @@
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
 
+	"github.com/ironsh/irons/api"
 	"github.com/spf13/cobra"
 )
 
 // startCmd represents the start command
 var startCmd = &cobra.Command{
-	Use:   "start ID",
+	Use:   "start [ID...]",
 	Short: "Start a VM",
 	Long: `Start a VM that has been previously stopped.
 
@@ -19,43 +23,81 @@ By default the command waits until the VM is running before
 returning. Pass --async to return immediately after the start
 request is accepted.
 
+Several VMs can be given at once. --all acts on every VM that is
+stopped, and -l/--selector on those of them whose labels match. VMs are
+acted on concurrently, --parallel at a time, and a table of results is
+shown; the command fails if any VM does.
+
 Examples:
   irons start vm_abc123
-  irons start --async vm_abc123`,
-	Args: cobra.ExactArgs(1),
+  irons start --async vm_abc123
+  irons start -l ticket=IR-42
+  irons start --all --parallel 8`,
+	Args: cobra.ArbitraryArgs,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		idOrName := args[0]
 		async, _ := cmd.Flags().GetBool("async")
+		parallel, _ := cmd.Flags().GetInt("parallel")
+
+		wait, err := waitFlags(cmd)
+		if err != nil {
+			return err
+		}
+		output, err := newPrinter()
+		if err != nil {
+			return err
+		}
+		msgs := output.messages()
 
 		// Create API client
 		client := newClient()
+		ctx := cmd.Context()
 
-		id, err := resolveVM(client, idOrName)
+		targets, bulk, err := targetVMs(cmd, client, args, statusIn("stopped"), false, msgs)
 		if err != nil {
 			return err
 		}
-
-		fmt.Printf("Starting VM '%s'...\n", id)
-
-		if _, err := client.Start(id); err != nil {
-			return fmt.Errorf("starting VM: %w", err)
+		if !bulk {
+			if err := startVM(ctx, client, targets[0].ID, async, msgs, wait); err != nil {
+				return err
+			}
+			return printTargets(ctx, client, output, targets, false)
 		}
-
-		if async {
-			fmt.Printf("✓ Start request accepted for VM '%s'.\n", id)
-			return nil
+		// The results table reports on each VM instead.
+		wait.Progress = nil
+		err = runBulk(ctx, targets, parallel, "start", "started", msgs, func(ctx context.Context, id string, out io.Writer) error {
+			return startVM(ctx, client, id, async, out, wait)
+		})
+		if perr := printTargets(ctx, client, output, targets, true); perr != nil {
+			return errors.Join(err, perr)
 		}
+		return err
+	},
+}
 
-		if err := waitForVMCond(cmd.Context(), client, id, statusAndDetailEq("running", "ready")); err != nil {
-			return err
-		}
+// startVM starts a VM and, unless async, waits for it to be ready.
+func startVM(ctx context.Context, client api.VMService, id string, async bool, out io.Writer, wait waitOptions) error {
+	fmt.Fprintf(out, "Starting VM '%s'...\n", id)
 
-		fmt.Printf("✓ VM '%s' started successfully!\n", id)
+	if _, err := client.StartContext(ctx, id); err != nil {
+		return fmt.Errorf("starting VM: %w", err)
+	}
+
+	if async {
+		fmt.Fprintf(out, "✓ Start request accepted for VM '%s'.\n", id)
 		return nil
-	},
+	}
+
+	if err := waitForVM(ctx, client, id, statusAndDetailEq("running", "ready"), wait); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "✓ VM '%s' started successfully!\n", id)
+	return nil
 }
 
 func init() {
 	rootCmd.AddCommand(startCmd)
+	addTargetFlags(startCmd)
 	startCmd.Flags().Bool("async", false, "Return immediately without waiting for the VM to reach the running state")
+	addWaitFlags(startCmd, "the VM to be running")
 }
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/ironsh/irons/api"
+	"github.com/ironsh/irons/labels"
 	"github.com/spf13/cobra"
 )
 
@@ -22,48 +24,60 @@ Examples:
 	RunE: func(cmd *cobra.Command, args []string) error {
 		idOrName := args[0]
 
+		output, err := newPrinter()
+		if err != nil {
+			return err
+		}
+
 		// Create API client
 		client := newClient()
+		ctx := cmd.Context()
 
-		id, err := resolveVM(client, idOrName)
+		id, err := resolveVM(ctx, client, idOrName)
 		if err != nil {
 			return err
 		}
 
 		// Make API call
-		resp, err := client.GetVM(id)
+		resp, err := client.GetVMContext(ctx, id)
 		if err != nil {
 			return fmt.Errorf("getting VM status: %w", err)
 		}
 
-		// Show status information
-		fmt.Printf("\n✓ VM Status:\n")
-		fmt.Printf("  ID: %s\n", resp.ID)
-		fmt.Printf("  Name: %s\n", resp.Name)
-		fmt.Printf("  Status: %s\n", resp.Status)
-		if resp.StatusDetail != "" {
-			fmt.Printf("  Detail: %s\n", resp.StatusDetail)
-		}
-		fmt.Printf("  Created: %s\n", resp.CreatedAt)
-		fmt.Printf("  Updated: %s\n", resp.UpdatedAt)
+		return output.print(resp, func() { printVMStatus(resp) })
+	},
+}
 
-		// Add visual status indicator
-		status := strings.ToLower(resp.Status)
-		switch {
-		case status == "running":
-			fmt.Printf("\n🟢 VM is healthy and ready\n")
-		case status == "creating" || status == "starting":
-			fmt.Printf("\n🟡 VM is starting up\n")
-		case status == "stopped" || status == "stopping":
-			fmt.Printf("\n🟠 VM is stopped\n")
-		case status == "failed":
-			fmt.Printf("\n🔴 VM has errors\n")
-		default:
-			fmt.Printf("\n⚪ VM status: %s\n", resp.Status)
-		}
+// printVMStatus describes a VM for people, with an indicator of its health.
+func printVMStatus(resp *api.VM) {
+	// Show status information
+	fmt.Printf("\n✓ VM Status:\n")
+	fmt.Printf("  ID: %s\n", resp.ID)
+	fmt.Printf("  Name: %s\n", resp.Name)
+	fmt.Printf("  Status: %s\n", resp.Status)
+	if resp.StatusDetail != "" {
+		fmt.Printf("  Detail: %s\n", resp.StatusDetail)
+	}
+	if len(resp.Labels) > 0 {
+		fmt.Printf("  Labels: %s\n", labels.Format(resp.Labels))
+	}
+	fmt.Printf("  Created: %s\n", resp.CreatedAt)
+	fmt.Printf("  Updated: %s\n", resp.UpdatedAt)
 
-		return nil
-	},
+	// Add visual status indicator
+	status := strings.ToLower(resp.Status)
+	switch {
+	case status == "running":
+		fmt.Printf("\n🟢 VM is healthy and ready\n")
+	case status == "creating" || status == "starting":
+		fmt.Printf("\n🟡 VM is starting up\n")
+	case status == "stopped" || status == "stopping":
+		fmt.Printf("\n🟠 VM is stopped\n")
+	case status == "failed":
+		fmt.Printf("\n🔴 VM has errors\n")
+	default:
+		fmt.Printf("\n⚪ VM status: %s\n", resp.Status)
+	}
 }
 
 func init() {
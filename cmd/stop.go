@@ -1,14 +1,18 @@
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
 
+	"github.com/ironsh/irons/api"
 	"github.com/spf13/cobra"
 )
 
 // stopCmd represents the stop command
 var stopCmd = &cobra.Command{
-	Use:   "stop ID",
+	Use:   "stop [ID...]",
 	Short: "Stop a VM",
 	Long: `Stop a running VM.
 
@@ -19,43 +23,81 @@ By default the command waits until the VM is stopped before
 returning. Pass --async to return immediately after the stop
 request is accepted.
 
+Several VMs can be given at once. --all acts on every VM that is
+running, and -l/--selector on those of them whose labels match. VMs are
+acted on concurrently, --parallel at a time, and a table of results is
+shown; the command fails if any VM does.
+
 Examples:
   irons stop vm_abc123
-  irons stop --async vm_abc123`,
-	Args: cobra.ExactArgs(1),
+  irons stop --async vm_abc123
+  irons stop -l 'agent in (a,b)'
+  irons stop agent-1 agent-2 agent-3`,
+	Args: cobra.ArbitraryArgs,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		idOrName := args[0]
 		async, _ := cmd.Flags().GetBool("async")
+		parallel, _ := cmd.Flags().GetInt("parallel")
+
+		wait, err := waitFlags(cmd)
+		if err != nil {
+			return err
+		}
+		output, err := newPrinter()
+		if err != nil {
+			return err
+		}
+		msgs := output.messages()
 
 		// Create API client
 		client := newClient()
+		ctx := cmd.Context()
 
-		id, err := resolveVM(client, idOrName)
+		targets, bulk, err := targetVMs(cmd, client, args, statusIn("running"), false, msgs)
 		if err != nil {
 			return err
 		}
-
-		fmt.Printf("Stopping VM '%s'...\n", id)
-
-		if _, err := client.Stop(id); err != nil {
-			return fmt.Errorf("stopping VM: %w", err)
+		if !bulk {
+			if err := stopVM(ctx, client, targets[0].ID, async, msgs, wait); err != nil {
+				return err
+			}
+			return printTargets(ctx, client, output, targets, false)
 		}
-
-		if async {
-			fmt.Printf("✓ Stop request accepted for VM '%s'.\n", id)
-			return nil
+		// The results table reports on each VM instead.
+		wait.Progress = nil
+		err = runBulk(ctx, targets, parallel, "stop", "stopped", msgs, func(ctx context.Context, id string, out io.Writer) error {
+			return stopVM(ctx, client, id, async, out, wait)
+		})
+		if perr := printTargets(ctx, client, output, targets, true); perr != nil {
+			return errors.Join(err, perr)
 		}
+		return err
+	},
+}
 
-		if err := waitForVMCond(cmd.Context(), client, id, statusIn("stopped")); err != nil {
-			return err
-		}
+// stopVM stops a VM and, unless async, waits for it to stop.
+func stopVM(ctx context.Context, client api.VMService, id string, async bool, out io.Writer, wait waitOptions) error {
+	fmt.Fprintf(out, "Stopping VM '%s'...\n", id)
 
-		fmt.Printf("✓ VM '%s' stopped successfully!\n", id)
+	if _, err := client.StopContext(ctx, id); err != nil {
+		return fmt.Errorf("stopping VM: %w", err)
+	}
+
+	if async {
+		fmt.Fprintf(out, "✓ Stop request accepted for VM '%s'.\n", id)
 		return nil
-	},
+	}
+
+	if err := waitForVM(ctx, client, id, statusIn("stopped"), wait); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "✓ VM '%s' stopped successfully!\n", id)
+	return nil
 }
 
 func init() {
 	rootCmd.AddCommand(stopCmd)
+	addTargetFlags(stopCmd)
 	stopCmd.Flags().Bool("async", false, "Return immediately without waiting for the VM to reach the stopped state")
+	addWaitFlags(stopCmd, "the VM to stop")
 }
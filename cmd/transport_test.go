@@ -0,0 +1,164 @@
+package cmd
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ironsh/irons/api"
+	"github.com/stretchr/testify/require"
+)
+
+// vmsHandler serves an empty VM list for any request.
+func vmsHandler(w http.ResponseWriter, r *http.Request) {
+	jsonResponse(w, http.StatusOK, api.ListVMsResponse{Data: []api.VM{}})
+}
+
+// writePEM writes a single PEM block to a file in dir and returns its path.
+func writePEM(t *testing.T, dir, name, blockType string, der []byte) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	data := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+	return path
+}
+
+func TestTransport_CACert(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(vmsHandler))
+	t.Cleanup(srv.Close)
+
+	result := runCLIAt(t, srv.URL, nil, "list")
+	require.NotEqual(t, 0, result.ExitCode)
+	require.Contains(t, result.Stderr, "certificate")
+
+	caFile := writePEM(t, t.TempDir(), "ca.pem", "CERTIFICATE", srv.Certificate().Raw)
+	result = runCLIAt(t, srv.URL, nil, "list", "--ca-cert", caFile)
+	require.Equal(t, 0, result.ExitCode, result.Stderr)
+}
+
+func TestTransport_InvalidCACert(t *testing.T) {
+	ms := newMockServer(t, nil)
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	require.NoError(t, os.WriteFile(caFile, []byte("not a certificate"), 0o600))
+
+	result := runCLI(t, ms, "list", "--ca-cert", caFile)
+	require.Equal(t, 1, result.ExitCode)
+	require.Contains(t, result.Stderr, "no PEM certificates found")
+	require.Empty(t, ms.Requests())
+}
+
+func TestTransport_ClientCertificate(t *testing.T) {
+	dir := t.TempDir()
+
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	caTmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "irons test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTmpl, caTmpl, &caKey.PublicKey, caKey)
+	require.NoError(t, err)
+	caCert, err := x509.ParseCertificate(caDER)
+	require.NoError(t, err)
+
+	clientKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	clientDER, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "irons client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, caCert, &clientKey.PublicKey, caKey)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalPKCS8PrivateKey(clientKey)
+	require.NoError(t, err)
+	certFile := writePEM(t, dir, "client.pem", "CERTIFICATE", clientDER)
+	keyFile := writePEM(t, dir, "client-key.pem", "PRIVATE KEY", keyDER)
+
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(caCert)
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(vmsHandler))
+	srv.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+	serverCA := writePEM(t, dir, "server-ca.pem", "CERTIFICATE", srv.Certificate().Raw)
+
+	client := api.NewClient(srv.URL, "test-key")
+	client.Retry.MaxRetries = 0
+	require.NoError(t, client.ConfigureTransport(api.TransportOptions{CACertFile: serverCA}))
+	_, err = client.ListVMs()
+	require.Error(t, err, "the server requires a client certificate")
+
+	require.NoError(t, client.ConfigureTransport(api.TransportOptions{
+		CACertFile:     serverCA,
+		ClientCertFile: certFile,
+		ClientKeyFile:  keyFile,
+	}))
+	_, err = client.ListVMs()
+	require.NoError(t, err)
+
+	err = client.ConfigureTransport(api.TransportOptions{ClientCertFile: certFile})
+	require.ErrorContains(t, err, "must be given together")
+}
+
+// proxyServer starts a fake forward proxy that answers requests itself and
+// records the absolute URLs it was asked to fetch.
+func proxyServer(t *testing.T) (*httptest.Server, *[]string) {
+	t.Helper()
+	var urls []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		urls = append(urls, r.URL.String())
+		vmsHandler(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &urls
+}
+
+func TestTransport_ExplicitProxy(t *testing.T) {
+	proxy, urls := proxyServer(t)
+
+	result := runCLIAt(t, "http://api.irons.test/v1", nil, "list", "--proxy", proxy.URL)
+	require.Equal(t, 0, result.ExitCode, result.Stderr)
+	require.Equal(t, []string{"http://api.irons.test/v1/vms"}, *urls)
+}
+
+func TestTransport_EnvironmentProxy(t *testing.T) {
+	proxy, urls := proxyServer(t)
+	t.Setenv("HTTP_PROXY", proxy.URL)
+	t.Setenv("NO_PROXY", "")
+
+	result := runCLIAt(t, "http://api.irons.test/v1", nil, "list", "--debug-tls-skip-verify")
+	require.Equal(t, 0, result.ExitCode, result.Stderr)
+	require.Equal(t, []string{"http://api.irons.test/v1/vms"}, *urls,
+		"HTTP_PROXY must be honoured even with a custom TLS configuration")
+}
+
+func TestTransport_InvalidProxy(t *testing.T) {
+	_, err := api.NewTransport(api.TransportOptions{Proxy: "ftp://proxy.example:21"})
+	require.ErrorContains(t, err, "unsupported scheme")
+
+	tr, err := api.NewTransport(api.TransportOptions{Proxy: "proxy.example:3128"})
+	require.NoError(t, err)
+	req, _ := http.NewRequest("GET", "https://api.iron.sh/v1/vms", nil)
+	proxyURL, err := tr.Proxy(req)
+	require.NoError(t, err)
+	require.Equal(t, "http://proxy.example:3128", proxyURL.String())
+}
@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"fmt"
+	"runtime"
+
+	"github.com/ironsh/irons/api"
+	"github.com/spf13/cobra"
+)
+
+// versionCmd represents the version command
+var versionCmd = &cobra.Command{
+	Use:   "version",
+	Short: "Print the irons version",
+	Long: `Print the version of irons and the platform it was built for.
+
+With --check, also ask the API for the minimum CLI version it supports and
+exit with a non-zero status if this binary is older, so CI runners can detect
+outdated installs.
+
+Examples:
+  irons version
+  irons version --check`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		version := rootCmd.Version
+		fmt.Printf("irons %s (%s/%s, API %s)\n", version, runtime.GOOS, runtime.GOARCH, api.APIVersion)
+
+		check, _ := cmd.Flags().GetBool("check")
+		if !check {
+			return nil
+		}
+
+		client := newClient()
+		resp, err := client.CLIVersionContext(cmd.Context())
+		if err != nil {
+			return fmt.Errorf("checking version: %w", err)
+		}
+
+		// Development builds are stamped "dev" rather than a version.
+		if _, err := api.CompareVersions(version, "0"); err != nil {
+			fmt.Printf("This is a development build; the server requires at least %s.\n", resp.MinimumVersion)
+			return nil
+		}
+
+		cmp, err := api.CompareVersions(version, resp.MinimumVersion)
+		if err != nil {
+			return fmt.Errorf("checking version: server advertised %w", err)
+		}
+		if cmp < 0 {
+			return fmt.Errorf("irons %s is older than the minimum supported version %s; please upgrade", version, resp.MinimumVersion)
+		}
+
+		if resp.LatestVersion != "" {
+			if cmp, err := api.CompareVersions(version, resp.LatestVersion); err == nil && cmp < 0 {
+				fmt.Printf("✓ irons %s is supported; %s is available.\n", version, resp.LatestVersion)
+				return nil
+			}
+		}
+		fmt.Printf("✓ irons %s is up to date.\n", version)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(versionCmd)
+	versionCmd.Flags().Bool("check", false, "Check the version against the minimum supported by the API")
+}
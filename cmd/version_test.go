@@ -0,0 +1,107 @@
+package cmd
+
+import (
+	"net/http"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/ironsh/irons/api"
+	"github.com/ironsh/irons/apitest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPI_SendsUserAgentAndAPIVersion(t *testing.T) {
+	srv := apitest.NewServer(t, apitest.Options{})
+
+	result := runCLIAt(t, srv.URL, nil, "list")
+	require.Equal(t, 0, result.ExitCode, result.Stderr)
+
+	reqs := srv.Requests()
+	require.NotEmpty(t, reqs)
+	require.Equal(t, "irons/"+testVersion+" ("+runtime.GOOS+"/"+runtime.GOARCH+")", reqs[0].Header.Get("User-Agent"))
+	require.Equal(t, api.APIVersion, reqs[0].Header.Get(api.APIVersionHeader))
+}
+
+func TestAPI_DeprecationNoticeOncePerCommand(t *testing.T) {
+	vm := api.VM{ID: "vm_abc123", Name: "agent-1", Status: "running"}
+	deprecated := func(w http.ResponseWriter) {
+		w.Header().Set("Deprecation", "@1767225600")
+		w.Header().Set("Sunset", "Tue, 01 Dec 2026 00:00:00 GMT")
+		w.Header().Set("Warning", `299 - "use the v2 VM endpoints"`)
+	}
+	ms := newMockServer(t, []route{
+		{"GET", "/vms", func(w http.ResponseWriter, r *http.Request, body []byte) {
+			deprecated(w)
+			jsonResponse(w, http.StatusOK, api.ListVMsResponse{Data: []api.VM{vm}})
+		}},
+		{"GET", "/vms/vm_abc123", func(w http.ResponseWriter, r *http.Request, body []byte) {
+			deprecated(w)
+			jsonResponse(w, http.StatusOK, wrapData(vm))
+		}},
+	})
+
+	result := runCLI(t, ms, "status", "agent-1")
+	require.Equal(t, 0, result.ExitCode, result.Stderr)
+	require.Equal(t, 1, strings.Count(result.Stderr, "Warning:"), result.Stderr)
+	require.Contains(t, result.Stderr, "GET /vms is deprecated and will be removed on 2026-12-01: use the v2 VM endpoints")
+}
+
+func TestAPI_WarningHeaderWithoutDeprecation(t *testing.T) {
+	ms := newMockServer(t, []route{
+		{"GET", "/vms", func(w http.ResponseWriter, r *http.Request, body []byte) {
+			w.Header().Set("Warning", `299 irons "scheduled maintenance at 02:00 UTC"`)
+			jsonResponse(w, http.StatusOK, api.ListVMsResponse{Data: []api.VM{}})
+		}},
+	})
+
+	result := runCLI(t, ms, "list")
+	require.Equal(t, 0, result.ExitCode, result.Stderr)
+	require.Equal(t, "Warning: scheduled maintenance at 02:00 UTC\n", result.Stderr)
+}
+
+func TestCompareVersions(t *testing.T) {
+	for _, tc := range []struct {
+		a, b string
+		want int
+	}{
+		{"1.2.0", "1.2.0", 0},
+		{"v1.2.0", "1.2", 0},
+		{"1.2.0", "1.10.0", -1},
+		{"2.0.0", "1.99.99", 1},
+		{"1.2.0-rc.1", "1.2.0", -1},
+		{"1.2.0", "1.2.0-rc.1", 1},
+		{"1.2.0-rc.1", "1.2.0-rc.2", -1},
+		{"1.2.0+abc", "1.2.0", 0},
+	} {
+		got, err := api.CompareVersions(tc.a, tc.b)
+		require.NoError(t, err, "%s vs %s", tc.a, tc.b)
+		require.Equal(t, tc.want, got, "%s vs %s", tc.a, tc.b)
+	}
+
+	_, err := api.CompareVersions("dev", "1.0.0")
+	require.Error(t, err)
+}
+
+func TestVersion_NoAuthRequired(t *testing.T) {
+	result := runCLIAt(t, "http://127.0.0.1:1", nil, "version")
+	require.Equal(t, 0, result.ExitCode, result.Stderr)
+	require.Contains(t, result.Stdout, "irons "+testVersion)
+}
+
+func TestVersion_Check(t *testing.T) {
+	srv := apitest.NewServer(t, apitest.Options{MinimumCLIVersion: "1.0.0", LatestCLIVersion: "1.3.0"})
+	result := runCLIAt(t, srv.URL, nil, "version", "--check")
+	require.Equal(t, 0, result.ExitCode, result.Stderr)
+	require.Contains(t, result.Stdout, "1.3.0 is available")
+
+	srv = apitest.NewServer(t, apitest.Options{MinimumCLIVersion: "1.2.0", LatestCLIVersion: "1.2.0"})
+	result = runCLIAt(t, srv.URL, nil, "version", "--check")
+	require.Equal(t, 0, result.ExitCode, result.Stderr)
+	require.Contains(t, result.Stdout, "up to date")
+
+	srv = apitest.NewServer(t, apitest.Options{MinimumCLIVersion: "1.5.0"})
+	result = runCLIAt(t, srv.URL, nil, "version", "--check")
+	require.Equal(t, 1, result.ExitCode)
+	require.Contains(t, result.Stderr, "older than the minimum supported version 1.5.0")
+}
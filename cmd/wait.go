@@ -1,17 +1,28 @@
 package cmd
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"os"
+	"os/exec"
 	"slices"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/ironsh/irons/api"
+	"github.com/spf13/cobra"
 )
 
 const (
 	pollInterval = 2 * time.Second
 	pollTimeout  = 10 * time.Minute
+	// probeTimeout is the least time a probe is given, whatever the
+	// interval, since it makes an SSH connection to the VM.
+	probeTimeout = 10 * time.Second
 )
 
 // statusIn returns a condition func that is satisfied when the VM's status
@@ -30,42 +41,345 @@ func statusAndDetailEq(status string, detail string) func(*api.VM) bool {
 	}
 }
 
+// waitOptions control how waitForVM polls a VM.
+type waitOptions struct {
+	// Timeout is how long to wait before giving up.
+	Timeout time.Duration
+	// Interval is the time between polls.
+	Interval time.Duration
+	// Progress receives progress reports, normally os.Stderr. If nil,
+	// none are made.
+	Progress io.Writer
+}
+
+// defaultWait returns the waitOptions used unless a command's flags say
+// otherwise.
+func defaultWait() waitOptions {
+	return waitOptions{Timeout: pollTimeout, Interval: pollInterval, Progress: os.Stderr}
+}
+
+// addWaitFlags adds the --timeout and --quiet flags to a command that
+// waits for what.
+func addWaitFlags(cmd *cobra.Command, what string) {
+	cmd.Flags().Duration("timeout", pollTimeout, "How long to wait for "+what)
+	cmd.Flags().BoolP("quiet", "q", false, "Do not report progress while waiting")
+}
+
+// waitFlags returns the waitOptions given by the flags added by
+// addWaitFlags. --timeout must be positive.
+func waitFlags(cmd *cobra.Command) (waitOptions, error) {
+	opts := defaultWait()
+	opts.Timeout, _ = cmd.Flags().GetDuration("timeout")
+	if opts.Timeout <= 0 {
+		return opts, fmt.Errorf("--timeout must be positive")
+	}
+	if quiet, _ := cmd.Flags().GetBool("quiet"); quiet {
+		opts.Progress = nil
+	}
+	return opts, nil
+}
+
 // waitForVMCond polls the VM until cond returns true, the timeout is
-// exceeded, or ctx is cancelled. It prints progress to stdout.
-func waitForVMCond(ctx context.Context, client *api.Client, id string, cond func(*api.VM) bool) error {
-	deadline := time.Now().Add(pollTimeout)
+// exceeded, or ctx is cancelled. It reports progress to stderr.
+func waitForVMCond(ctx context.Context, client api.VMService, id string, cond func(*api.VM) bool) error {
+	return waitForVM(ctx, client, id, cond, defaultWait())
+}
 
-	fmt.Printf("Waiting for VM '%s'", id)
+// waitForVM is like waitForVMCond but polls as opts say. A VM that no
+// longer exists is treated as destroyed, and the wait fails if the VM is
+// destroyed or fails without satisfying cond.
+func waitForVM(ctx context.Context, client api.VMService, id string, cond func(*api.VM) bool, opts waitOptions) error {
+	deadline := time.Now().Add(opts.Timeout)
 
-	ticker := time.NewTicker(pollInterval)
+	progress := newWaitProgress(opts.Progress, fmt.Sprintf("VM '%s'", id))
+	defer progress.finish()
+
+	ticker := time.NewTicker(opts.Interval)
 	defer ticker.Stop()
 
+	var lastErr error
 	for {
 		if time.Now().After(deadline) {
-			fmt.Println()
-			return fmt.Errorf("timed out after %s waiting for VM '%s'", pollTimeout, id)
+			if lastErr != nil {
+				return fmt.Errorf("timed out after %s waiting for VM '%s': %w", opts.Timeout, id, lastErr)
+			}
+			return fmt.Errorf("timed out after %s waiting for VM '%s'", opts.Timeout, id)
 		}
 
-		resp, err := client.GetVM(id)
-		if err != nil {
+		resp, err := client.GetVMContext(ctx, id)
+		if api.IsNotFound(err) {
+			resp, err = &api.VM{ID: id, Status: "destroyed"}, nil
+		}
+		lastErr = err
+		if err != nil && ctx.Err() == nil {
 			// Transient network errors shouldn't abort the wait; just retry.
-			fmt.Print(".")
-		} else if resp.Status == "failed" {
-			fmt.Println()
-			return fmt.Errorf("VM '%s' entered failed state", id)
-		} else if cond(resp) {
-			fmt.Println()
-			return nil
-		} else {
-			fmt.Print(".")
+			progress.fail(err)
+		} else if err == nil {
+			progress.observe(vmState(resp))
+			if cond(resp) {
+				return nil
+			}
+			if resp.Status == "failed" || resp.Status == "destroyed" {
+				return fmt.Errorf("VM '%s' entered %s state", id, resp.Status)
+			}
 		}
 
 		// Wait for the next poll interval or an early exit signal.
 		select {
 		case <-ctx.Done():
-			fmt.Println()
 			return fmt.Errorf("cancelled while waiting for VM '%s': %w", id, ctx.Err())
 		case <-ticker.C:
 		}
 	}
 }
+
+// vmState describes the VM's status and, if it has one, its status detail.
+func vmState(vm *api.VM) string {
+	if vm.StatusDetail == "" || vm.StatusDetail == vm.Status {
+		return vm.Status
+	}
+	return vm.Status + "/" + vm.StatusDetail
+}
+
+// waitForProbe runs probe every opts.Interval until it succeeds, the
+// timeout is exceeded, or ctx is cancelled. It is always run at least once,
+// and on timeout the last probe error is reported.
+func waitForProbe(ctx context.Context, id, desc string, probe func(context.Context) error, opts waitOptions) error {
+	deadline := time.Now().Add(opts.Timeout)
+
+	progress := newWaitProgress(opts.Progress, fmt.Sprintf("VM '%s' to be %s", id, desc))
+	defer progress.finish()
+
+	ticker := time.NewTicker(opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		// Each attempt is bounded, so a dropped connection cannot hold up
+		// the next one, but given long enough for an SSH handshake even if
+		// the interval is short. The ticker only spaces attempts out.
+		attemptCtx, cancel := context.WithTimeout(ctx, max(opts.Interval, probeTimeout))
+		err := probe(attemptCtx)
+		cancel()
+		if err == nil {
+			return nil
+		}
+		progress.fail(err)
+
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timed out waiting for VM '%s' to be %s: %w", id, desc, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("cancelled while waiting for VM '%s': %w", id, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// waitCmd represents the wait command
+var waitCmd = &cobra.Command{
+	Use:   "wait ID...",
+	Short: "Wait for VMs to reach a condition",
+	Long: `Wait for one or more VMs to reach a condition.
+
+--for takes one of:
+  status=STATUS[,detail=DETAIL]  the VM has the status, and the detail if given
+  delete                         the VM has been destroyed
+  ssh                            the VM is running and can be logged in to
+  port:PORT                      the VM is running and accepts connections on PORT
+
+ssh runs a command on the VM over SSH, and port: runs nc on it to check
+that the VM accepts connections on PORT itself, so they check that it can
+actually be reached rather than only what the API reports. The VM is
+polled every --interval until the condition holds or --timeout passes,
+and the command fails if it does not, or if the VM fails or is destroyed
+first. With several VMs, they are waited for concurrently and a table of
+results is shown.
+
+Examples:
+  irons wait my-vm
+  irons wait my-vm --for status=stopped
+  irons wait agent-1 agent-2 --for ssh --timeout 5m
+  irons wait my-vm --for port:3000 --interval 1s
+  irons wait vm_abc123 --for delete`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		forFlag, _ := cmd.Flags().GetString("for")
+		interval, _ := cmd.Flags().GetDuration("interval")
+
+		cond, err := parseWaitCondition(forFlag)
+		if err != nil {
+			return err
+		}
+		wait, err := waitFlags(cmd)
+		if err != nil {
+			return err
+		}
+		if interval <= 0 {
+			return fmt.Errorf("--interval must be positive")
+		}
+		wait.Interval = interval
+		output, err := newPrinter()
+		if err != nil {
+			return err
+		}
+		msgs := output.messages()
+
+		client := newClient()
+		ctx := cmd.Context()
+
+		var targets []target
+		for _, arg := range args {
+			id, err := resolveVM(ctx, client, arg)
+			if cond.gone && api.IsNotFound(err) {
+				// Destroyed VMs are not resolved, so a VM that is already
+				// gone is waited for by the argument, which waitForVM
+				// cannot find and so treats as destroyed.
+				id, err = arg, nil
+			}
+			if err != nil {
+				return err
+			}
+			targets = append(targets, target{ID: id, Name: arg})
+		}
+
+		if len(targets) == 1 {
+			if err := waitUntil(ctx, client, targets[0].ID, cond, wait); err != nil {
+				return err
+			}
+			fmt.Fprintf(msgs, "✓ VM '%s' is %s\n", targets[0].Name, cond.Desc)
+			return printTargets(ctx, client, output, targets, false)
+		}
+		wait.Progress = nil
+		err = runBulk(ctx, targets, len(targets), "be "+cond.Desc, cond.Desc, msgs, func(ctx context.Context, id string, out io.Writer) error {
+			return waitUntil(ctx, client, id, cond, wait)
+		})
+		if perr := printTargets(ctx, client, output, targets, true); perr != nil {
+			return errors.Join(err, perr)
+		}
+		return err
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(waitCmd)
+	waitCmd.Flags().String("for", "status=running,detail=ready", "Condition to wait for: status=STATUS[,detail=DETAIL], delete, ssh or port:PORT")
+	addWaitFlags(waitCmd, "each VM")
+	waitCmd.Flags().Duration("interval", pollInterval, "Time between checks")
+}
+
+// waitCondition is a condition that irons wait waits for.
+type waitCondition struct {
+	// Desc describes a VM that meets the condition, e.g. "running".
+	Desc string
+	// cond is polled for first.
+	cond func(*api.VM) bool
+	// probe, if set, is then run on the VM over SSH until it succeeds.
+	probe func(ctx context.Context, resp *api.SSHResponse) error
+	// gone reports whether a VM that cannot be found meets the condition.
+	gone bool
+}
+
+// parseWaitCondition parses the value of --for.
+func parseWaitCondition(s string) (waitCondition, error) {
+	switch {
+	case s == "delete":
+		return waitCondition{Desc: "deleted", cond: statusIn("destroyed"), gone: true}, nil
+
+	case s == "ssh":
+		return waitCondition{
+			Desc: "reachable over SSH",
+			cond: statusIn("running"),
+			probe: func(ctx context.Context, resp *api.SSHResponse) error {
+				return probeSSH(ctx, resp)
+			},
+		}, nil
+
+	case strings.HasPrefix(s, "port:"):
+		port, err := strconv.Atoi(strings.TrimPrefix(s, "port:"))
+		if err != nil || port < 1 || port > 65535 {
+			return waitCondition{}, fmt.Errorf("invalid --for %q: port must be between 1 and 65535", s)
+		}
+		return waitCondition{
+			Desc: fmt.Sprintf("listening on port %d", port),
+			cond: statusIn("running"),
+			probe: func(ctx context.Context, resp *api.SSHResponse) error {
+				return probePort(ctx, resp, port)
+			},
+		}, nil
+
+	case strings.HasPrefix(s, "status="):
+		var status, detail string
+		for _, part := range strings.Split(s, ",") {
+			key, value, _ := strings.Cut(part, "=")
+			switch {
+			case value == "":
+				return waitCondition{}, fmt.Errorf("invalid --for %q: %q needs a value", s, part)
+			case key == "status" && status == "":
+				status = value
+			case key == "detail" && detail == "":
+				detail = value
+			default:
+				return waitCondition{}, fmt.Errorf("invalid --for %q: unexpected %q", s, part)
+			}
+		}
+		if detail == "" {
+			return waitCondition{Desc: status, cond: statusIn(status)}, nil
+		}
+		return waitCondition{Desc: status + "/" + detail, cond: statusAndDetailEq(status, detail)}, nil
+	}
+	return waitCondition{}, fmt.Errorf("invalid --for %q: must be status=STATUS[,detail=DETAIL], delete, ssh or port:PORT", s)
+}
+
+// waitUntil waits for the VM to meet c, sharing opts.Timeout between
+// polling the VM and probing it.
+func waitUntil(ctx context.Context, client api.VMService, id string, c waitCondition, opts waitOptions) error {
+	start := time.Now()
+	if err := waitForVM(ctx, client, id, c.cond, opts); err != nil {
+		return err
+	}
+	if c.probe == nil {
+		return nil
+	}
+
+	resp, err := client.SSHContext(ctx, id)
+	if err != nil {
+		return fmt.Errorf("getting SSH info: %w", err)
+	}
+	opts.Timeout -= time.Since(start)
+	return waitForProbe(ctx, id, c.Desc, func(ctx context.Context) error { return c.probe(ctx, resp) }, opts)
+}
+
+// probePort checks that the VM described by resp accepts TCP connections
+// on port. The check is made on the VM over SSH: its SSH host may be a
+// gateway shared with other VMs, so connecting to that would say nothing
+// about the VM.
+func probePort(ctx context.Context, resp *api.SSHResponse, port int) error {
+	out, err := sshCommand(ctx, resp, fmt.Sprintf("nc -z 127.0.0.1 %d", port)).CombinedOutput()
+	var exit *exec.ExitError
+	if errors.As(err, &exit) && exit.ExitCode() == 1 {
+		return fmt.Errorf("nothing is listening on port %d", port)
+	}
+	return probeError(fmt.Sprintf("checking port %d over SSH", port), err, out)
+}
+
+// probeSSH checks that the VM described by resp can be logged in to, by
+// running a command on it. Like probePort, it goes through the SSH host to
+// the VM, since the host answering says nothing about the VM.
+func probeSSH(ctx context.Context, resp *api.SSHResponse) error {
+	out, err := sshCommand(ctx, resp, "true").CombinedOutput()
+	return probeError("connecting over SSH", err, out)
+}
+
+// probeError describes a failed probe command with its output, or returns
+// nil if err is nil.
+func probeError(what string, err error, out []byte) error {
+	if err == nil {
+		return nil
+	}
+	if out = bytes.TrimSpace(out); len(out) > 0 {
+		return fmt.Errorf("%s: %w: %s", what, err, out)
+	}
+	return fmt.Errorf("%s: %w", what, err)
+}
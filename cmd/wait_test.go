@@ -0,0 +1,191 @@
+package cmd
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/ironsh/irons/api"
+	"github.com/ironsh/irons/apitest"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeVMService is an in-memory api.VMService. Only GetVMContext is
+// implemented; calling any other method panics via the nil embedded interface.
+type fakeVMService struct {
+	api.VMService
+	vm    api.VM
+	calls int
+}
+
+func (f *fakeVMService) GetVMContext(ctx context.Context, id string) (*api.VM, error) {
+	f.calls++
+	vm := f.vm
+	return &vm, nil
+}
+
+func TestWaitForVMCond_Satisfied(t *testing.T) {
+	fake := &fakeVMService{vm: api.VM{ID: "vm_abc123", Status: "running", StatusDetail: "ready"}}
+
+	err := waitForVMCond(context.Background(), fake, "vm_abc123", statusAndDetailEq("running", "ready"))
+	require.NoError(t, err)
+	require.Equal(t, 1, fake.calls)
+}
+
+func TestWaitForVMCond_Failed(t *testing.T) {
+	fake := &fakeVMService{vm: api.VM{ID: "vm_abc123", Status: "failed"}}
+
+	err := waitForVMCond(context.Background(), fake, "vm_abc123", statusIn("running"))
+	require.ErrorContains(t, err, "entered failed state")
+}
+
+func TestWaitForVMCond_Cancelled(t *testing.T) {
+	fake := &fakeVMService{vm: api.VM{ID: "vm_abc123", Status: "creating"}}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := waitForVMCond(ctx, fake, "vm_abc123", statusIn("running"))
+	require.ErrorIs(t, err, context.Canceled)
+}
+
+func TestWaitForProbe_AttemptOutlastsInterval(t *testing.T) {
+	// A probe that takes longer than the interval, like an SSH handshake
+	// polled every second, must not be cut off every time.
+	probe := func(ctx context.Context) error {
+		select {
+		case <-time.After(50 * time.Millisecond):
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	err := waitForProbe(context.Background(), "vm_abc123", "ready", probe, waitOptions{Timeout: time.Second, Interval: 10 * time.Millisecond})
+	require.NoError(t, err)
+}
+
+func TestParseWaitCondition(t *testing.T) {
+	for _, tc := range []struct {
+		in, desc string
+		vm       api.VM
+		ok       bool
+	}{
+		{"status=running", "running", api.VM{Status: "running", StatusDetail: "booting"}, true},
+		{"status=running,detail=ready", "running/ready", api.VM{Status: "running", StatusDetail: "booting"}, false},
+		{"delete", "deleted", api.VM{Status: "destroyed"}, true},
+		{"ssh", "reachable over SSH", api.VM{Status: "running"}, true},
+		{"port:3000", "listening on port 3000", api.VM{Status: "stopped"}, false},
+	} {
+		c, err := parseWaitCondition(tc.in)
+		require.NoError(t, err, tc.in)
+		require.Equal(t, tc.desc, c.Desc)
+		require.Equal(t, tc.ok, c.cond(&tc.vm), tc.in)
+	}
+
+	for _, in := range []string{"", "running", "status=", "status=a,status=b", "status=a,color=b", "port:", "port:http", "port:70000"} {
+		_, err := parseWaitCondition(in)
+		require.Error(t, err, in)
+	}
+}
+
+// listen starts a TCP server on a free port that writes banner to every
+// connection, and returns its port.
+func listen(t *testing.T, banner string) int {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Write([]byte(banner))
+			conn.Close()
+		}
+	}()
+	return ln.Addr().(*net.TCPAddr).Port
+}
+
+func TestWait_SSH(t *testing.T) {
+	srv := apitest.NewServer(t, apitest.Options{})
+	srv.AddVM(api.VM{Name: "agent-1"})
+	srv.AddVM(api.VM{Name: "agent-2"})
+	env, log := fakeSSH(t, srv)
+
+	// The SSH host answering is not enough: the VM itself must let us in.
+	res := runCLIWithEnv(t, append(env, "FAKE_SSH_EXIT=255"), nil, "wait", "agent-1", "--for", "ssh", "--timeout", "1s", "--interval", "100ms")
+	require.NotEqual(t, 0, res.ExitCode)
+	require.Contains(t, res.Stderr, "connecting over SSH: exit status 255")
+	require.Contains(t, readLog(t, log), "iron@127.0.0.1 true")
+
+	res = runCLIWithEnv(t, env, nil, "wait", "agent-1", "--for", "ssh")
+	require.Equal(t, 0, res.ExitCode, res.Stderr)
+	require.Contains(t, res.Stdout, "✓ VM 'agent-1' is reachable over SSH")
+
+	res = runCLIWithEnv(t, env, nil, "wait", "agent-1", "agent-2", "--for", "ssh")
+	require.Equal(t, 0, res.ExitCode, res.Stderr)
+	require.Contains(t, res.Stdout, "✓ 2 VMs reachable over SSH")
+}
+
+func TestWait_PortIsCheckedOnVM(t *testing.T) {
+	srv := apitest.NewServer(t, apitest.Options{})
+	srv.AddVM(api.VM{Name: "agent-1"})
+	env, log := fakeSSH(t, srv)
+
+	// The port is open on the SSH host, which may be a gateway shared by
+	// other VMs, but nc on the VM finds nothing listening.
+	port := strconv.Itoa(listen(t, ""))
+	res := runCLIWithEnv(t, append(env, "FAKE_SSH_EXIT=1"), nil, "wait", "agent-1", "--for", "port:"+port, "--timeout", "1s", "--interval", "100ms")
+	require.NotEqual(t, 0, res.ExitCode)
+	require.Contains(t, res.Stderr, "timed out waiting for VM")
+	require.Contains(t, res.Stderr, "nothing is listening on port "+port)
+	require.Contains(t, readLog(t, log), "iron@127.0.0.1 nc -z 127.0.0.1 "+port)
+
+	res = runCLIWithEnv(t, env, nil, "wait", "agent-1", "--for", "port:"+port)
+	require.Equal(t, 0, res.ExitCode, res.Stderr)
+	require.Contains(t, res.Stdout, "listening on port "+port)
+}
+
+func TestWait_StatusAndDelete(t *testing.T) {
+	srv := apitest.NewServer(t, apitest.Options{})
+	vm := srv.AddVM(api.VM{Name: "agent-1", Status: "stopped", StatusDetail: "stopped"})
+
+	res := runCLIAt(t, srv.URL, nil, "wait", "agent-1", "--for", "status=running", "--timeout", "1s", "--interval", "100ms")
+	require.NotEqual(t, 0, res.ExitCode)
+	require.Contains(t, res.Stderr, "timed out after 1s")
+
+	res = runCLIAt(t, srv.URL, nil, "destroy", "agent-1")
+	require.Equal(t, 0, res.ExitCode, res.Stderr)
+
+	res = runCLIAt(t, srv.URL, nil, "wait", vm.ID, "--for", "delete")
+	require.Equal(t, 0, res.ExitCode, res.Stderr)
+	require.Contains(t, res.Stdout, "is deleted")
+
+	// Destroyed VMs are not found by name, which is what delete waits for.
+	res = runCLIAt(t, srv.URL, nil, "wait", "agent-1", "--for", "delete")
+	require.Equal(t, 0, res.ExitCode, res.Stderr)
+	require.Contains(t, res.Stdout, "✓ VM 'agent-1' is deleted")
+
+	res = runCLIAt(t, srv.URL, nil, "wait", "vm_gone", "--for", "delete")
+	require.Equal(t, 0, res.ExitCode, res.Stderr)
+
+	res = runCLIAt(t, srv.URL, nil, "wait", "agent-1", "--for", "status=running")
+	require.NotEqual(t, 0, res.ExitCode)
+	require.Contains(t, res.Stderr, "no active VM found")
+}
+
+func TestWait_InvalidFlags(t *testing.T) {
+	srv := apitest.NewServer(t, apitest.Options{})
+
+	res := runCLIAt(t, srv.URL, nil, "wait", "agent-1", "--for", "port:http")
+	require.NotEqual(t, 0, res.ExitCode)
+	require.Contains(t, res.Stderr, "port must be between 1 and 65535")
+
+	res = runCLIAt(t, srv.URL, nil, "start", "agent-1", "--timeout", "0s")
+	require.NotEqual(t, 0, res.ExitCode)
+	require.Contains(t, res.Stderr, "--timeout must be positive")
+	require.Equal(t, 0, srv.CountRequests("GET", "/vms"))
+}
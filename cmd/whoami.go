@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ironsh/irons/api"
+	"github.com/ironsh/irons/config"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// expiryWarning is how close to its expiry an API key must be for whoami to
+// suggest logging in again.
+const expiryWarning = 7 * 24 * time.Hour
+
+// whoamiCmd represents the whoami command
+var whoamiCmd = &cobra.Command{
+	Use:   "whoami",
+	Short: "Show the account the API key belongs to",
+	Long: `Show which account and organization the API key belongs to, the
+scopes it grants and when it expires.
+
+The key is checked against the API, so this also tells you whether it
+still works.
+
+Examples:
+  irons whoami
+  irons whoami --profile staging
+  irons whoami -o json`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		output, err := newPrinter()
+		if err != nil {
+			return err
+		}
+
+		client := newClient()
+		ctx := cmd.Context()
+
+		info, err := client.TokenInfoContext(ctx)
+		if err != nil {
+			return fmt.Errorf("checking API key: %w", err)
+		}
+
+		cfg, err := config.Load()
+		if err != nil {
+			return err
+		}
+
+		err = output.print(info, func() {
+			fmt.Printf("✓ Logged in as %s\n", formatAccount(info.Account))
+			fmt.Printf("  Organization: %s\n", formatOrganization(info.Organization))
+			fmt.Printf("  Profile: %s\n", activeProfile(cfg))
+			fmt.Printf("  API URL: %s\n", viper.GetString("api-url"))
+			fmt.Printf("  Key source: %s\n", apiKeySource())
+			if len(info.Scopes) > 0 {
+				fmt.Printf("  Scopes: %s\n", strings.Join(info.Scopes, ", "))
+			}
+			if info.ExpiresAt == nil {
+				fmt.Printf("  Expires: never\n")
+			} else {
+				fmt.Printf("  Expires: %s\n", info.ExpiresAt.Local().Format(time.RFC1123))
+			}
+		})
+		if err != nil {
+			return err
+		}
+
+		if info.ExpiresAt == nil {
+			return nil
+		}
+		switch left := time.Until(*info.ExpiresAt); {
+		case left <= 0:
+			fmt.Fprintf(output.messages(), "\n⚠ This API key has expired. Run `irons login` to get a new one.\n")
+		case left < expiryWarning:
+			fmt.Fprintf(output.messages(), "\n⚠ This API key expires in %s. Run `irons login` to get a new one.\n", left.Round(time.Minute))
+		}
+		return nil
+	},
+}
+
+// formatAccount describes an account as "Name <email> (id)", leaving out
+// whatever is not set.
+func formatAccount(a api.Account) string {
+	var parts []string
+	if a.Name != "" {
+		parts = append(parts, a.Name)
+	}
+	if a.Email != "" {
+		parts = append(parts, "<"+a.Email+">")
+	}
+	if len(parts) == 0 {
+		return a.ID
+	}
+	return fmt.Sprintf("%s (%s)", strings.Join(parts, " "), a.ID)
+}
+
+// formatOrganization describes an organization as "Name (id)".
+func formatOrganization(o api.Organization) string {
+	if o.Name == "" {
+		return o.ID
+	}
+	return fmt.Sprintf("%s (%s)", o.Name, o.ID)
+}
+
+func init() {
+	rootCmd.AddCommand(whoamiCmd)
+}
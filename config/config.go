@@ -2,8 +2,10 @@ package config
 
 import (
 	"fmt"
+	"maps"
 	"os"
 	"path/filepath"
+	"slices"
 
 	"go.yaml.in/yaml/v3"
 )
@@ -13,9 +15,104 @@ const (
 	configFile = "config.yml"
 )
 
+// DefaultProfile is the profile used when none has been selected.
+const DefaultProfile = "default"
+
 // Config holds the persistent CLI configuration.
 type Config struct {
+	// CurrentProfile is the profile used when --profile and IRONS_PROFILE
+	// are not set. Empty means DefaultProfile.
+	CurrentProfile string `yaml:"current_profile,omitempty"`
+	// Profiles holds the per-account settings, keyed by profile name.
+	Profiles map[string]*Profile `yaml:"profiles,omitempty"`
+
+	// APIKey is the API key written by versions of irons that predated
+	// profiles. Load moves it into the default profile.
 	APIKey string `yaml:"api_key,omitempty"`
+
+	// CACert is a PEM bundle of extra certificate authorities to trust.
+	CACert string `yaml:"ca_cert,omitempty"`
+	// ClientCert and ClientKey are used for mutual TLS.
+	ClientCert string `yaml:"client_cert,omitempty"`
+	ClientKey  string `yaml:"client_key,omitempty"`
+	// Proxy is the URL of an HTTP(S) or SOCKS5 proxy to use instead of the
+	// proxy environment variables.
+	Proxy string `yaml:"proxy,omitempty"`
+}
+
+// Profile holds the settings for one IronCD account.
+type Profile struct {
+	APIURL string `yaml:"api_url,omitempty"`
+	APIKey string `yaml:"api_key,omitempty"`
+	// CredentialProcess is a command that supplies the API key at runtime
+	// instead of APIKey. See CredentialProcess.
+	CredentialProcess string `yaml:"credential_process,omitempty"`
+	// SSHKey is the public key used by `irons create` when --key is not set.
+	SSHKey string `yaml:"ssh_key,omitempty"`
+	// Output is the default output format for commands that print resources.
+	Output string `yaml:"output,omitempty"`
+	// Retries is the number of times failed API requests are retried.
+	Retries *int `yaml:"retries,omitempty"`
+	// EgressMode is the egress mode applied to VMs made by `irons create`.
+	EgressMode string `yaml:"egress_mode,omitempty"`
+	// NamePrefix is prepended to the names of VMs made by `irons create`.
+	NamePrefix string `yaml:"name_prefix,omitempty"`
+}
+
+// ProfileName returns the profile to use: override if it is set, otherwise
+// the current profile.
+func (c *Config) ProfileName(override string) string {
+	switch {
+	case override != "":
+		return override
+	case c.CurrentProfile != "":
+		return c.CurrentProfile
+	default:
+		return DefaultProfile
+	}
+}
+
+// Profile returns the named profile, or nil if it does not exist.
+func (c *Config) Profile(name string) *Profile {
+	return c.Profiles[name]
+}
+
+// EnsureProfile returns the named profile, creating an empty one if it does
+// not exist.
+func (c *Config) EnsureProfile(name string) *Profile {
+	if c.Profiles == nil {
+		c.Profiles = map[string]*Profile{}
+	}
+	p, ok := c.Profiles[name]
+	if !ok || p == nil {
+		p = &Profile{}
+		c.Profiles[name] = p
+	}
+	return p
+}
+
+// ProfileNames returns the names of all profiles, sorted.
+func (c *Config) ProfileNames() []string {
+	return slices.Sorted(maps.Keys(c.Profiles))
+}
+
+// migrate moves a legacy top-level API key into the default profile.
+func (c *Config) migrate() {
+	if c.APIKey == "" {
+		return
+	}
+	if p := c.EnsureProfile(DefaultProfile); p.APIKey == "" {
+		p.APIKey = c.APIKey
+	}
+	if c.CurrentProfile == "" {
+		c.CurrentProfile = DefaultProfile
+	}
+	c.APIKey = ""
+}
+
+// Path returns the path to the config file.
+func Path() (string, error) {
+	return configPath()
 }
 
 // configPath returns the path to the config file:
@@ -52,6 +149,7 @@ func Load() (*Config, error) {
 	if err := yaml.Unmarshal(data, &cfg); err != nil {
 		return nil, fmt.Errorf("parsing config file: %w", err)
 	}
+	cfg.migrate()
 
 	return &cfg, nil
 }
@@ -79,13 +177,22 @@ func Save(cfg *Config) error {
 	return nil
 }
 
-// SetAPIKey is a convenience helper that loads the existing config, sets the
-// API key, and saves it back.
-func SetAPIKey(token string) error {
+// SetProfileAPIKey loads the existing config, stores the API key and, if
+// apiURL is not empty, the API URL in the named profile, and saves it back.
+// The profile is created if needed, and becomes the current profile if
+// there was none.
+func SetProfileAPIKey(profile, apiURL, token string) error {
 	cfg, err := Load()
 	if err != nil {
 		return err
 	}
-	cfg.APIKey = token
+	p := cfg.EnsureProfile(profile)
+	p.APIKey = token
+	if apiURL != "" {
+		p.APIURL = apiURL
+	}
+	if cfg.CurrentProfile == "" {
+		cfg.CurrentProfile = profile
+	}
 	return Save(cfg)
 }
@@ -0,0 +1,146 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// expiryMargin is how long before its expiry a cached credential is
+// refreshed, so it does not expire while a request is in flight.
+const expiryMargin = 30 * time.Second
+
+// Credential is an API key returned by a credential process.
+type Credential struct {
+	APIKey string `json:"api_key"`
+	// ExpiresAt is when the key stops being valid. The zero time means it
+	// does not expire.
+	ExpiresAt time.Time `json:"expires_at,omitzero"`
+}
+
+// storeRequest is written to a credential process's stdin by Store.
+type storeRequest struct {
+	APIKey string `json:"api_key"`
+	APIURL string `json:"api_url,omitempty"`
+}
+
+// CredentialProcess fetches the API key from an external command instead of
+// the config file, in the style of AWS's credential_process. Command is run
+// by the shell with one argument appended, the action:
+//
+//	get    print {"api_key": "...", "expires_at": "<RFC3339>"} on stdout;
+//	       expires_at is optional
+//	store  read {"api_key": "...", "api_url": "..."} from stdin and save it
+//	erase  forget the saved key
+//
+// The key returned by get is cached in memory until shortly before it
+// expires.
+type CredentialProcess struct {
+	Command string
+
+	mu     sync.Mutex
+	cached *Credential
+}
+
+// NewCredentialProcess returns a CredentialProcess that runs command.
+func NewCredentialProcess(command string) *CredentialProcess {
+	return &CredentialProcess{Command: command}
+}
+
+// Token returns the API key, running the command's get action unless a
+// cached key is still valid. It implements api.TokenSource.
+func (p *CredentialProcess) Token(ctx context.Context) (string, error) {
+	cred, err := p.Get(ctx)
+	if err != nil {
+		return "", err
+	}
+	return cred.APIKey, nil
+}
+
+// Get returns the credential, running the command's get action unless a
+// cached credential is still valid.
+func (p *CredentialProcess) Get(ctx context.Context) (Credential, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.cached != nil && (p.cached.ExpiresAt.IsZero() || time.Until(p.cached.ExpiresAt) > expiryMargin) {
+		return *p.cached, nil
+	}
+
+	out, err := p.run(ctx, "get", nil)
+	if err != nil {
+		return Credential{}, err
+	}
+
+	var cred Credential
+	if err := json.Unmarshal(out, &cred); err != nil {
+		return Credential{}, fmt.Errorf("credential process returned invalid JSON: %w", err)
+	}
+	if cred.APIKey == "" {
+		return Credential{}, errors.New("credential process returned no api_key")
+	}
+	if !cred.ExpiresAt.IsZero() && time.Until(cred.ExpiresAt) <= 0 {
+		return Credential{}, fmt.Errorf("credential process returned a key that expired at %s", cred.ExpiresAt.Format(time.RFC3339))
+	}
+
+	p.cached = &cred
+	return cred, nil
+}
+
+// Store hands an API key, and the API URL it was issued for, to the
+// command's store action. The cached credential is replaced with it.
+func (p *CredentialProcess) Store(ctx context.Context, apiURL, apiKey string) error {
+	in, err := json.Marshal(storeRequest{APIKey: apiKey, APIURL: apiURL})
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, err := p.run(ctx, "store", in); err != nil {
+		return err
+	}
+	p.cached = &Credential{APIKey: apiKey}
+	return nil
+}
+
+// Erase runs the command's erase action, so the helper forgets the stored
+// key, and drops the cached credential.
+func (p *CredentialProcess) Erase(ctx context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.cached = nil
+	_, err := p.run(ctx, "erase", nil)
+	return err
+}
+
+// run runs the command with action appended, feeding it stdin, and returns
+// its stdout. The command's stderr is included in the error if it fails.
+func (p *CredentialProcess) run(ctx context.Context, action string, stdin []byte) ([]byte, error) {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", p.Command+" "+action)
+	} else {
+		// "$@" appends the action as a properly quoted argument.
+		cmd = exec.CommandContext(ctx, "sh", "-c", p.Command+` "$@"`, "sh", action)
+	}
+	cmd.Stdin = bytes.NewReader(stdin)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("credential process %s failed: %w: %s", action, err, msg)
+		}
+		return nil, fmt.Errorf("credential process %s failed: %w", action, err)
+	}
+	return stdout.Bytes(), nil
+}
@@ -0,0 +1,139 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Scope says where in the config file a setting is stored.
+type Scope int
+
+const (
+	// ProfileScope settings are stored separately in each profile.
+	ProfileScope Scope = iota
+	// GlobalScope settings are stored once, at the top level, and shared by
+	// every profile.
+	GlobalScope
+)
+
+// String returns "profile" or "global".
+func (s Scope) String() string {
+	if s == GlobalScope {
+		return "global"
+	}
+	return "profile"
+}
+
+// internalKeys are top-level fields that are not settings: they are managed
+// by `irons config use-profile` and `irons login`, or only read for
+// migration.
+var internalKeys = []string{"current_profile", "profiles", "api_key"}
+
+// KeyScope returns the scope of the setting stored under key, reporting
+// false if there is no such setting.
+func KeyScope(key string) (Scope, bool) {
+	if _, ok := fieldByKey(reflect.ValueOf(&Profile{}).Elem(), key); ok {
+		return ProfileScope, true
+	}
+	if slices.Contains(internalKeys, key) {
+		return 0, false
+	}
+	if _, ok := fieldByKey(reflect.ValueOf(&Config{}).Elem(), key); ok {
+		return GlobalScope, true
+	}
+	return 0, false
+}
+
+// Get returns the value of key as set in the file, in the named profile for
+// profile-scoped keys. It reports false if the key is unset.
+func (c *Config) Get(profile, key string) (string, bool) {
+	v, ok := c.field(profile, key, false)
+	if !ok {
+		return "", false
+	}
+	return fieldString(v)
+}
+
+// Set stores value under key, in the named profile for profile-scoped keys,
+// creating the profile if needed. Values for numeric keys must be integers.
+func (c *Config) Set(profile, key, value string) error {
+	v, ok := c.field(profile, key, true)
+	if !ok {
+		return fmt.Errorf("unknown config key %q", key)
+	}
+	switch v.Kind() {
+	case reflect.Pointer:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%s must be an integer", key)
+		}
+		v.Set(reflect.ValueOf(&n))
+	default:
+		v.SetString(value)
+	}
+	return nil
+}
+
+// Unset removes key from the file, in the named profile for profile-scoped
+// keys.
+func (c *Config) Unset(profile, key string) error {
+	if _, ok := KeyScope(key); !ok {
+		return fmt.Errorf("unknown config key %q", key)
+	}
+	if v, ok := c.field(profile, key, false); ok {
+		v.SetZero()
+	}
+	return nil
+}
+
+// field returns the settable struct field for key. Profile-scoped keys are
+// looked up in the named profile, which is created if create is set.
+func (c *Config) field(profile, key string, create bool) (reflect.Value, bool) {
+	scope, ok := KeyScope(key)
+	if !ok {
+		return reflect.Value{}, false
+	}
+	if scope == GlobalScope {
+		return fieldByKey(reflect.ValueOf(c).Elem(), key)
+	}
+
+	p := c.Profile(profile)
+	if p == nil {
+		if !create {
+			return reflect.Value{}, false
+		}
+		p = c.EnsureProfile(profile)
+	}
+	return fieldByKey(reflect.ValueOf(p).Elem(), key)
+}
+
+// fieldByKey returns the field of struct v whose yaml tag names key.
+func fieldByKey(v reflect.Value, key string) (reflect.Value, bool) {
+	t := v.Type()
+	for i := range t.NumField() {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("yaml"), ",")
+		if name == key {
+			return v.Field(i), true
+		}
+	}
+	return reflect.Value{}, false
+}
+
+// fieldString formats a string or *int field, reporting false if it is
+// empty or nil.
+func fieldString(v reflect.Value) (string, bool) {
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			return "", false
+		}
+		return fmt.Sprint(v.Elem().Interface()), true
+	case reflect.String:
+		return v.String(), v.String() != ""
+	default:
+		return "", false
+	}
+}
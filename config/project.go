@@ -0,0 +1,145 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// ProjectFile is the name of the per-project config file.
+const ProjectFile = ".irons.yml"
+
+// Project holds per-project defaults from a .irons.yml file, typically
+// committed to the root of a repository. Its settings take precedence over
+// the user's config file, but not over flags or environment variables.
+type Project struct {
+	// NamePrefix is prepended to the names of VMs made by `irons create`.
+	NamePrefix string `yaml:"name_prefix,omitempty"`
+	// SSHKey is the public key used by `irons create`. A relative path is
+	// relative to the project file, and ~ is the user's home directory.
+	SSHKey string `yaml:"ssh_key,omitempty"`
+	// EgressMode is the egress mode applied to VMs made by `irons create`.
+	EgressMode string `yaml:"egress_mode,omitempty"`
+	// Output is the default output format.
+	Output string `yaml:"output,omitempty"`
+	// Retries is the number of times failed API requests are retried.
+	Retries *int `yaml:"retries,omitempty"`
+	// Upload lists files and directories that `irons create` copies to new
+	// VMs when --upload is not given, as local:remote. A relative local path
+	// is relative to the project file.
+	Upload []string `yaml:"upload,omitempty"`
+	// Egress lists egress rules the project needs. `irons create` and `irons
+	// run` refuse to start if the account is missing any, unless told to add
+	// them.
+	Egress []EgressRule `yaml:"egress,omitempty"`
+
+	// Path is the file the project was loaded from.
+	Path string `yaml:"-"`
+}
+
+// EgressRule is an egress rule required by a project.
+type EgressRule struct {
+	Name    string `yaml:"name,omitempty"`
+	Host    string `yaml:"host,omitempty"`
+	CIDR    string `yaml:"cidr,omitempty"`
+	Comment string `yaml:"comment,omitempty"`
+}
+
+// FindProject returns the path of the nearest .irons.yml in dir or one of
+// its parents, or "" if there is none.
+func FindProject(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	for {
+		path := filepath.Join(dir, ProjectFile)
+		info, err := os.Stat(path)
+		if err == nil && !info.IsDir() {
+			return path, nil
+		}
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return "", fmt.Errorf("checking for %s: %w", ProjectFile, err)
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", nil
+		}
+		dir = parent
+	}
+}
+
+// LoadProject finds and reads the nearest .irons.yml, starting from dir. If
+// there is none, nil is returned without error. Unknown keys are rejected so
+// that typos, and credentials that do not belong in a repository, are
+// caught.
+func LoadProject(dir string) (*Project, error) {
+	path, err := FindProject(dir)
+	if err != nil || path == "" {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+
+	var p Project
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&p); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	p.Path = path
+
+	if p.SSHKey != "" {
+		p.SSHKey = ResolvePath(filepath.Dir(path), p.SSHKey)
+	}
+	for i, u := range p.Upload {
+		// Split at the last colon, as --upload does.
+		j := strings.LastIndex(u, ":")
+		if j <= 0 {
+			return nil, fmt.Errorf("parsing %s: upload %q must be local:remote", path, u)
+		}
+		p.Upload[i] = ResolvePath(filepath.Dir(path), u[:j]) + u[j:]
+	}
+	for i, r := range p.Egress {
+		if (r.Host == "") == (r.CIDR == "") {
+			return nil, fmt.Errorf("parsing %s: egress rule %d must have exactly one of host or cidr", path, i+1)
+		}
+	}
+
+	return &p, nil
+}
+
+// Get returns the value of a setting key from the project, reporting false
+// if the project does not set it.
+func (p *Project) Get(key string) (string, bool) {
+	v, ok := fieldByKey(reflect.ValueOf(p).Elem(), key)
+	if !ok {
+		return "", false
+	}
+	return fieldString(v)
+}
+
+// ResolvePath expands a leading ~ and makes a relative path relative to dir,
+// for paths given in files such as .irons.yml.
+func ResolvePath(dir, path string) string {
+	if rest, ok := strings.CutPrefix(path, "~/"); ok {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, rest)
+		}
+	}
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(dir, path)
+}
@@ -0,0 +1,324 @@
+// Package jsonpath evaluates the JSONPath templates of kubectl's
+// -o jsonpath output against decoded JSON:
+//
+//	{.name}                          a field of the current object
+//	{.labels.env} {['iron.sh/x']}    nested fields, and keys that are not names
+//	{[0].id} {[-1].id} {[*].id}      elements of a list, counting back from the
+//	                                 end if negative, or all of them
+//	{.labels.*}                      every value of an object, by key
+//	{range [*]}{.id}{"\n"}{end}      the body once for each result
+//	{"\t"}                           a string literal, with Go escapes
+//
+// Text outside braces is copied as it is. An expression with several
+// results prints them separated by spaces. Unlike kubectl, a field that is
+// missing has no result rather than being an error, since the API leaves out
+// fields that are empty.
+//
+// Only what irons needs is implemented: there are no filters or slices.
+package jsonpath
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Template is a parsed JSONPath template.
+type Template struct {
+	nodes []node
+}
+
+// node is text, a literal, an expression or a range.
+type node struct {
+	text string
+	// path is set for expressions and ranges.
+	path []segment
+	// body is set for ranges.
+	body    []node
+	isRange bool
+}
+
+// segment is a step of a path: a key, an index, or every element.
+type segment struct {
+	key   string
+	index int
+	kind  segmentKind
+}
+
+type segmentKind int
+
+const (
+	keySegment segmentKind = iota
+	indexSegment
+	wildcardSegment
+)
+
+// Parse parses a template.
+func Parse(tmpl string) (*Template, error) {
+	actions, err := split(tmpl)
+	if err != nil {
+		return nil, fmt.Errorf("invalid jsonpath %q: %w", tmpl, err)
+	}
+
+	// stack holds the node lists being built: the template's, then the body
+	// of each range that is open.
+	stack := [][]node{nil}
+	ranges := []node{}
+	for _, a := range actions {
+		top := len(stack) - 1
+		if !a.action {
+			stack[top] = append(stack[top], node{text: a.text})
+			continue
+		}
+
+		expr := strings.TrimSpace(a.text)
+		switch {
+		case expr == "end":
+			if len(ranges) == 0 {
+				return nil, fmt.Errorf("invalid jsonpath %q: {end} without {range}", tmpl)
+			}
+			r := ranges[len(ranges)-1]
+			r.body = stack[top]
+			ranges, stack = ranges[:len(ranges)-1], stack[:top]
+			stack[top-1] = append(stack[top-1], r)
+
+		case strings.HasPrefix(expr, "range "):
+			path, err := parsePath(strings.TrimSpace(strings.TrimPrefix(expr, "range ")))
+			if err != nil {
+				return nil, fmt.Errorf("invalid jsonpath %q: %w", tmpl, err)
+			}
+			ranges = append(ranges, node{path: path, isRange: true})
+			stack = append(stack, nil)
+
+		case strings.HasPrefix(expr, `"`):
+			s, err := strconv.Unquote(expr)
+			if err != nil {
+				return nil, fmt.Errorf("invalid jsonpath %q: bad string %s", tmpl, expr)
+			}
+			stack[top] = append(stack[top], node{text: s})
+
+		case strings.HasPrefix(expr, "'") && strings.HasSuffix(expr, "'") && len(expr) > 1:
+			stack[top] = append(stack[top], node{text: expr[1 : len(expr)-1]})
+
+		default:
+			path, err := parsePath(expr)
+			if err != nil {
+				return nil, fmt.Errorf("invalid jsonpath %q: %w", tmpl, err)
+			}
+			if path == nil {
+				// {} and {.} and {$} print the current object.
+				path = []segment{}
+			}
+			stack[top] = append(stack[top], node{path: path})
+		}
+	}
+	if len(ranges) > 0 {
+		return nil, fmt.Errorf("invalid jsonpath %q: {range} without {end}", tmpl)
+	}
+	return &Template{nodes: stack[0]}, nil
+}
+
+// piece is text or the inside of a {...} action.
+type piece struct {
+	text   string
+	action bool
+}
+
+// split splits a template into text and actions. Braces inside quoted
+// strings in actions do not end them.
+func split(tmpl string) ([]piece, error) {
+	var pieces []piece
+	for tmpl != "" {
+		open := strings.IndexByte(tmpl, '{')
+		if open < 0 {
+			pieces = append(pieces, piece{text: tmpl})
+			break
+		}
+		if open > 0 {
+			pieces = append(pieces, piece{text: tmpl[:open]})
+		}
+
+		var quote byte
+		end := -1
+		for i := open + 1; i < len(tmpl) && end < 0; i++ {
+			switch c := tmpl[i]; {
+			case quote != 0 && c == '\\' && quote == '"':
+				i++
+			case quote != 0 && c == quote:
+				quote = 0
+			case quote != 0:
+			case c == '"' || c == '\'':
+				quote = c
+			case c == '}':
+				end = i
+			}
+		}
+		if end < 0 {
+			return nil, fmt.Errorf("unclosed {")
+		}
+		pieces = append(pieces, piece{text: tmpl[open+1 : end], action: true})
+		tmpl = tmpl[end+1:]
+	}
+	return pieces, nil
+}
+
+// parsePath parses a path such as .labels.env or [*].id. A leading $ or @
+// is allowed and ignored.
+func parsePath(s string) ([]segment, error) {
+	orig := s
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "$"), "@")
+	var path []segment
+	for s != "" {
+		switch {
+		case s == ".":
+			s = ""
+
+		case strings.HasPrefix(s, ".*"):
+			path = append(path, segment{kind: wildcardSegment})
+			s = s[2:]
+
+		case s[0] == '.':
+			n := strings.IndexAny(s[1:], ".[")
+			if n < 0 {
+				n = len(s) - 1
+			}
+			if n == 0 {
+				return nil, fmt.Errorf("empty field name in %q", orig)
+			}
+			path = append(path, segment{key: s[1 : n+1]})
+			s = s[n+1:]
+
+		case s[0] == '[':
+			end := strings.IndexByte(s, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("unclosed [ in %q", orig)
+			}
+			inner := strings.TrimSpace(s[1:end])
+			switch {
+			case inner == "*":
+				path = append(path, segment{kind: wildcardSegment})
+			case len(inner) > 1 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0]:
+				path = append(path, segment{key: inner[1 : len(inner)-1]})
+			default:
+				i, err := strconv.Atoi(inner)
+				if err != nil {
+					return nil, fmt.Errorf("invalid index [%s] in %q", inner, orig)
+				}
+				path = append(path, segment{index: i, kind: indexSegment})
+			}
+			s = s[end+1:]
+
+		default:
+			return nil, fmt.Errorf("unexpected %q in %q: paths start with . or [", s, orig)
+		}
+	}
+	return path, nil
+}
+
+// Execute writes the template applied to data, which must be made of the
+// values encoding/json decodes into an any.
+func (t *Template) Execute(w io.Writer, data any) error {
+	return execute(w, t.nodes, data)
+}
+
+func execute(w io.Writer, nodes []node, cur any) error {
+	for _, n := range nodes {
+		switch {
+		case n.isRange:
+			results := eval(n.path, cur)
+			if len(results) == 1 {
+				if list, ok := results[0].([]any); ok {
+					results = list
+				}
+			}
+			for _, r := range results {
+				if err := execute(w, n.body, r); err != nil {
+					return err
+				}
+			}
+
+		case n.path != nil:
+			results := eval(n.path, cur)
+			parts := make([]string, len(results))
+			for i, r := range results {
+				s, err := format(r)
+				if err != nil {
+					return err
+				}
+				parts[i] = s
+			}
+			if _, err := io.WriteString(w, strings.Join(parts, " ")); err != nil {
+				return err
+			}
+
+		default:
+			if _, err := io.WriteString(w, n.text); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// eval returns the values path leads to from cur.
+func eval(path []segment, cur any) []any {
+	results := []any{cur}
+	for _, seg := range path {
+		var next []any
+		for _, r := range results {
+			switch v := r.(type) {
+			case map[string]any:
+				switch seg.kind {
+				case keySegment:
+					if x, ok := v[seg.key]; ok {
+						next = append(next, x)
+					}
+				case wildcardSegment:
+					keys := make([]string, 0, len(v))
+					for k := range v {
+						keys = append(keys, k)
+					}
+					slices.Sort(keys)
+					for _, k := range keys {
+						next = append(next, v[k])
+					}
+				}
+			case []any:
+				switch seg.kind {
+				case indexSegment:
+					i := seg.index
+					if i < 0 {
+						i += len(v)
+					}
+					if i >= 0 && i < len(v) {
+						next = append(next, v[i])
+					}
+				case wildcardSegment:
+					next = append(next, v...)
+				}
+			}
+		}
+		results = next
+	}
+	return results
+}
+
+// format formats a result: strings as they are, other scalars as in JSON
+// and objects and lists as compact JSON.
+func format(v any) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	}
+	b, err := json.Marshal(v)
+	return string(b), err
+}
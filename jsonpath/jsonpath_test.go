@@ -0,0 +1,58 @@
+package jsonpath
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const vms = `[
+	{"id": "vm_1", "name": "a", "status": "running", "labels": {"env": "ci", "iron.sh/agent": "claude"}},
+	{"id": "vm_2", "name": "b", "status": "stopped", "port": 22, "ready": false}
+]`
+
+func TestExecute(t *testing.T) {
+	var data any
+	require.NoError(t, json.Unmarshal([]byte(vms), &data))
+
+	for tmpl, want := range map[string]string{
+		"{[0].name}":                    "a",
+		"{$[-1].id}":                    "vm_2",
+		"{[*].id}":                      "vm_1 vm_2",
+		"{[0].labels.env}":              "ci",
+		"{[0].labels['iron.sh/agent']}": "claude",
+		"{[0].labels.*}":                "ci claude",
+		"{[1].port} {[1].ready}":        "22 false",
+		"{[0].labels}":                  `{"env":"ci","iron.sh/agent":"claude"}`,
+		"{[1].labels.env}":              "",
+		"{[5].id}":                      "",
+		`{range [*]}{.name}={.status}{"\n"}{end}`: "a=running\nb=stopped\n",
+		`{range .}{.id}{'\t'}{end}`:               `vm_1\tvm_2\t`,
+		"ids: {[*].id}!":                          "ids: vm_1 vm_2!",
+		`{"}"}`:                                   "}",
+	} {
+		tp, err := Parse(tmpl)
+		require.NoError(t, err, tmpl)
+		var b strings.Builder
+		require.NoError(t, tp.Execute(&b, data), tmpl)
+		require.Equal(t, want, b.String(), tmpl)
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	for _, tmpl := range []string{
+		"{.name",
+		"{name}",
+		"{[x]}",
+		"{[0}",
+		"{..name}",
+		"{range [*]}{.id}",
+		"{end}",
+		`{"\q"}`,
+	} {
+		_, err := Parse(tmpl)
+		require.Error(t, err, tmpl)
+	}
+}
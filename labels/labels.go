@@ -0,0 +1,238 @@
+// Package labels validates VM labels and parses and evaluates label
+// selectors, with the syntax and rules of Kubernetes:
+//
+//	env=prod,tier!=cache        equality: =, == and !=
+//	env in (prod,staging)       set membership: in and notin
+//	ticket                      the label exists
+//	!ticket                     the label does not exist
+//
+// Requirements are separated by commas, and a VM matches a selector when it
+// meets every requirement.
+package labels
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Operator is how a Requirement compares a label.
+type Operator string
+
+const (
+	Equals       Operator = "="
+	NotEquals    Operator = "!="
+	In           Operator = "in"
+	NotIn        Operator = "notin"
+	Exists       Operator = "exists"
+	DoesNotExist Operator = "!"
+)
+
+// Requirement is one condition of a selector.
+type Requirement struct {
+	Key      string
+	Operator Operator
+	// Values holds the value for Equals and NotEquals, the set for In and
+	// NotIn, and nothing for Exists and DoesNotExist.
+	Values []string
+}
+
+// Matches reports whether labels meet the requirement. As in Kubernetes,
+// NotEquals and NotIn match labels that do not have the key at all.
+func (r Requirement) Matches(labels map[string]string) bool {
+	v, ok := labels[r.Key]
+	switch r.Operator {
+	case Equals, In:
+		return ok && slices.Contains(r.Values, v)
+	case NotEquals, NotIn:
+		return !ok || !slices.Contains(r.Values, v)
+	case Exists:
+		return ok
+	case DoesNotExist:
+		return !ok
+	}
+	return false
+}
+
+// String formats the requirement in selector syntax.
+func (r Requirement) String() string {
+	switch r.Operator {
+	case Exists:
+		return r.Key
+	case DoesNotExist:
+		return "!" + r.Key
+	case In, NotIn:
+		return fmt.Sprintf("%s %s (%s)", r.Key, r.Operator, strings.Join(r.Values, ","))
+	}
+	return r.Key + string(r.Operator) + r.Values[0]
+}
+
+// Selector is a list of requirements that must all be met. The empty
+// selector matches everything.
+type Selector []Requirement
+
+// Matches reports whether labels meet every requirement.
+func (s Selector) Matches(labels map[string]string) bool {
+	for _, r := range s {
+		if !r.Matches(labels) {
+			return false
+		}
+	}
+	return true
+}
+
+// String formats the selector in selector syntax.
+func (s Selector) String() string {
+	parts := make([]string, len(s))
+	for i, r := range s {
+		parts[i] = r.String()
+	}
+	return strings.Join(parts, ",")
+}
+
+// setRequirement matches "key in (a,b)" and "key notin (a,b)".
+var setRequirement = regexp.MustCompile(`^(\S+)\s+(in|notin)\s*\((.*)\)$`)
+
+// Parse parses a selector. An empty string is the empty selector.
+func Parse(selector string) (Selector, error) {
+	if strings.TrimSpace(selector) == "" {
+		return nil, nil
+	}
+	var s Selector
+	for _, term := range splitTerms(selector) {
+		term = strings.TrimSpace(term)
+		if term == "" {
+			return nil, fmt.Errorf("invalid selector %q: empty requirement", selector)
+		}
+		r, err := parseRequirement(term)
+		if err != nil {
+			return nil, fmt.Errorf("invalid selector %q: %w", selector, err)
+		}
+		s = append(s, r)
+	}
+	return s, nil
+}
+
+// splitTerms splits a selector at the commas that are not inside
+// parentheses.
+func splitTerms(selector string) []string {
+	var terms []string
+	depth, start := 0, 0
+	for i, c := range selector {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				terms = append(terms, selector[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(terms, selector[start:])
+}
+
+func parseRequirement(term string) (Requirement, error) {
+	var r Requirement
+	switch {
+	case strings.HasPrefix(term, "!"):
+		r = Requirement{Key: strings.TrimSpace(term[1:]), Operator: DoesNotExist}
+
+	case setRequirement.MatchString(term):
+		m := setRequirement.FindStringSubmatch(term)
+		r = Requirement{Key: m[1], Operator: Operator(m[2])}
+		for _, v := range strings.Split(m[3], ",") {
+			v = strings.TrimSpace(v)
+			if err := ValidateValue(v); err != nil {
+				return r, err
+			}
+			r.Values = append(r.Values, v)
+		}
+		if len(r.Values) == 0 || (len(r.Values) == 1 && r.Values[0] == "") {
+			return r, fmt.Errorf("%q needs at least one value", term)
+		}
+
+	case strings.ContainsAny(term, "=!"):
+		op := Equals
+		key, value, ok := strings.Cut(term, "!=")
+		if ok {
+			op = NotEquals
+		} else if key, value, ok = strings.Cut(term, "=="); !ok {
+			key, value, _ = strings.Cut(term, "=")
+		}
+		r = Requirement{Key: strings.TrimSpace(key), Operator: op, Values: []string{strings.TrimSpace(value)}}
+		if err := ValidateValue(r.Values[0]); err != nil {
+			return r, err
+		}
+
+	default:
+		r = Requirement{Key: term, Operator: Exists}
+	}
+
+	if err := ValidateKey(r.Key); err != nil {
+		return r, err
+	}
+	return r, nil
+}
+
+var (
+	// name matches a label name or value: alphanumerics, '-', '_' and '.',
+	// starting and ending with an alphanumeric.
+	name = regexp.MustCompile(`^[A-Za-z0-9]([-A-Za-z0-9_.]*[A-Za-z0-9])?$`)
+	// dnsSubdomain matches the optional prefix of a key.
+	dnsSubdomain = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*$`)
+)
+
+// ValidateKey checks a label key: a name of up to 63 characters, optionally
+// preceded by a DNS subdomain prefix of up to 253 characters and a slash,
+// e.g. "team" or "iron.sh/agent".
+func ValidateKey(key string) error {
+	prefix, n, hasPrefix := strings.Cut(key, "/")
+	if !hasPrefix {
+		prefix, n = "", key
+	}
+	if hasPrefix && (prefix == "" || len(prefix) > 253 || !dnsSubdomain.MatchString(prefix)) {
+		return fmt.Errorf("invalid label key %q: the prefix must be a lowercase DNS subdomain", key)
+	}
+	if n == "" || len(n) > 63 || !name.MatchString(n) {
+		return fmt.Errorf("invalid label key %q: must be at most 63 alphanumerics, '-', '_' or '.', starting and ending with an alphanumeric", key)
+	}
+	return nil
+}
+
+// ValidateValue checks a label value: empty, or at most 63 alphanumerics,
+// '-', '_' or '.', starting and ending with an alphanumeric.
+func ValidateValue(value string) error {
+	if value != "" && (len(value) > 63 || !name.MatchString(value)) {
+		return fmt.Errorf("invalid label value %q: must be at most 63 alphanumerics, '-', '_' or '.', starting and ending with an alphanumeric", value)
+	}
+	return nil
+}
+
+// ParsePair parses a "key=value" label assignment.
+func ParsePair(pair string) (key, value string, err error) {
+	key, value, ok := strings.Cut(pair, "=")
+	if !ok {
+		return "", "", fmt.Errorf("invalid label %q: must be key=value", pair)
+	}
+	if err := ValidateKey(key); err != nil {
+		return "", "", err
+	}
+	if err := ValidateValue(value); err != nil {
+		return "", "", err
+	}
+	return key, value, nil
+}
+
+// Format formats labels as sorted key=value pairs separated by commas.
+func Format(labels map[string]string) string {
+	pairs := make([]string, 0, len(labels))
+	for k, v := range labels {
+		pairs = append(pairs, k+"="+v)
+	}
+	slices.Sort(pairs)
+	return strings.Join(pairs, ",")
+}
@@ -0,0 +1,68 @@
+package labels
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	for selector, want := range map[string]string{
+		"":                               "",
+		"env=prod":                       "env=prod",
+		"env==prod, tier != cache":       "env=prod,tier!=cache",
+		"env in (prod, staging),ticket":  "env in (prod,staging),ticket",
+		"iron.sh/agent notin (a),!debug": "iron.sh/agent notin (a),!debug",
+		"empty=":                         "empty=",
+	} {
+		s, err := Parse(selector)
+		require.NoError(t, err, selector)
+		require.Equal(t, want, s.String(), selector)
+	}
+
+	for _, selector := range []string{
+		"env=prod,",
+		"env in ()",
+		"=prod",
+		"env=pr od",
+		"-env",
+		"Iron.sh/agent=x",
+		"env in (prod",
+	} {
+		_, err := Parse(selector)
+		require.Error(t, err, selector)
+	}
+}
+
+func TestMatches(t *testing.T) {
+	labels := map[string]string{"env": "prod", "ticket": "IR-42"}
+	for selector, want := range map[string]bool{
+		"":                      true,
+		"env=prod":              true,
+		"env=staging":           false,
+		"env!=staging":          true,
+		"team!=core":            true,
+		"env in (prod,staging)": true,
+		"env notin (prod)":      false,
+		"team notin (core)":     true,
+		"ticket":                true,
+		"!ticket":               false,
+		"env=prod,!ticket":      false,
+		"env=prod,ticket=IR-42": true,
+	} {
+		s, err := Parse(selector)
+		require.NoError(t, err, selector)
+		require.Equal(t, want, s.Matches(labels), selector)
+	}
+}
+
+func TestParsePair(t *testing.T) {
+	k, v, err := ParsePair("iron.sh/agent=claude-1")
+	require.NoError(t, err)
+	require.Equal(t, []string{"iron.sh/agent", "claude-1"}, []string{k, v})
+
+	_, _, err = ParsePair("env")
+	require.ErrorContains(t, err, "must be key=value")
+	_, _, err = ParsePair("env=a b")
+	require.ErrorContains(t, err, "invalid label value")
+}
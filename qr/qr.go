@@ -0,0 +1,500 @@
+// Package qr encodes short strings, such as URLs, as QR codes and renders
+// them for display in a terminal.
+//
+// Only what `irons login` needs is implemented: byte mode, error correction
+// levels L and M, and versions 1 to 10, which hold up to 271 bytes. The
+// encoder follows ISO/IEC 18004.
+package qr
+
+import (
+	"errors"
+	"strings"
+)
+
+// Level is an error correction level.
+type Level int
+
+const (
+	// L recovers about 7% of the codewords.
+	L Level = iota
+	// M recovers about 15% of the codewords.
+	M
+)
+
+// formatBits returns the level's two-bit indicator in the format
+// information, which is not in the same order as the levels.
+func (l Level) formatBits() int {
+	if l == L {
+		return 1
+	}
+	return 0
+}
+
+// maxVersion is the largest version supported.
+const maxVersion = 10
+
+// blockSpec describes how the codewords of a version and level are split
+// into Reed-Solomon blocks: blocks1 blocks of data1 data codewords, then
+// blocks2 blocks of one more, each followed by ecc error correction
+// codewords.
+type blockSpec struct {
+	ecc     int
+	blocks1 int
+	data1   int
+	blocks2 int
+}
+
+// blocks is indexed by level and then version-1.
+var blocks = [2][maxVersion]blockSpec{
+	L: {
+		{7, 1, 19, 0}, {10, 1, 34, 0}, {15, 1, 55, 0}, {20, 1, 80, 0}, {26, 1, 108, 0},
+		{18, 2, 68, 0}, {20, 2, 78, 0}, {24, 2, 97, 0}, {30, 2, 116, 0}, {18, 2, 68, 2},
+	},
+	M: {
+		{10, 1, 16, 0}, {16, 1, 28, 0}, {26, 1, 44, 0}, {18, 2, 32, 0}, {24, 2, 43, 0},
+		{16, 4, 27, 0}, {18, 4, 31, 0}, {22, 2, 38, 2}, {22, 3, 36, 2}, {26, 4, 43, 1},
+	},
+}
+
+// dataCodewords returns the number of data codewords the spec holds.
+func (b blockSpec) dataCodewords() int {
+	return b.blocks1*b.data1 + b.blocks2*(b.data1+1)
+}
+
+// alignment lists the centre coordinates of the alignment patterns, indexed
+// by version-1.
+var alignment = [maxVersion][]int{
+	nil, {6, 18}, {6, 22}, {6, 26}, {6, 30},
+	{6, 34}, {6, 22, 38}, {6, 24, 42}, {6, 26, 46}, {6, 28, 50},
+}
+
+// remainderBits is the number of unused modules after the codewords, indexed
+// by version-1.
+var remainderBits = [maxVersion]int{0, 7, 7, 7, 7, 7, 0, 0, 0, 0}
+
+// ErrTooLong is returned by Encode when the data does not fit in the largest
+// supported version.
+var ErrTooLong = errors.New("qr: data too long")
+
+// Code is an encoded QR code.
+type Code struct {
+	// Version is the QR version, which determines the size.
+	Version int
+	// Size is the width and height in modules.
+	Size int
+
+	modules  [][]bool // true is dark
+	function [][]bool // true for modules that are not data
+}
+
+// Dark reports whether the module at row y and column x is dark. Modules
+// outside the code are light.
+func (c *Code) Dark(x, y int) bool {
+	if x < 0 || y < 0 || x >= c.Size || y >= c.Size {
+		return false
+	}
+	return c.modules[y][x]
+}
+
+// Encode encodes data as a QR code at the given error correction level,
+// using the smallest version it fits in.
+func Encode(data []byte, level Level) (*Code, error) {
+	version := 0
+	for v := 1; v <= maxVersion; v++ {
+		if len(data) <= capacity(v, level) {
+			version = v
+			break
+		}
+	}
+	if version == 0 {
+		return nil, ErrTooLong
+	}
+
+	c := newCode(version)
+	codewords := addECC(encodeData(data, version, level), blocks[level][version-1])
+	c.placeData(codewords)
+
+	// Use the mask with the lowest penalty.
+	best, bestPenalty := 0, -1
+	for mask := range 8 {
+		c.applyMask(mask)
+		c.drawFormat(level, mask)
+		if p := c.penalty(); bestPenalty < 0 || p < bestPenalty {
+			best, bestPenalty = mask, p
+		}
+		c.applyMask(mask) // XOR again to undo it
+	}
+	c.applyMask(best)
+	c.drawFormat(level, best)
+	return c, nil
+}
+
+// countBits returns the length of the byte mode character count.
+func countBits(version int) int {
+	if version < 10 {
+		return 8
+	}
+	return 16
+}
+
+// capacity returns the number of bytes a version and level hold.
+func capacity(version int, level Level) int {
+	bits := blocks[level][version-1].dataCodewords()*8 - 4 - countBits(version)
+	return bits / 8
+}
+
+// bitWriter appends bits to a byte slice, most significant first.
+type bitWriter struct {
+	buf []byte
+	n   int // number of bits written
+}
+
+func (w *bitWriter) write(v, bits int) {
+	for i := bits - 1; i >= 0; i-- {
+		if w.n%8 == 0 {
+			w.buf = append(w.buf, 0)
+		}
+		if v>>i&1 == 1 {
+			w.buf[w.n/8] |= 0x80 >> (w.n % 8)
+		}
+		w.n++
+	}
+}
+
+// encodeData returns the data codewords: the byte mode segment, terminator
+// and padding.
+func encodeData(data []byte, version int, level Level) []byte {
+	total := blocks[level][version-1].dataCodewords()
+
+	var w bitWriter
+	w.write(0b0100, 4) // byte mode
+	w.write(len(data), countBits(version))
+	for _, b := range data {
+		w.write(int(b), 8)
+	}
+	w.write(0, min(4, total*8-w.n)) // terminator
+	if w.n%8 != 0 {
+		w.write(0, 8-w.n%8)
+	}
+	for pad := 0xEC; len(w.buf) < total; pad ^= 0xEC ^ 0x11 {
+		w.write(pad, 8)
+	}
+	return w.buf
+}
+
+// addECC splits data into blocks, computes each block's error correction
+// codewords and returns the interleaved result.
+func addECC(data []byte, spec blockSpec) []byte {
+	n := spec.blocks1 + spec.blocks2
+	gen := generator(spec.ecc)
+	dataBlocks := make([][]byte, n)
+	eccBlocks := make([][]byte, n)
+	for i := range n {
+		size := spec.data1
+		if i >= spec.blocks1 {
+			size++
+		}
+		dataBlocks[i], data = data[:size], data[size:]
+		eccBlocks[i] = remainder(dataBlocks[i], gen)
+	}
+
+	var out []byte
+	for i := range spec.data1 + 1 {
+		for _, b := range dataBlocks {
+			if i < len(b) {
+				out = append(out, b[i])
+			}
+		}
+	}
+	for i := range spec.ecc {
+		for _, b := range eccBlocks {
+			out = append(out, b[i])
+		}
+	}
+	return out
+}
+
+// newCode returns a code of the given version with the function patterns
+// drawn and the format and version areas reserved.
+func newCode(version int) *Code {
+	size := 17 + 4*version
+	c := &Code{Version: version, Size: size}
+	c.modules = make([][]bool, size)
+	c.function = make([][]bool, size)
+	for i := range size {
+		c.modules[i] = make([]bool, size)
+		c.function[i] = make([]bool, size)
+	}
+
+	for i := range size {
+		c.setFunction(6, i, i%2 == 0) // timing patterns
+		c.setFunction(i, 6, i%2 == 0)
+	}
+	c.drawFinder(3, 3)
+	c.drawFinder(size-4, 3)
+	c.drawFinder(3, size-4)
+
+	pos := alignment[version-1]
+	for i, x := range pos {
+		for j, y := range pos {
+			// Skip the three corners taken by finder patterns.
+			if (i == 0 && j == 0) || (i == 0 && j == len(pos)-1) || (i == len(pos)-1 && j == 0) {
+				continue
+			}
+			c.drawAlignment(x, y)
+		}
+	}
+
+	c.drawFormat(L, 0) // reserve the area; overwritten once the mask is chosen
+	c.drawVersion()
+	return c
+}
+
+func (c *Code) setFunction(x, y int, dark bool) {
+	c.modules[y][x] = dark
+	c.function[y][x] = true
+}
+
+// drawFinder draws a finder pattern and its separator centred on x, y.
+func (c *Code) drawFinder(x, y int) {
+	for dy := -4; dy <= 4; dy++ {
+		for dx := -4; dx <= 4; dx++ {
+			xx, yy := x+dx, y+dy
+			if xx < 0 || yy < 0 || xx >= c.Size || yy >= c.Size {
+				continue
+			}
+			d := max(abs(dx), abs(dy))
+			c.setFunction(xx, yy, d != 2 && d != 4)
+		}
+	}
+}
+
+// drawAlignment draws an alignment pattern centred on x, y.
+func (c *Code) drawAlignment(x, y int) {
+	for dy := -2; dy <= 2; dy++ {
+		for dx := -2; dx <= 2; dx++ {
+			c.setFunction(x+dx, y+dy, max(abs(dx), abs(dy)) != 1)
+		}
+	}
+}
+
+// drawFormat draws both copies of the format information, and the dark
+// module next to the lower copy.
+func (c *Code) drawFormat(level Level, mask int) {
+	bits := formatInfo(level, mask)
+	bit := func(i int) bool { return bits>>i&1 == 1 }
+
+	for i := range 6 {
+		c.setFunction(8, i, bit(i))
+	}
+	c.setFunction(8, 7, bit(6))
+	c.setFunction(8, 8, bit(7))
+	c.setFunction(7, 8, bit(8))
+	for i := 9; i < 15; i++ {
+		c.setFunction(14-i, 8, bit(i))
+	}
+
+	for i := range 8 {
+		c.setFunction(c.Size-1-i, 8, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		c.setFunction(8, c.Size-15+i, bit(i))
+	}
+	c.setFunction(8, c.Size-8, true)
+}
+
+// formatInfo returns the 15-bit format information for level and mask: five
+// data bits, ten BCH error correction bits, XORed with a fixed pattern.
+func formatInfo(level Level, mask int) int {
+	data := level.formatBits()<<3 | mask
+	rem := data
+	for range 10 {
+		rem = rem<<1 ^ (rem>>9)*0x537
+	}
+	return (data<<10 | rem) ^ 0x5412
+}
+
+// drawVersion draws both copies of the version information, which only
+// versions 7 and up have.
+func (c *Code) drawVersion() {
+	if c.Version < 7 {
+		return
+	}
+	bits := versionInfo(c.Version)
+	for i := range 18 {
+		dark := bits>>i&1 == 1
+		a, b := c.Size-11+i%3, i/3
+		c.setFunction(a, b, dark)
+		c.setFunction(b, a, dark)
+	}
+}
+
+// versionInfo returns the 18-bit version information: six data bits and
+// twelve BCH error correction bits.
+func versionInfo(version int) int {
+	rem := version
+	for range 12 {
+		rem = rem<<1 ^ (rem>>11)*0x1F25
+	}
+	return version<<12 | rem
+}
+
+// placeData fills the data modules with codewords in the zigzag order, two
+// columns at a time from the right, alternating upwards and downwards.
+func (c *Code) placeData(codewords []byte) {
+	i := 0
+	for right := c.Size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5 // skip the vertical timing pattern
+		}
+		upward := (right+1)&2 == 0
+		for vert := range c.Size {
+			y := vert
+			if upward {
+				y = c.Size - 1 - vert
+			}
+			for j := range 2 {
+				x := right - j
+				if c.function[y][x] {
+					continue
+				}
+				// Remainder bits after the last codeword are light.
+				if i < len(codewords)*8 {
+					c.modules[y][x] = codewords[i/8]>>(7-i%8)&1 == 1
+					i++
+				}
+			}
+		}
+	}
+}
+
+// maskFuncs are the eight data mask patterns; a module is inverted where
+// the pattern is true.
+var maskFuncs = [8]func(x, y int) bool{
+	func(x, y int) bool { return (x+y)%2 == 0 },
+	func(x, y int) bool { return y%2 == 0 },
+	func(x, y int) bool { return x%3 == 0 },
+	func(x, y int) bool { return (x+y)%3 == 0 },
+	func(x, y int) bool { return (x/3+y/2)%2 == 0 },
+	func(x, y int) bool { return x*y%2+x*y%3 == 0 },
+	func(x, y int) bool { return (x*y%2+x*y%3)%2 == 0 },
+	func(x, y int) bool { return ((x+y)%2+x*y%3)%2 == 0 },
+}
+
+// applyMask XORs the data modules with a mask pattern. Applying the same
+// mask twice restores the original modules.
+func (c *Code) applyMask(mask int) {
+	f := maskFuncs[mask]
+	for y := range c.Size {
+		for x := range c.Size {
+			if !c.function[y][x] && f(x, y) {
+				c.modules[y][x] = !c.modules[y][x]
+			}
+		}
+	}
+}
+
+// penalty scores the code by the four rules of the standard, which favour
+// masks that are easy to scan: long runs, 2x2 blocks, patterns that look
+// like finders, and an imbalance of dark and light modules are penalised.
+func (c *Code) penalty() int {
+	p := 0
+	finderLike := [][]bool{
+		{true, false, true, true, true, false, true, false, false, false, false},
+		{false, false, false, false, true, false, true, true, true, false, true},
+	}
+	dark := 0
+	for a := range c.Size {
+		runRow, runCol := 1, 1
+		for b := range c.Size {
+			if c.modules[a][b] {
+				dark++
+			}
+			if b > 0 {
+				// Rule 1: runs of five or more in rows and columns.
+				runRow, p = run(c.modules[a][b] == c.modules[a][b-1], runRow, p)
+				runCol, p = run(c.modules[b][a] == c.modules[b-1][a], runCol, p)
+			}
+			// Rule 2: 2x2 blocks of the same colour.
+			if a > 0 && b > 0 {
+				v := c.modules[a][b]
+				if c.modules[a-1][b] == v && c.modules[a][b-1] == v && c.modules[a-1][b-1] == v {
+					p += 3
+				}
+			}
+			// Rule 3: 1:1:3:1:1 patterns with four light modules on one side.
+			for _, pat := range finderLike {
+				if b+len(pat) > c.Size {
+					continue
+				}
+				row, col := true, true
+				for k, v := range pat {
+					row = row && c.modules[a][b+k] == v
+					col = col && c.modules[b+k][a] == v
+				}
+				if row {
+					p += 40
+				}
+				if col {
+					p += 40
+				}
+			}
+		}
+		p += runEnd(runRow) + runEnd(runCol)
+	}
+
+	// Rule 4: 10 points for every 5% the dark proportion is away from 50%.
+	total := c.Size * c.Size
+	p += abs(dark*20-total*10) / total * 10
+	return p
+}
+
+// run extends or ends a run of same-coloured modules, adding the rule 1
+// penalty for the run that ends.
+func run(same bool, length, p int) (int, int) {
+	if same {
+		return length + 1, p
+	}
+	return 1, p + runEnd(length)
+}
+
+// runEnd returns the rule 1 penalty for a run of length modules.
+func runEnd(length int) int {
+	if length < 5 {
+		return 0
+	}
+	return length - 2
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+// Terminal renders the code with Unicode half blocks, two rows of modules
+// per line, surrounded by a quiet zone of quiet modules. ANSI colours force
+// dark modules to black and light ones to white, so the code scans on
+// terminals with either a dark or a light background.
+func (c *Code) Terminal(quiet int) string {
+	var sb strings.Builder
+	for y := -quiet; y < c.Size+quiet; y += 2 {
+		sb.WriteString("\x1b[30;47m")
+		for x := -quiet; x < c.Size+quiet; x++ {
+			top, bottom := c.Dark(x, y), c.Dark(x, y+1)
+			switch {
+			case top && bottom:
+				sb.WriteString("█")
+			case top:
+				sb.WriteString("▀")
+			case bottom:
+				sb.WriteString("▄")
+			default:
+				sb.WriteString(" ")
+			}
+		}
+		sb.WriteString("\x1b[0m\n")
+	}
+	return sb.String()
+}
@@ -0,0 +1,179 @@
+package qr
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReedSolomon(t *testing.T) {
+	// The data codewords of "HELLO WORLD" at 1-M and their error correction
+	// codewords, from the worked example at thonky.com.
+	data := []byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236, 17, 236, 17}
+	want := []byte{196, 35, 39, 119, 235, 215, 231, 226, 93, 23}
+	require.Equal(t, want, remainder(data, generator(10)))
+}
+
+func TestFormatAndVersionInfo(t *testing.T) {
+	// Values from the tables in ISO/IEC 18004.
+	require.Equal(t, 0b111011111000100, formatInfo(L, 0))
+	require.Equal(t, 0b101010000010010, formatInfo(M, 0))
+	require.Equal(t, 0b111100010011101, formatInfo(L, 3))
+	require.Equal(t, 0b110011000101111, formatInfo(L, 4))
+	require.Equal(t, 0b000111110010010100, versionInfo(7))
+	require.Equal(t, 0b001010010011010011, versionInfo(10))
+}
+
+func TestCapacity(t *testing.T) {
+	require.Equal(t, 17, capacity(1, L))
+	require.Equal(t, 14, capacity(1, M))
+	require.Equal(t, 271, capacity(10, L))
+	require.Equal(t, 213, capacity(10, M))
+
+	_, err := Encode(bytes.Repeat([]byte("x"), 272), L)
+	require.ErrorIs(t, err, ErrTooLong)
+}
+
+func TestEncodeRoundTrip(t *testing.T) {
+	for _, level := range []Level{L, M} {
+		for _, n := range []int{0, 1, 14, 17, 40, 78, 106, 150, 200, 213} {
+			data := make([]byte, n)
+			for i := range data {
+				data[i] = byte(i*7 + n)
+			}
+			c, err := Encode(data, level)
+			require.NoError(t, err)
+			require.Equal(t, 17+4*c.Version, c.Size)
+			require.Equal(t, data, decode(t, c), "level %d, %d bytes", level, n)
+		}
+	}
+}
+
+func TestTerminal(t *testing.T) {
+	c, err := Encode([]byte("https://iron.sh/device?code=IRON-0001"), L)
+	require.NoError(t, err)
+
+	lines := strings.Split(strings.TrimSuffix(c.Terminal(2), "\n"), "\n")
+	require.Len(t, lines, (c.Size+4+1)/2)
+	// Rows 0 and 1 of the top-left finder pattern, after the quiet zone.
+	require.True(t, strings.HasPrefix(lines[1], "\x1b[30;47m  █▀▀▀▀▀█"), lines[1])
+}
+
+// decode reads the data back out of c, checking the format information and
+// every block's error correction codewords along the way.
+func decode(t *testing.T, c *Code) []byte {
+	t.Helper()
+
+	// Read both copies of the format information.
+	var first, second int
+	for i := range 15 {
+		var x1, y1, x2, y2 int
+		switch {
+		case i < 6:
+			x1, y1 = 8, i
+		case i < 8:
+			x1, y1 = 8, i+1
+		case i == 8:
+			x1, y1 = 7, 8
+		default:
+			x1, y1 = 14-i, 8
+		}
+		if i < 8 {
+			x2, y2 = c.Size-1-i, 8
+		} else {
+			x2, y2 = 8, c.Size-15+i
+		}
+		if c.Dark(x1, y1) {
+			first |= 1 << i
+		}
+		if c.Dark(x2, y2) {
+			second |= 1 << i
+		}
+	}
+	require.Equal(t, first, second, "format information copies differ")
+
+	var level Level
+	mask := -1
+	for _, l := range []Level{L, M} {
+		for m := range 8 {
+			if formatInfo(l, m) == first {
+				level, mask = l, m
+			}
+		}
+	}
+	require.NotEqual(t, -1, mask, "invalid format information %015b", first)
+
+	// Read the codewords in zigzag order, removing the mask.
+	var bits []bool
+	for right := c.Size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right--
+		}
+		for vert := range c.Size {
+			y := vert
+			if (right+1)&2 == 0 {
+				y = c.Size - 1 - vert
+			}
+			for _, x := range []int{right, right - 1} {
+				if !c.function[y][x] {
+					bits = append(bits, c.Dark(x, y) != maskFuncs[mask](x, y))
+				}
+			}
+		}
+	}
+	spec := blocks[level][c.Version-1]
+	n := spec.blocks1 + spec.blocks2
+	require.Len(t, bits, (spec.dataCodewords()+n*spec.ecc)*8+remainderBits[c.Version-1])
+	codewords := make([]byte, len(bits)/8)
+	for i := range codewords {
+		for j := range 8 {
+			if bits[i*8+j] {
+				codewords[i] |= 0x80 >> j
+			}
+		}
+	}
+
+	// De-interleave the blocks and check them.
+	dataBlocks := make([][]byte, n)
+	pos := 0
+	for i := range spec.data1 + 1 {
+		for b := range n {
+			if i < spec.data1 || b >= spec.blocks1 {
+				dataBlocks[b] = append(dataBlocks[b], codewords[pos])
+				pos++
+			}
+		}
+	}
+	gen := generator(spec.ecc)
+	eccBlocks := make([][]byte, n)
+	for range spec.ecc {
+		for b := range n {
+			eccBlocks[b] = append(eccBlocks[b], codewords[pos])
+			pos++
+		}
+	}
+	var data []byte
+	for b := range n {
+		require.Equal(t, remainder(dataBlocks[b], gen), eccBlocks[b], "block %d", b)
+		data = append(data, dataBlocks[b]...)
+	}
+
+	// Parse the byte mode segment.
+	bit := 0
+	read := func(n int) int {
+		v := 0
+		for range n {
+			v = v<<1 | int(data[bit/8]>>(7-bit%8)&1)
+			bit++
+		}
+		return v
+	}
+	require.Equal(t, 0b0100, read(4))
+	out := make([]byte, read(countBits(c.Version)))
+	for i := range out {
+		out[i] = byte(read(8))
+	}
+	return out
+}
@@ -0,0 +1,64 @@
+package qr
+
+// Reed-Solomon error correction over GF(256) with the QR code polynomial
+// x^8 + x^4 + x^3 + x^2 + 1.
+
+var gfExp, gfLog = func() (exp [512]byte, log [256]byte) {
+	x := 1
+	for i := range 255 {
+		exp[i] = byte(x)
+		log[x] = byte(i)
+		x <<= 1
+		if x&0x100 != 0 {
+			x ^= 0x11D
+		}
+	}
+	// Repeat the table so gfMul need not reduce the exponent.
+	for i := 255; i < len(exp); i++ {
+		exp[i] = exp[i-255]
+	}
+	return exp, log
+}()
+
+// gfMul multiplies two field elements.
+func gfMul(a, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return gfExp[int(gfLog[a])+int(gfLog[b])]
+}
+
+// generator returns the coefficients of the generator polynomial
+// (x - α^0)(x - α^1)...(x - α^(degree-1)), highest degree first, without
+// the leading 1.
+func generator(degree int) []byte {
+	gen := make([]byte, degree)
+	gen[degree-1] = 1 // start with the polynomial 1
+	root := byte(1)
+	for range degree {
+		// Multiply by (x - root).
+		for j := range gen {
+			gen[j] = gfMul(gen[j], root)
+			if j+1 < len(gen) {
+				gen[j] ^= gen[j+1]
+			}
+		}
+		root = gfMul(root, 2)
+	}
+	return gen
+}
+
+// remainder returns the error correction codewords for data: the remainder
+// of data(x)·x^len(gen) divided by the generator polynomial.
+func remainder(data, gen []byte) []byte {
+	rem := make([]byte, len(gen))
+	for _, b := range data {
+		factor := b ^ rem[0]
+		copy(rem, rem[1:])
+		rem[len(rem)-1] = 0
+		for i, g := range gen {
+			rem[i] ^= gfMul(g, factor)
+		}
+	}
+	return rem
+}
@@ -0,0 +1,248 @@
+package spec
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/ironsh/irons/api"
+	"github.com/ironsh/irons/config"
+)
+
+// State is the current state of an account, as far as a spec manages it.
+type State struct {
+	// EgressMode is the account's egress mode.
+	EgressMode string
+	Rules      []api.EgressRule
+	// VMs are the account's VMs that have not been destroyed.
+	VMs []api.VM
+	// VMEgressModes holds the egress mode of VMs, by ID. Only VMs whose
+	// mode the spec sets need to be present.
+	VMEgressModes map[string]string
+	Secrets       []api.Secret
+}
+
+// Action is what a Change does to a resource.
+type Action string
+
+const (
+	Create Action = "create"
+	Update Action = "update"
+	// Replace creates a resource again and deletes the old one, for changes
+	// the API cannot make in place.
+	Replace Action = "replace"
+	Delete  Action = "delete"
+)
+
+// Kinds of resource a Change applies to.
+const (
+	KindEgressMode = "egress_mode"
+	KindEgressRule = "egress_rule"
+	KindSecret     = "secret"
+	KindVM         = "vm"
+)
+
+// Change is one step towards the desired state.
+type Change struct {
+	Action Action
+	Kind   string
+	// Name identifies the resource to people: a VM or secret name, a rule's
+	// host or CIDR, or "account" for the egress mode.
+	Name string
+	// ID is the ID of the existing resource, for updates and deletes.
+	ID string
+	// Fields lists the attributes that change, for updates and replaces.
+	Fields []FieldChange
+
+	// The desired resource, for creates, updates and replaces of its kind.
+	VM     *VM
+	Rule   *config.EgressRule
+	Secret *Secret
+	// Mode is the desired account egress mode.
+	Mode string
+}
+
+// FieldChange is a change to one attribute of a resource.
+type FieldChange struct {
+	Field    string
+	From, To string
+}
+
+// String describes the field change, e.g. `mode: "enforce" -> "warn"`.
+func (f FieldChange) String() string {
+	return fmt.Sprintf("%s: %q -> %q", f.Field, f.From, f.To)
+}
+
+// Plan returns the changes that bring the account in state to the spec,
+// ordered so that egress policy, rules and secrets exist before the VMs
+// that use them, and deletions come last. With prune, resources of the
+// kinds the spec manages that it does not list are deleted.
+func Plan(s *Spec, state *State, prune bool) []Change {
+	var changes, deletes []Change
+
+	if s.Egress != nil {
+		if s.Egress.Mode != "" && s.Egress.Mode != state.EgressMode {
+			changes = append(changes, Change{
+				Action: Update, Kind: KindEgressMode, Name: "account", Mode: s.Egress.Mode,
+				Fields: []FieldChange{{"mode", state.EgressMode, s.Egress.Mode}},
+			})
+		}
+		c, d := planRules(s.Egress.Rules, state.Rules, prune && s.Egress.Rules != nil)
+		changes, deletes = append(changes, c...), append(deletes, d...)
+	}
+	if s.Secrets != nil {
+		c, d := planSecrets(s.Secrets, state.Secrets, prune)
+		changes, deletes = append(changes, c...), append(deletes, d...)
+	}
+	if s.VMs != nil {
+		c, d := planVMs(s.VMs, state, prune)
+		changes, deletes = append(changes, c...), append(deletes, d...)
+	}
+
+	return append(changes, deletes...)
+}
+
+func planRules(want []config.EgressRule, have []api.EgressRule, prune bool) (changes, deletes []Change) {
+	existing := map[string]api.EgressRule{}
+	for _, r := range have {
+		existing[ruleKey(r.Host, r.CIDR)] = r
+	}
+
+	wanted := map[string]bool{}
+	for i := range want {
+		r := &want[i]
+		key := ruleKey(r.Host, r.CIDR)
+		wanted[key] = true
+		cur, ok := existing[key]
+		if !ok {
+			changes = append(changes, Change{Action: Create, Kind: KindEgressRule, Name: r.Host + r.CIDR, Rule: r})
+			continue
+		}
+		// Rules cannot be updated, so a changed name or comment means a
+		// new rule.
+		var fields []FieldChange
+		if r.Name != cur.Name {
+			fields = append(fields, FieldChange{"name", cur.Name, r.Name})
+		}
+		if r.Comment != cur.Comment {
+			fields = append(fields, FieldChange{"comment", cur.Comment, r.Comment})
+		}
+		if len(fields) > 0 {
+			changes = append(changes, Change{Action: Replace, Kind: KindEgressRule, Name: r.Host + r.CIDR, ID: cur.ID, Rule: r, Fields: fields})
+		}
+	}
+
+	if prune {
+		for _, r := range have {
+			if !wanted[ruleKey(r.Host, r.CIDR)] {
+				deletes = append(deletes, Change{Action: Delete, Kind: KindEgressRule, Name: r.Host + r.CIDR, ID: r.ID})
+			}
+		}
+	}
+	return changes, deletes
+}
+
+func planSecrets(want []Secret, have []api.Secret, prune bool) (changes, deletes []Change) {
+	existing := map[string]api.Secret{}
+	for _, s := range have {
+		existing[s.Name] = s
+	}
+
+	wanted := map[string]bool{}
+	for i := range want {
+		s := &want[i]
+		wanted[s.Name] = true
+		cur, ok := existing[s.Name]
+		if !ok {
+			changes = append(changes, Change{Action: Create, Kind: KindSecret, Name: s.Name, Secret: s})
+			continue
+		}
+
+		var fields []FieldChange
+		if s.EnvVar != cur.EnvVar {
+			fields = append(fields, FieldChange{"env_var", cur.EnvVar, s.EnvVar})
+		}
+		if !sameHosts(s.Hosts, cur.Hosts) {
+			fields = append(fields, FieldChange{"hosts", strings.Join(cur.Hosts, ", "), strings.Join(s.Hosts, ", ")})
+		}
+		comment := ""
+		if cur.Comment != nil {
+			comment = *cur.Comment
+		}
+		if s.Comment != comment {
+			fields = append(fields, FieldChange{"comment", comment, s.Comment})
+		}
+		if len(fields) > 0 {
+			changes = append(changes, Change{Action: Update, Kind: KindSecret, Name: s.Name, ID: cur.ID, Secret: s, Fields: fields})
+		}
+	}
+
+	if prune {
+		for _, s := range have {
+			if !wanted[s.Name] {
+				deletes = append(deletes, Change{Action: Delete, Kind: KindSecret, Name: s.Name, ID: s.ID})
+			}
+		}
+	}
+	return changes, deletes
+}
+
+func planVMs(want []VM, state *State, prune bool) (changes, deletes []Change) {
+	existing := map[string]api.VM{}
+	for _, vm := range state.VMs {
+		existing[vm.Name] = vm
+	}
+
+	wanted := map[string]bool{}
+	for i := range want {
+		vm := &want[i]
+		wanted[vm.Name] = true
+		cur, ok := existing[vm.Name]
+		if !ok {
+			changes = append(changes, Change{Action: Create, Kind: KindVM, Name: vm.Name, VM: vm})
+			continue
+		}
+		if mode := state.VMEgressModes[cur.ID]; vm.EgressMode != "" && vm.EgressMode != mode {
+			changes = append(changes, Change{
+				Action: Update, Kind: KindVM, Name: vm.Name, ID: cur.ID, VM: vm,
+				Fields: []FieldChange{{"egress_mode", mode, vm.EgressMode}},
+			})
+		}
+	}
+
+	if prune {
+		for _, vm := range state.VMs {
+			if !wanted[vm.Name] {
+				deletes = append(deletes, Change{Action: Delete, Kind: KindVM, Name: vm.Name, ID: vm.ID})
+			}
+		}
+	}
+	return changes, deletes
+}
+
+// sameHosts reports whether two host lists hold the same hosts in any order.
+func sameHosts(a, b []string) bool {
+	a, b = slices.Clone(a), slices.Clone(b)
+	slices.Sort(a)
+	slices.Sort(b)
+	return slices.Equal(a, b)
+}
+
+// Summary counts the changes as Terraform does, with a replace counting as
+// both an add and a destroy.
+func Summary(changes []Change) (add, change, destroy int) {
+	for _, c := range changes {
+		switch c.Action {
+		case Create:
+			add++
+		case Update:
+			change++
+		case Replace:
+			add++
+			destroy++
+		case Delete:
+			destroy++
+		}
+	}
+	return add, change, destroy
+}
@@ -0,0 +1,111 @@
+package spec
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ironsh/irons/api"
+	"github.com/ironsh/irons/config"
+	"github.com/stretchr/testify/require"
+)
+
+// actions summarizes changes as "action kind name" strings.
+func actions(changes []Change) []string {
+	var out []string
+	for _, c := range changes {
+		out = append(out, string(c.Action)+" "+c.Kind+" "+c.Name)
+	}
+	return out
+}
+
+func TestPlan(t *testing.T) {
+	comment := "old"
+	s := &Spec{
+		Egress: &Egress{Mode: "warn", Rules: []config.EgressRule{
+			{Host: "github.com"},
+			{CIDR: "10.0.0.0/8", Comment: "internal"},
+			{Host: "proxy.golang.org"},
+		}},
+		VMs: []VM{{Name: "agent-1", EgressMode: "warn"}, {Name: "agent-2"}},
+		Secrets: []Secret{
+			{Name: "gh", EnvVar: "GITHUB_TOKEN", Hosts: []string{"b.example", "a.example"}},
+			{Name: "npm", EnvVar: "NPM_TOKEN", Comment: "new"},
+		},
+	}
+	state := &State{
+		EgressMode: "enforce",
+		Rules: []api.EgressRule{
+			{ID: "r1", Host: "github.com"},
+			{ID: "r2", CIDR: "10.0.0.0/8"},
+			{ID: "r3", Host: "stale.example"},
+		},
+		VMs:           []api.VM{{ID: "vm1", Name: "agent-1"}, {ID: "vm9", Name: "old"}},
+		VMEgressModes: map[string]string{"vm1": "enforce"},
+		Secrets: []api.Secret{
+			{ID: "s1", Name: "gh", EnvVar: "GITHUB_TOKEN", Hosts: []string{"a.example", "b.example"}},
+			{ID: "s2", Name: "npm", EnvVar: "NPM_TOKEN", Comment: &comment},
+		},
+	}
+
+	require.Equal(t, []string{
+		"update egress_mode account",
+		"replace egress_rule 10.0.0.0/8",
+		"create egress_rule proxy.golang.org",
+		"update secret npm",
+		"update vm agent-1",
+		"create vm agent-2",
+	}, actions(Plan(s, state, false)))
+
+	changes := Plan(s, state, true)
+	require.Equal(t, []string{
+		"delete egress_rule stale.example",
+		"delete vm old",
+	}, actions(changes[len(changes)-2:]))
+	add, change, destroy := Summary(changes)
+	require.Equal(t, []int{3, 3, 3}, []int{add, change, destroy})
+}
+
+func TestPlan_UnmanagedSections(t *testing.T) {
+	s := &Spec{Egress: &Egress{Mode: "enforce"}}
+	state := &State{
+		EgressMode: "enforce",
+		Rules:      []api.EgressRule{{ID: "r1", Host: "github.com"}},
+		VMs:        []api.VM{{ID: "vm1", Name: "agent-1"}},
+		Secrets:    []api.Secret{{ID: "s1", Name: "gh"}},
+	}
+	require.Empty(t, Plan(s, state, true))
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "irons.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`egress:
+  rules: []
+vms:
+  - name: agent-1
+    ssh_key: keys/id.pub
+secrets:
+  - name: gh
+    env_var: GITHUB_TOKEN
+    value_file: gh.txt
+`), 0o644))
+
+	s, err := Load(path)
+	require.NoError(t, err)
+	require.NotNil(t, s.Egress.Rules, "an empty rules list is managed")
+	require.Equal(t, filepath.Join(dir, "keys", "id.pub"), s.VMs[0].SSHKey)
+	require.Equal(t, filepath.Join(dir, "gh.txt"), s.Secrets[0].ValueFile)
+
+	for content, want := range map[string]string{
+		"vms:\n  - name: a\n  - name: a\n":            "listed twice",
+		"vms:\n  - name: a\n    egress_mode: block\n": "must be enforce or warn",
+		"egress:\n  rules:\n    - comment: x\n":       "exactly one of host or cidr",
+		"secrets:\n  - name: gh\n":                    "has no env_var",
+		"vm:\n  - name: a\n":                          "field vm not found",
+	} {
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+		_, err := Load(path)
+		require.ErrorContains(t, err, want, content)
+	}
+}
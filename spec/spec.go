@@ -0,0 +1,200 @@
+// Package spec defines the declarative sandbox spec read by `irons plan` and
+// `irons apply`, and works out the changes that converge an account on it.
+//
+// A spec lists the VMs, egress policy and rules, and secrets an account
+// should have:
+//
+//	egress:
+//	  mode: enforce
+//	  rules:
+//	    - host: github.com
+//	    - cidr: 10.0.0.0/8
+//	      comment: internal network
+//	vms:
+//	  - name: agent-1
+//	    ssh_key: ~/.ssh/id_ed25519.pub
+//	    egress_mode: warn
+//	secrets:
+//	  - name: github-token
+//	    env_var: GITHUB_TOKEN
+//	    hosts: [api.github.com]
+//	    value_from_env: GITHUB_TOKEN
+//
+// Sections that are left out are not managed: their resources are neither
+// created nor pruned.
+package spec
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/ironsh/irons/config"
+	"go.yaml.in/yaml/v3"
+)
+
+// Spec is the desired state of an account.
+type Spec struct {
+	Egress  *Egress  `yaml:"egress,omitempty"`
+	VMs     []VM     `yaml:"vms,omitempty"`
+	Secrets []Secret `yaml:"secrets,omitempty"`
+
+	// Path is the file the spec was loaded from.
+	Path string `yaml:"-"`
+}
+
+// Egress is the desired account-wide egress policy.
+type Egress struct {
+	// Mode is the account's egress mode, enforce or warn. Empty leaves it
+	// unmanaged.
+	Mode string `yaml:"mode,omitempty"`
+	// Rules are the account's egress rules. A rule is identified by its host
+	// or CIDR.
+	Rules []config.EgressRule `yaml:"rules,omitempty"`
+}
+
+// VM is a desired VM, identified by its name.
+type VM struct {
+	Name string `yaml:"name"`
+	// SSHKey is the public key the VM is created with. A relative path is
+	// relative to the spec file. Empty means the ssh_key setting. The key
+	// of an existing VM cannot be changed.
+	SSHKey string `yaml:"ssh_key,omitempty"`
+	// EgressMode is the VM's egress mode, enforce or warn. Empty leaves it
+	// unmanaged.
+	EgressMode string `yaml:"egress_mode,omitempty"`
+}
+
+// Secret is a desired secret, identified by its name. Its value is only
+// read when the secret is created, since the API never returns it to
+// compare against.
+type Secret struct {
+	Name    string   `yaml:"name"`
+	EnvVar  string   `yaml:"env_var"`
+	Hosts   []string `yaml:"hosts,omitempty"`
+	Comment string   `yaml:"comment,omitempty"`
+	// ValueFromEnv names the environment variable holding the value.
+	ValueFromEnv string `yaml:"value_from_env,omitempty"`
+	// ValueFile is a file holding the value, relative to the spec file.
+	ValueFile string `yaml:"value_file,omitempty"`
+}
+
+// Value reads the secret's value from its environment variable or file.
+func (s Secret) Value() (string, error) {
+	switch {
+	case s.ValueFromEnv != "":
+		v := os.Getenv(s.ValueFromEnv)
+		if v == "" {
+			return "", fmt.Errorf("secret %q: environment variable %s is not set", s.Name, s.ValueFromEnv)
+		}
+		return v, nil
+	case s.ValueFile != "":
+		data, err := os.ReadFile(s.ValueFile)
+		if err != nil {
+			return "", fmt.Errorf("secret %q: %w", s.Name, err)
+		}
+		return string(bytes.TrimRight(data, "\r\n")), nil
+	default:
+		return "", fmt.Errorf("secret %q has no value_from_env or value_file to create it with", s.Name)
+	}
+}
+
+// Load reads and validates a spec. Unknown keys are rejected so that typos
+// are caught, and relative paths are resolved against the file's directory.
+func Load(path string) (*Spec, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading spec: %w", err)
+	}
+
+	var s Spec
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&s); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	s.Path = path
+
+	dir := filepath.Dir(path)
+	for i := range s.VMs {
+		if s.VMs[i].SSHKey != "" {
+			s.VMs[i].SSHKey = config.ResolvePath(dir, s.VMs[i].SSHKey)
+		}
+	}
+	for i := range s.Secrets {
+		if s.Secrets[i].ValueFile != "" {
+			s.Secrets[i].ValueFile = config.ResolvePath(dir, s.Secrets[i].ValueFile)
+		}
+	}
+
+	if err := s.validate(); err != nil {
+		return nil, fmt.Errorf("invalid spec %s: %w", path, err)
+	}
+	return &s, nil
+}
+
+// validate checks required fields, allowed values and uniqueness.
+func (s *Spec) validate() error {
+	if s.Egress != nil {
+		if err := validateMode(s.Egress.Mode); err != nil {
+			return fmt.Errorf("egress: %w", err)
+		}
+		seen := map[string]bool{}
+		for i, r := range s.Egress.Rules {
+			if (r.Host == "") == (r.CIDR == "") {
+				return fmt.Errorf("egress rule %d must have exactly one of host or cidr", i+1)
+			}
+			if seen[ruleKey(r.Host, r.CIDR)] {
+				return fmt.Errorf("egress rule for %s is listed twice", r.Host+r.CIDR)
+			}
+			seen[ruleKey(r.Host, r.CIDR)] = true
+		}
+	}
+
+	seen := map[string]bool{}
+	for i, vm := range s.VMs {
+		if vm.Name == "" {
+			return fmt.Errorf("vm %d has no name", i+1)
+		}
+		if seen[vm.Name] {
+			return fmt.Errorf("vm %q is listed twice", vm.Name)
+		}
+		seen[vm.Name] = true
+		if err := validateMode(vm.EgressMode); err != nil {
+			return fmt.Errorf("vm %q: %w", vm.Name, err)
+		}
+	}
+
+	seen = map[string]bool{}
+	for i, sec := range s.Secrets {
+		if sec.Name == "" {
+			return fmt.Errorf("secret %d has no name", i+1)
+		}
+		if seen[sec.Name] {
+			return fmt.Errorf("secret %q is listed twice", sec.Name)
+		}
+		seen[sec.Name] = true
+		if sec.EnvVar == "" {
+			return fmt.Errorf("secret %q has no env_var", sec.Name)
+		}
+		if sec.ValueFromEnv != "" && sec.ValueFile != "" {
+			return fmt.Errorf("secret %q: value_from_env and value_file cannot both be set", sec.Name)
+		}
+	}
+	return nil
+}
+
+func validateMode(mode string) error {
+	if mode != "" && mode != "enforce" && mode != "warn" {
+		return fmt.Errorf("egress mode %q must be enforce or warn", mode)
+	}
+	return nil
+}
+
+// ruleKey identifies an egress rule by its host or CIDR.
+func ruleKey(host, cidr string) string {
+	return host + "|" + cidr
+}